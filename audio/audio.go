@@ -0,0 +1,21 @@
+// Package audio holds the machine-agnostic parts of sound output: sources of
+// 16-bit PCM, and WAV files.
+package audio
+
+// DefaultSampleRate is used for output and recordings
+const DefaultSampleRate = 48000
+
+// Source produces mono 16-bit samples on demand
+type Source interface {
+	// Generate fills buf with the next len(buf) samples
+	Generate(buf []int16)
+}
+
+// Silence is a Source that produces no sound
+type Silence struct{}
+
+func (Silence) Generate(buf []int16) {
+	for i := range buf {
+		buf[i] = 0
+	}
+}
@@ -0,0 +1,113 @@
+package audio
+
+// Mixer plays sounds on a fixed number of channels and sums them into one
+// mono output. Sounds are resampled to the output rate as they play, so
+// sample sets recorded at any rate can be mixed.
+type Mixer struct {
+	// Gain scales the mixed output (1 is unity)
+	Gain float64
+
+	sampleRate int
+	voices     []voice
+}
+
+type voice struct {
+	sound *Sound
+	// pos and step are in 1/65536ths of a source sample
+	pos     uint64
+	step    uint64
+	loop    bool
+	playing bool
+}
+
+func NewMixer(sampleRate, channels int) *Mixer {
+	return &Mixer{Gain: 1, sampleRate: sampleRate, voices: make([]voice, channels)}
+}
+
+// Play starts s on channel from the beginning, replacing whatever the channel
+// was playing. A nil sound just stops the channel.
+func (m *Mixer) Play(channel int, s *Sound, loop bool) {
+	v := &m.voices[channel]
+	if s == nil || len(s.Samples) == 0 || s.SampleRate == 0 {
+		v.playing = false
+		return
+	}
+	*v = voice{
+		sound:   s,
+		step:    uint64(s.SampleRate) << 16 / uint64(m.sampleRate),
+		loop:    loop,
+		playing: true,
+	}
+}
+
+// Stop silences channel
+func (m *Mixer) Stop(channel int) {
+	m.voices[channel].playing = false
+}
+
+// Playing reports whether channel is still sounding
+func (m *Mixer) Playing(channel int) bool {
+	return m.voices[channel].playing
+}
+
+func (m *Mixer) Generate(buf []int16) {
+	gain := int64(m.Gain * 256)
+	for i := range buf {
+		var sum int64
+		for c := range m.voices {
+			sum += int64(m.voices[c].next())
+		}
+		buf[i] = clamp16(sum * gain / 256)
+	}
+}
+
+// next returns the voice's next output sample, interpolating linearly
+// between source samples
+func (v *voice) next() int16 {
+	if !v.playing {
+		return 0
+	}
+	samples := v.sound.Samples
+	i, frac := int(v.pos>>16), int64(v.pos&0xffff)
+	a, b := int64(samples[i]), int64(0)
+	if i+1 < len(samples) {
+		b = int64(samples[i+1])
+	} else if v.loop {
+		b = int64(samples[0])
+	}
+	v.pos += v.step
+	if int(v.pos>>16) >= len(samples) {
+		if v.loop {
+			v.pos -= uint64(len(samples)) << 16
+		} else {
+			v.playing = false
+		}
+	}
+	return int16(a + (b-a)*frac/65536)
+}
+
+func clamp16(v int64) int16 {
+	if v > 32767 {
+		return 32767
+	}
+	if v < -32768 {
+		return -32768
+	}
+	return int16(v)
+}
+
+// Mono returns s mixed down to one channel
+func (s *Sound) Mono() *Sound {
+	if s.Channels <= 1 {
+		return s
+	}
+	mono := &Sound{SampleRate: s.SampleRate, Channels: 1, Samples: make([]int16, len(s.Samples)/s.Channels)}
+	for i := range mono.Samples {
+		var sum int
+		for c := 0; c < s.Channels; c++ {
+			sum += int(s.Samples[i*s.Channels+c])
+		}
+		mono.Samples[i] = int16(sum / s.Channels)
+	}
+	return mono
+}
@@ -0,0 +1,48 @@
+package audio
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMixer(t *testing.T) {
+	m := NewMixer(8000, 2)
+	// The same rate plays back sample for sample; half the rate is interpolated
+	m.Play(0, &Sound{SampleRate: 8000, Channels: 1, Samples: []int16{100, 200, 300}}, false)
+	m.Play(1, &Sound{SampleRate: 4000, Channels: 1, Samples: []int16{1000, 2000}}, true)
+
+	buf := make([]int16, 6)
+	m.Generate(buf)
+	want := []int16{1100, 1700, 2300, 1500, 1000, 1500}
+	if !reflect.DeepEqual(buf, want) {
+		t.Fatalf("expected %v, got %v\n", want, buf)
+	}
+	if m.Playing(0) || !m.Playing(1) {
+		t.Fatalf("expected the one-shot to end and the loop to continue\n")
+	}
+
+	m.Stop(1)
+	m.Generate(buf)
+	if !reflect.DeepEqual(buf, make([]int16, 6)) {
+		t.Fatalf("expected silence, got %v\n", buf)
+	}
+}
+
+func TestMixerClamps(t *testing.T) {
+	m := NewMixer(8000, 2)
+	loud := &Sound{SampleRate: 8000, Channels: 1, Samples: []int16{30000, -30000}}
+	m.Play(0, loud, false)
+	m.Play(1, loud, false)
+	buf := make([]int16, 2)
+	m.Generate(buf)
+	if buf[0] != 32767 || buf[1] != -32768 {
+		t.Fatalf("expected clamped output, got %v\n", buf)
+	}
+}
+
+func TestSoundMono(t *testing.T) {
+	s := &Sound{SampleRate: 8000, Channels: 2, Samples: []int16{100, 300, -10, 10}}
+	if got := s.Mono().Samples; !reflect.DeepEqual(got, []int16{200, 0}) {
+		t.Fatalf("unexpected mix %v\n", got)
+	}
+}
@@ -0,0 +1,63 @@
+// Package sdlaudio plays 16-bit mono audio through an SDL audio device
+package sdlaudio
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/veandco/go-sdl2/sdl"
+)
+
+// Player queues audio to the default output device. The emulator pushes each
+// frame's samples as it runs, so the device plays at the pace of emulation.
+type Player struct {
+	dev sdl.AudioDeviceID
+	// maxQueued is the most audio, in bytes, allowed to wait in the queue
+	maxQueued uint32
+	buf       []byte
+}
+
+// MaxLatency is the most audio, in seconds, kept queued. Samples beyond it
+// are dropped, so fast-forward does not build up a delay.
+const MaxLatency = 0.1
+
+func Open(sampleRate int) (*Player, error) {
+	if err := sdl.InitSubSystem(sdl.INIT_AUDIO); err != nil {
+		return nil, fmt.Errorf("sdl audio init failed: %v", err)
+	}
+	want := sdl.AudioSpec{
+		Freq:     int32(sampleRate),
+		Format:   sdl.AUDIO_S16LSB,
+		Channels: 1,
+		Samples:  1024,
+	}
+	// SDL converts to whatever the device needs
+	dev, err := sdl.OpenAudioDevice("", false, &want, nil, 0)
+	if err != nil {
+		sdl.QuitSubSystem(sdl.INIT_AUDIO)
+		return nil, fmt.Errorf("open audio device failed: %v", err)
+	}
+	sdl.PauseAudioDevice(dev, false)
+	return &Player{dev: dev, maxQueued: uint32(MaxLatency * float64(sampleRate) * 2)}, nil
+}
+
+// Queue appends samples to the device's queue
+func (p *Player) Queue(samples []int16) error {
+	if sdl.GetQueuedAudioSize(p.dev) > p.maxQueued {
+		return nil
+	}
+	if cap(p.buf) < 2*len(samples) {
+		p.buf = make([]byte, 2*len(samples))
+	}
+	buf := p.buf[:2*len(samples)]
+	for i, s := range samples {
+		binary.LittleEndian.PutUint16(buf[2*i:], uint16(s))
+	}
+	return sdl.QueueAudio(p.dev, buf)
+}
+
+func (p *Player) Close() error {
+	sdl.CloseAudioDevice(p.dev)
+	sdl.QuitSubSystem(sdl.INIT_AUDIO)
+	return nil
+}
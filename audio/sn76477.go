@@ -0,0 +1,155 @@
+package audio
+
+import "fmt"
+
+// SN76477Mixer is the source selection on the SN76477 mixer pins (A, B, C).
+// Combined sources gate each other: the output is high only while all of
+// them are.
+type SN76477Mixer int
+
+const (
+	MixVCO SN76477Mixer = iota
+	MixSLF
+	MixNoise
+	MixVCONoise
+	MixSLFNoise
+	MixSLFVCONoise
+	MixSLFVCO
+	MixInhibit
+)
+
+func (m SN76477Mixer) String() string {
+	names := [...]string{"vco", "slf", "noise", "vco+noise", "slf+noise", "slf+vco+noise", "slf+vco", "inhibit"}
+	if m >= 0 && int(m) < len(names) {
+		return names[m]
+	}
+	return fmt.Sprintf("SN76477Mixer(%d)", int(m))
+}
+
+// Capacitor voltage swings of the SN76477 oscillators. The VCO runs at its
+// maximum frequency with a control voltage of sn76477VCOMaxVolts and scales
+// linearly below it.
+const (
+	sn76477SLFMinVolts = 0.33
+	sn76477SLFMaxVolts = 2.37
+	sn76477VCOMaxVolts = sn76477SLFMaxVolts + 0.35
+)
+
+// SN76477 is a behavioural model of the Texas Instruments SN76477 Complex
+// Sound Generator. The oscillators are set from their external components,
+// as on a schematic, with the datasheet formula f = 0.64 / RC.
+type SN76477 struct {
+	// SLF is the super low frequency oscillator
+	SLFRes, SLFCap float64
+	// VCO is the voltage controlled oscillator. With VCOFromSLF the SLF
+	// triangle sweeps it, otherwise VCOControl (volts) sets its frequency.
+	VCORes, VCOCap float64
+	VCOFromSLF     bool
+	VCOControl     float64
+	// NoiseHz clocks the noise generator, low-passed at NoiseFilterHz
+	NoiseHz       float64
+	NoiseFilterHz float64
+	Mixer         SN76477Mixer
+	// AttackTime and DecayTime (seconds) shape the output when the chip is
+	// enabled and inhibited
+	AttackTime, DecayTime float64
+	// Amplitude scales the output, at most 1
+	Amplitude float64
+
+	sampleRate int
+	started    bool
+	enabled    bool
+	slf, vco   Oscillator
+	noise      *Noise
+	filter     LowPass
+	env        Envelope
+}
+
+func NewSN76477(sampleRate int) *SN76477 {
+	return &SN76477{Amplitude: 1, sampleRate: sampleRate}
+}
+
+// SLFHz is the frequency of the super low frequency oscillator
+func (c *SN76477) SLFHz() float64 {
+	return rcHz(c.SLFRes, c.SLFCap)
+}
+
+// VCOMaxHz is the frequency of the VCO at its highest control voltage
+func (c *SN76477) VCOMaxHz() float64 {
+	return rcHz(c.VCORes, c.VCOCap)
+}
+
+func rcHz(r, c float64) float64 {
+	if r <= 0 || c <= 0 {
+		return 0
+	}
+	return 0.64 / (r * c)
+}
+
+// SetEnable drives the inhibit pin: the output fades in over AttackTime when
+// enabled and out over DecayTime when inhibited
+func (c *SN76477) SetEnable(on bool) {
+	c.start()
+	c.enabled = on
+	if on {
+		c.env.Charge(1, c.AttackTime)
+	} else {
+		c.env.Charge(0, c.DecayTime)
+	}
+}
+
+func (c *SN76477) start() {
+	if c.started {
+		return
+	}
+	c.started = true
+	c.slf, c.vco = NewOscillator(c.sampleRate), NewOscillator(c.sampleRate)
+	c.noise = NewNoise(c.NoiseHz, c.sampleRate)
+	c.filter = NewLowPass(c.NoiseFilterHz, c.sampleRate)
+	c.env = NewEnvelope(c.sampleRate)
+}
+
+// Next returns the next output sample, in [-Amplitude, Amplitude]
+func (c *SN76477) Next() float64 {
+	c.start()
+	slfPhase := c.slf.Next(c.SLFHz())
+	control := c.VCOControl
+	if c.VCOFromSLF {
+		control = sn76477SLFMinVolts + (sn76477SLFMaxVolts-sn76477SLFMinVolts)*Triangle(slfPhase)
+	}
+	vcoPhase := c.vco.Next(c.VCOMaxHz() * control / sn76477VCOMaxVolts)
+
+	slf, vco := slfPhase < 0.5, vcoPhase < 0.5
+	noise := true
+	if c.NoiseHz > 0 {
+		n := c.noise.Next()
+		if c.NoiseFilterHz > 0 {
+			n = c.filter.Next(n)
+		}
+		noise = n > 0
+	}
+	var high bool
+	switch c.Mixer {
+	case MixVCO:
+		high = vco
+	case MixSLF:
+		high = slf
+	case MixNoise:
+		high = noise
+	case MixVCONoise:
+		high = vco && noise
+	case MixSLFNoise:
+		high = slf && noise
+	case MixSLFVCONoise:
+		high = slf && vco && noise
+	case MixSLFVCO:
+		high = slf && vco
+	default:
+		return 0
+	}
+	level := c.env.Next() * c.Amplitude
+	if high {
+		return level
+	}
+	return -level
+}
@@ -0,0 +1,120 @@
+package audio
+
+import "math"
+
+// Building blocks for modelling analogue sound circuits. Each runs at a fixed
+// sample rate and advances one sample per call to Next, so a circuit renders
+// the same samples however its output is split into buffers.
+
+// rcCoeff is the fraction of the remaining distance an RC stage with time
+// constant tau (seconds) covers in one sample
+func rcCoeff(tau float64, sampleRate int) float64 {
+	if tau <= 0 {
+		return 1
+	}
+	return 1 - math.Exp(-1/(tau*float64(sampleRate)))
+}
+
+// Oscillator is a phase accumulator
+type Oscillator struct {
+	sampleRate float64
+	phase      float64
+}
+
+func NewOscillator(sampleRate int) Oscillator {
+	return Oscillator{sampleRate: float64(sampleRate)}
+}
+
+// Next advances by one sample at hz and returns the phase, in [0, 1)
+func (o *Oscillator) Next(hz float64) float64 {
+	o.phase += hz / o.sampleRate
+	o.phase -= math.Floor(o.phase)
+	return o.phase
+}
+
+// Reset restarts the cycle
+func (o *Oscillator) Reset() {
+	o.phase = 0
+}
+
+// Square maps a phase to a square wave of ±1 with the given duty cycle
+func Square(phase, duty float64) float64 {
+	if phase < duty {
+		return 1
+	}
+	return -1
+}
+
+// Triangle maps a phase to a triangle wave rising from 0 to 1 and back
+func Triangle(phase float64) float64 {
+	if phase < 0.5 {
+		return 2 * phase
+	}
+	return 2 - 2*phase
+}
+
+// Noise is white noise from a 17-bit shift register clocked at Hz, as in the
+// SN76477 and the MM5837 noise sources. Its output is ±1.
+type Noise struct {
+	Hz  float64
+	osc Oscillator
+	reg uint32
+}
+
+func NewNoise(hz float64, sampleRate int) *Noise {
+	return &Noise{Hz: hz, osc: NewOscillator(sampleRate), reg: 1}
+}
+
+func (n *Noise) Next() float64 {
+	// Clock the register once for every cycle completed since the last sample
+	steps := int(n.osc.phase + n.Hz/n.osc.sampleRate)
+	n.osc.Next(n.Hz)
+	for ; steps > 0; steps-- {
+		bit := (n.reg ^ n.reg>>3) & 1
+		n.reg = n.reg>>1 | bit<<16
+	}
+	if n.reg&1 != 0 {
+		return 1
+	}
+	return -1
+}
+
+// LowPass is a one-pole RC low-pass filter
+type LowPass struct {
+	k float64
+	y float64
+}
+
+func NewLowPass(cutoffHz float64, sampleRate int) LowPass {
+	return LowPass{k: rcCoeff(1/(2*math.Pi*cutoffHz), sampleRate)}
+}
+
+func (f *LowPass) Next(x float64) float64 {
+	f.y += (x - f.y) * f.k
+	return f.y
+}
+
+// Envelope is a capacitor charging or discharging towards a target level
+// through a resistor, the usual way these circuits shape a sound's volume
+type Envelope struct {
+	Level float64
+
+	sampleRate int
+	target     float64
+	k          float64
+}
+
+func NewEnvelope(sampleRate int) Envelope {
+	return Envelope{sampleRate: sampleRate}
+}
+
+// Charge starts moving towards target with time constant tau seconds (0 jumps
+// straight there)
+func (e *Envelope) Charge(target, tau float64) {
+	e.target, e.k = target, rcCoeff(tau, e.sampleRate)
+}
+
+func (e *Envelope) Next() float64 {
+	e.Level += (e.target - e.Level) * e.k
+	return e.Level
+}
@@ -0,0 +1,74 @@
+package audio
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSN76477Components(t *testing.T) {
+	c := NewSN76477(48000)
+	c.SLFRes, c.SLFCap = 120e3, 1e-6
+	c.VCORes, c.VCOCap = 8.2e3, 0.1e-6
+	if hz := c.SLFHz(); math.Abs(hz-5.333) > 0.01 {
+		t.Fatalf("expected a 5.33 Hz SLF, got %.3f\n", hz)
+	}
+	if hz := c.VCOMaxHz(); math.Abs(hz-780.5) > 0.1 {
+		t.Fatalf("expected the VCO to top out at 780.5 Hz, got %.3f\n", hz)
+	}
+}
+
+// crossings counts rising zero crossings in n samples of next
+func crossings(next func() float64, n int) int {
+	count, last := 0, next()
+	for i := 1; i < n; i++ {
+		v := next()
+		if last <= 0 && v > 0 {
+			count++
+		}
+		last = v
+	}
+	return count
+}
+
+func TestSN76477VCO(t *testing.T) {
+	c := NewSN76477(48000)
+	c.VCORes, c.VCOCap = 8.2e3, 0.1e-6
+	c.VCOControl = sn76477VCOMaxVolts / 2
+	c.Mixer = MixVCO
+	if got := c.Next(); got != 0 {
+		t.Fatalf("expected silence until enabled, got %v\n", got)
+	}
+	c.SetEnable(true)
+	// Half the control voltage, half of 780.5 Hz
+	if n := crossings(c.Next, 48000); n < 389 || n > 391 {
+		t.Fatalf("expected 390 cycles in a second, got %d\n", n)
+	}
+	c.Mixer = MixInhibit
+	if got := c.Next(); got != 0 {
+		t.Fatalf("expected the inhibited mixer to be silent, got %v\n", got)
+	}
+}
+
+func TestEnvelope(t *testing.T) {
+	e := NewEnvelope(1000)
+	e.Level = 1
+	e.Charge(0, 0.01)
+	for i := 0; i < 10; i++ {
+		e.Next()
+	}
+	// One time constant leaves 1/e
+	if math.Abs(e.Level-1/math.E) > 1e-9 {
+		t.Fatalf("expected %.6f after one time constant, got %.6f\n", 1/math.E, e.Level)
+	}
+}
+
+func TestNoise(t *testing.T) {
+	n := NewNoise(8000, 8000)
+	sum := 0.0
+	for i := 0; i < 8000; i++ {
+		sum += n.Next()
+	}
+	if math.Abs(sum) > 400 {
+		t.Fatalf("expected balanced noise, got a sum of %v\n", sum)
+	}
+}
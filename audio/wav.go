@@ -0,0 +1,168 @@
+package audio
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+)
+
+const wavHeaderSize = 44
+
+// WAVWriter streams 16-bit PCM to a WAV file. The sizes in the header are
+// filled in by Close, so the destination must be seekable.
+type WAVWriter struct {
+	w          io.WriteSeeker
+	sampleRate int
+	channels   int
+	dataBytes  uint32
+	buf        []byte
+}
+
+func NewWAVWriter(w io.WriteSeeker, sampleRate, channels int) (*WAVWriter, error) {
+	ww := &WAVWriter{w: w, sampleRate: sampleRate, channels: channels}
+	if err := ww.writeHeader(); err != nil {
+		return nil, err
+	}
+	return ww, nil
+}
+
+// Write appends interleaved samples
+func (w *WAVWriter) Write(samples []int16) error {
+	if cap(w.buf) < 2*len(samples) {
+		w.buf = make([]byte, 2*len(samples))
+	}
+	buf := w.buf[:2*len(samples)]
+	for i, s := range samples {
+		binary.LittleEndian.PutUint16(buf[2*i:], uint16(s))
+	}
+	if _, err := w.w.Write(buf); err != nil {
+		return err
+	}
+	w.dataBytes += uint32(len(buf))
+	return nil
+}
+
+// Samples returns the number of sample frames written
+func (w *WAVWriter) Samples() int {
+	return int(w.dataBytes) / (2 * w.channels)
+}
+
+// Close fills in the header sizes. It does not close the underlying writer.
+func (w *WAVWriter) Close() error {
+	if _, err := w.w.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+	_, err := w.w.Seek(0, io.SeekEnd)
+	return err
+}
+
+func (w *WAVWriter) writeHeader() error {
+	h := make([]byte, wavHeaderSize)
+	copy(h[0:], "RIFF")
+	binary.LittleEndian.PutUint32(h[4:], 36+w.dataBytes)
+	copy(h[8:], "WAVEfmt ")
+	binary.LittleEndian.PutUint32(h[16:], 16) // fmt chunk size
+	binary.LittleEndian.PutUint16(h[20:], 1)  // PCM
+	binary.LittleEndian.PutUint16(h[22:], uint16(w.channels))
+	binary.LittleEndian.PutUint32(h[24:], uint32(w.sampleRate))
+	binary.LittleEndian.PutUint32(h[28:], uint32(w.sampleRate*w.channels*2))
+	binary.LittleEndian.PutUint16(h[32:], uint16(w.channels*2))
+	binary.LittleEndian.PutUint16(h[34:], 16)
+	copy(h[36:], "data")
+	binary.LittleEndian.PutUint32(h[40:], w.dataBytes)
+	_, err := w.w.Write(h)
+	return err
+}
+
+// WAVFile is a WAVWriter that owns its file
+type WAVFile struct {
+	*WAVWriter
+	f *os.File
+}
+
+func CreateWAV(path string, sampleRate, channels int) (*WAVFile, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	w, err := NewWAVWriter(f, sampleRate, channels)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &WAVFile{WAVWriter: w, f: f}, nil
+}
+
+func (w *WAVFile) Close() error {
+	err := w.WAVWriter.Close()
+	if cerr := w.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Sound is decoded PCM audio
+type Sound struct {
+	SampleRate int
+	Channels   int
+	// Samples are interleaved when there is more than one channel
+	Samples []int16
+}
+
+// ReadWAV decodes an uncompressed 8 or 16-bit PCM WAV file
+func ReadWAV(r io.Reader) (*Sound, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 12 || string(data[0:4]) != "RIFF" || string(data[8:12]) != "WAVE" {
+		return nil, errors.New("not a WAV file")
+	}
+	var s Sound
+	var bits int
+	for pos := 12; pos+8 <= len(data); {
+		id := string(data[pos : pos+4])
+		size := int(binary.LittleEndian.Uint32(data[pos+4:]))
+		body := data[pos+8:]
+		if size > len(body) {
+			// Tolerate truncated data chunks
+			size = len(body)
+		}
+		body = body[:size]
+		switch id {
+		case "fmt ":
+			if size < 16 {
+				return nil, errors.New("WAV fmt chunk too short")
+			}
+			if format := binary.LittleEndian.Uint16(body[0:]); format != 1 {
+				return nil, errors.New("WAV is not uncompressed PCM")
+			}
+			s.Channels = int(binary.LittleEndian.Uint16(body[2:]))
+			s.SampleRate = int(binary.LittleEndian.Uint32(body[4:]))
+			bits = int(binary.LittleEndian.Uint16(body[14:]))
+		case "data":
+			switch bits {
+			case 8:
+				s.Samples = make([]int16, size)
+				for i, b := range body {
+					s.Samples[i] = int16(int(b)-128) << 8
+				}
+			case 16:
+				s.Samples = make([]int16, size/2)
+				for i := range s.Samples {
+					s.Samples[i] = int16(binary.LittleEndian.Uint16(body[2*i:]))
+				}
+			default:
+				return nil, errors.New("WAV data before fmt, or unsupported sample size")
+			}
+			return &s, nil
+		}
+		// Chunks are padded to even sizes
+		pos += 8 + size + size&1
+	}
+	return nil, errors.New("WAV has no data chunk")
+}
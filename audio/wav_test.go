@@ -0,0 +1,43 @@
+package audio
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestWAVRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.wav")
+	w, err := CreateWAV(path, 22050, 1)
+	if err != nil {
+		t.Fatalf("CreateWAV failed: %v\n", err)
+	}
+	want := []int16{0, 1, -1, 32767, -32768, 1234}
+	if err := w.Write(want[:3]); err != nil {
+		t.Fatalf("Write failed: %v\n", err)
+	}
+	_ = w.Write(want[3:])
+	if w.Samples() != len(want) {
+		t.Fatalf("expected %d samples, got %d\n", len(want), w.Samples())
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v\n", err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Open failed: %v\n", err)
+	}
+	defer f.Close()
+	s, err := ReadWAV(f)
+	if err != nil {
+		t.Fatalf("ReadWAV failed: %v\n", err)
+	}
+	if s.SampleRate != 22050 || s.Channels != 1 || !reflect.DeepEqual(s.Samples, want) {
+		t.Fatalf("unexpected sound: %d Hz, %d channels, %v\n", s.SampleRate, s.Channels, s.Samples)
+	}
+	if info, _ := f.Stat(); info.Size() != wavHeaderSize+2*int64(len(want)) {
+		t.Fatalf("unexpected file size %d\n", info.Size())
+	}
+}
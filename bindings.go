@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+
+	"intel8080/input"
+	"intel8080/machine"
+)
+
+// loadKeyMap resolves -keymap for the SDL window or the terminal
+func loadKeyMap(terminal bool) (*input.Map, error) {
+	var config *input.Config
+	if *keymapPath != "" {
+		var err error
+		if config, err = input.LoadConfig(*keymapPath); err != nil {
+			return nil, err
+		}
+	}
+	keys, err := config.Resolve(terminal)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", *keymapPath, err)
+	}
+	return keys, nil
+}
+
+// saveState writes the machine state to -state
+func saveState(m *machine.Machine) {
+	if err := machine.WriteStateFile(*statePath, m.Snapshot()); err != nil {
+		fmt.Printf("save-state failed: %v\n", err)
+		return
+	}
+	fmt.Printf("state saved to %s\n", *statePath)
+}
+
+// loadState restores the machine state saved in -state. A movie can't
+// follow the machine jumping to another state, so not while recording one.
+func loadState(m *machine.Machine) {
+	if *recordMovie != "" {
+		fmt.Println("load-state is not available while recording a movie")
+		return
+	}
+	s, err := machine.ReadStateFile(*statePath)
+	if err == nil {
+		err = m.Restore(s)
+	}
+	if err != nil {
+		fmt.Printf("load-state failed: %v\n", err)
+		return
+	}
+	fmt.Printf("state loaded from %s\n", *statePath)
+}
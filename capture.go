@@ -0,0 +1,179 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"intel8080/audio"
+	"intel8080/display"
+	"intel8080/machine"
+)
+
+// capture writes screenshots and GIF recordings for every front-end. It is
+// fed each frame after it is drawn.
+type capture struct {
+	pngAt   map[uint64]bool
+	pngLast bool
+
+	gif     *display.GIFRecorder
+	gifPath string
+
+	av      *machine.AVRecorder
+	y4mFile *os.File
+	wavFile *audio.WAVFile
+}
+
+func newCapture(m *machine.Machine) (*capture, error) {
+	c := &capture{pngAt: map[uint64]bool{}}
+	// -png-at defaults to "end" for headless runs, but interactive runs only
+	// write frames when asked to
+	if (*headless || flagSet("png-at")) && *pngAt != "" {
+		for _, f := range strings.Split(*pngAt, ",") {
+			if f == "end" {
+				c.pngLast = true
+				continue
+			}
+			n, err := strconv.ParseUint(f, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("bad -png-at frame %q", f)
+			}
+			c.pngAt[n] = true
+		}
+	}
+	if *gifPath != "" {
+		c.startGIF(*gifPath)
+	}
+	if err := c.startAV(m.ScreenAspect()); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// startAV opens the -y4m and -wav recordings
+func (c *capture) startAV(aspect float64) error {
+	if *y4mPath == "" && *wavPath == "" {
+		return nil
+	}
+	var err error
+	if *y4mPath != "" {
+		if c.y4mFile, err = os.Create(*y4mPath); err != nil {
+			return err
+		}
+	}
+	var wav *audio.WAVWriter
+	if *wavPath != "" {
+		if c.wavFile, err = audio.CreateWAV(*wavPath, audio.DefaultSampleRate, 1); err != nil {
+			return err
+		}
+		wav = c.wavFile.WAVWriter
+	}
+	if c.y4mFile != nil {
+		c.av = machine.NewAVRecorder(c.y4mFile, wav, audio.DefaultSampleRate, aspect)
+	} else {
+		c.av = machine.NewAVRecorder(nil, wav, audio.DefaultSampleRate, aspect)
+	}
+	return nil
+}
+
+// recording reports whether every frame is needed
+func (c *capture) recording() bool {
+	return c.gif != nil || c.av != nil
+}
+
+// frame is called after each emulated frame is drawn into fb, with the
+// frame's audio
+func (c *capture) frame(frame uint64, fb *display.Framebuffer, samples []int16) error {
+	if c.gif != nil {
+		c.gif.AddFrame(fb)
+	}
+	if c.av != nil {
+		if err := c.av.AddFrame(fb, samples); err != nil {
+			return err
+		}
+	}
+	if c.pngAt[frame] {
+		return c.writePNG(frame, fb)
+	}
+	return nil
+}
+
+func (c *capture) writePNG(frame uint64, fb *display.Framebuffer) error {
+	path := *pngPath
+	if strings.Contains(path, "%") {
+		path = fmt.Sprintf(path, frame)
+	}
+	fmt.Printf("frame %d -> %s\n", frame, path)
+	return display.SavePNG(path, fb)
+}
+
+// screenshot saves fb to a timestamped PNG in -capture-dir
+func (c *capture) screenshot(frame uint64, fb *display.Framebuffer) {
+	path := filepath.Join(*captureDir, fmt.Sprintf("invaders-%s-%d.png", time.Now().Format("20060102-150405"), frame))
+	if err := display.SavePNG(path, fb); err != nil {
+		fmt.Printf("Screenshot failed: %v\n", err)
+		return
+	}
+	fmt.Printf("Screenshot saved to %s\n", path)
+}
+
+// toggleGIF starts recording to a timestamped GIF in -capture-dir, or stops and saves the recording
+func (c *capture) toggleGIF() {
+	if c.gif != nil {
+		c.stopGIF()
+		return
+	}
+	c.startGIF(filepath.Join(*captureDir, fmt.Sprintf("invaders-%s.gif", time.Now().Format("20060102-150405"))))
+	fmt.Printf("Recording GIF to %s\n", c.gifPath)
+}
+
+func (c *capture) startGIF(path string) {
+	c.gif = display.NewGIFRecorder(machine.RefreshRate)
+	c.gif.Skip = *gifSkip
+	c.gifPath = path
+}
+
+func (c *capture) stopGIF() {
+	if err := c.gif.Save(c.gifPath); err != nil {
+		fmt.Printf("Saving GIF failed: %v\n", err)
+	} else {
+		fmt.Printf("GIF saved to %s (%d frames)\n", c.gifPath, c.gif.Frames())
+	}
+	c.gif = nil
+}
+
+// close writes the last frame if requested and finishes any GIF recording
+func (c *capture) close(frame uint64, fb *display.Framebuffer) error {
+	if c.gif != nil {
+		c.stopGIF()
+	}
+	if c.av != nil {
+		if err := c.stopAV(); err != nil {
+			return err
+		}
+	}
+	if c.pngLast && !c.pngAt[frame] {
+		return c.writePNG(frame, fb)
+	}
+	return nil
+}
+
+func (c *capture) stopAV() error {
+	err := c.av.Flush()
+	if c.y4mFile != nil {
+		if cerr := c.y4mFile.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if c.wavFile != nil {
+		if cerr := c.wavFile.Close(); err == nil {
+			err = cerr
+		}
+	}
+	fmt.Printf("Recorded %d frames\n", c.av.Frames())
+	c.av = nil
+	return err
+}
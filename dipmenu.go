@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"intel8080/machine"
+)
+
+// dipMenu changes the DIP switches while the game runs, printing to the
+// console. The game reads the switches when a game starts, so they can only
+// be changed between credits.
+type dipMenu struct {
+	selected int
+	open     bool
+}
+
+// next shows the menu, or selects the next switch if it is showing
+func (d *dipMenu) next(m *machine.Machine) {
+	if d.open {
+		d.selected = (d.selected + 1) % len(machine.DIPSwitches)
+	}
+	d.open = true
+	d.print(m)
+}
+
+// change steps the selected switch to its next setting
+func (d *dipMenu) change(m *machine.Machine) {
+	if !d.open {
+		d.next(m)
+		return
+	}
+	if m.InGame() {
+		fmt.Println("DIP switches can only be changed between credits")
+		return
+	}
+	sw := &machine.DIPSwitches[d.selected]
+	settings := sw.Settings()
+	i := 0
+	for j, s := range settings {
+		if s == m.DIPSwitch(sw.Name) {
+			i = j + 1
+		}
+	}
+	_ = m.SetDIPSwitches(machine.DIPSettings{sw.Name: settings[i%len(settings)]})
+	d.print(m)
+}
+
+func (d *dipMenu) print(m *machine.Machine) {
+	fmt.Println("DIP switches (dip-menu selects, dip-change changes):")
+	for i, sw := range machine.DIPSwitches {
+		cursor := " "
+		if i == d.selected {
+			cursor = ">"
+		}
+		fmt.Printf("%s %-9s = %-4s [%s] %s\n", cursor, sw.Name, m.DIPSwitch(sw.Name),
+			strings.Join(sw.Settings(), " "), sw.Help)
+	}
+}
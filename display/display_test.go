@@ -0,0 +1,34 @@
+package display
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestRenderers(t *testing.T) {
+	fb := NewFramebuffer(4, 8)
+	fb.Set(0, 0, 0xffffffff)
+	fb.Set(3, 7, 0xff00ff00)
+
+	a, b := NewImageRenderer(), NewImageRenderer()
+	if err := a.Render(fb); err != nil {
+		t.Fatalf("Render failed: %v\n", err)
+	}
+	fb.Set(0, 0, 0xff000000)
+	_ = b.Render(fb)
+	if a.Frame().At(0, 0) != 0xffffffff || b.Frame().At(0, 0) != 0xff000000 || a.Frames != 1 {
+		t.Fatalf("image renderers should keep independent copies of their frames\n")
+	}
+	if c := a.Frame().Image().RGBAAt(3, 7); c.G != 0xff || c.R != 0 || c.A != 0xff {
+		t.Fatalf("unexpected RGBA conversion: %v\n", c)
+	}
+
+	var out bytes.Buffer
+	term := NewTerminalRenderer(&out, TerminalASCII)
+	term.Mono = true
+	_ = term.Render(fb)
+	want := "\x1b[2J\x1b[?25l\x1b[H  \r\n #\r\n"
+	if out.String() != want {
+		t.Fatalf("expected %q, got %q\n", want, out.String())
+	}
+}
@@ -0,0 +1,340 @@
+package display
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Filter post-processes frames between the machine and a renderer. Filters
+// may change the frame size and may keep state between frames.
+type Filter interface {
+	// Apply returns the filtered frame. It must not modify src, and the
+	// result is only valid until the next call.
+	Apply(src *Framebuffer) *Framebuffer
+	// Reset forgets any state carried between frames
+	Reset()
+}
+
+// Pipeline applies filters in order
+type Pipeline []Filter
+
+func (p Pipeline) Apply(fb *Framebuffer) *Framebuffer {
+	for _, f := range p {
+		fb = f.Apply(fb)
+	}
+	return fb
+}
+
+func (p Pipeline) Reset() {
+	for _, f := range p {
+		f.Reset()
+	}
+}
+
+// filterFactories build filters from an optional numeric parameter
+var filterFactories = map[string]struct {
+	defaultParam float64
+	build        func(param float64) Filter
+}{
+	"persistence": {0.6, func(p float64) Filter { return &Persistence{Decay: p} }},
+	"scanlines":   {0.5, func(p float64) Filter { return &Scanlines{Intensity: p} }},
+	"vscanlines":  {0.5, func(p float64) Filter { return &Scanlines{Intensity: p, Vertical: true} }},
+	"bloom":       {0.5, func(p float64) Filter { return &Bloom{Strength: p} }},
+	"tint":        {0xd8e8ff, func(p float64) Filter { return &Tint{Color: 0xff000000 | uint32(p)} }},
+	"scale2x":     {0, func(p float64) Filter { return &ScaleNx{N: 2} }},
+	"scale3x":     {0, func(p float64) Filter { return &ScaleNx{N: 3} }},
+}
+
+// ParseFilters builds a pipeline from a comma separated list of filters, each
+// optionally followed by :param. An empty spec gives an empty pipeline.
+//
+//	persistence[:decay]     phosphor afterglow, decay per frame (0-1)
+//	scanlines[:intensity]   dark lines between rows, doubling the height
+//	vscanlines[:intensity]  the same between columns, for rotated monitors
+//	bloom[:strength]        glow around lit pixels
+//	tint[:rrggbb]           phosphor colour (hex)
+//	scale2x, scale3x        pixel-art scalers
+func ParseFilters(spec string) (Pipeline, error) {
+	var p Pipeline
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, arg := item, ""
+		if i := strings.IndexByte(item, ':'); i >= 0 {
+			name, arg = item[:i], item[i+1:]
+		}
+		factory, ok := filterFactories[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown filter %q", name)
+		}
+		param := factory.defaultParam
+		if arg != "" {
+			var err error
+			if name == "tint" {
+				var c uint64
+				c, err = strconv.ParseUint(arg, 16, 32)
+				param = float64(c)
+			} else {
+				param, err = strconv.ParseFloat(arg, 64)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("bad parameter for filter %s: %q", name, arg)
+			}
+		}
+		p = append(p, factory.build(param))
+	}
+	return p, nil
+}
+
+// output reuses buf when it has the right size
+func output(buf **Framebuffer, width, height int) *Framebuffer {
+	if *buf == nil || (*buf).Width != width || (*buf).Height != height {
+		*buf = NewFramebuffer(width, height)
+	}
+	return *buf
+}
+
+// Persistence emulates phosphor afterglow: a pixel fades by Decay per frame
+// instead of going dark at once, so sprites drawn on alternate frames (the
+// Invaders shots) stop flickering
+type Persistence struct {
+	Decay float64
+	out   *Framebuffer
+	prev  []uint32
+}
+
+func (f *Persistence) Apply(src *Framebuffer) *Framebuffer {
+	out := output(&f.out, src.Width, src.Height)
+	if len(f.prev) != len(src.Pix) {
+		f.prev = make([]uint32, len(src.Pix))
+	}
+	decay := uint32(f.Decay * 256)
+	for i, c := range src.Pix {
+		p := f.prev[i]
+		var r uint32 = 0xff000000
+		for shift := uint(0); shift < 24; shift += 8 {
+			cur, faded := (c>>shift)&0xff, ((p>>shift)&0xff)*decay/256
+			if faded > cur {
+				cur = faded
+			}
+			r |= cur << shift
+		}
+		out.Pix[i] = r
+		f.prev[i] = r
+	}
+	return out
+}
+
+func (f *Persistence) Reset() {
+	f.prev = nil
+}
+
+// Scanlines doubles the frame across the beam's path and darkens every other
+// line by Intensity (0-1), leaving the gaps between scanlines visible when
+// scaled up. Vertical is for monitors mounted on their side.
+type Scanlines struct {
+	Intensity float64
+	Vertical  bool
+	out       *Framebuffer
+}
+
+func (f *Scanlines) Apply(src *Framebuffer) *Framebuffer {
+	keep := uint32((1 - f.Intensity) * 256)
+	if f.Vertical {
+		out := output(&f.out, src.Width*2, src.Height)
+		for y := 0; y < src.Height; y++ {
+			for x := 0; x < src.Width; x++ {
+				c := src.At(x, y)
+				out.Set(2*x, y, c)
+				out.Set(2*x+1, y, scale(c, keep))
+			}
+		}
+		return out
+	}
+	out := output(&f.out, src.Width, src.Height*2)
+	for y := 0; y < src.Height; y++ {
+		for x := 0; x < src.Width; x++ {
+			c := src.At(x, y)
+			out.Set(x, 2*y, c)
+			out.Set(x, 2*y+1, scale(c, keep))
+		}
+	}
+	return out
+}
+
+func (f *Scanlines) Reset() {}
+
+// Bloom adds a blurred copy of the frame, scaled by Strength, so lit pixels glow
+type Bloom struct {
+	Strength float64
+	out      *Framebuffer
+	tmp      []uint32
+}
+
+// bloomRadius is the half-width of the box blur, in pixels
+const bloomRadius = 2
+
+func (f *Bloom) Apply(src *Framebuffer) *Framebuffer {
+	out := output(&f.out, src.Width, src.Height)
+	if len(f.tmp) != len(src.Pix) {
+		f.tmp = make([]uint32, len(src.Pix))
+	}
+	w, h := src.Width, src.Height
+	// Separable box blur: horizontal into tmp, then vertical into out
+	boxBlur(f.tmp, src.Pix, w, h, 1, w)
+	boxBlur(out.Pix, f.tmp, h, w, w, 1)
+	strength := uint32(f.Strength * 256)
+	for i, c := range src.Pix {
+		out.Pix[i] = add(c, scale(out.Pix[i], strength))
+	}
+	return out
+}
+
+func (f *Bloom) Reset() {}
+
+// boxBlur blurs lines of n pixels, step apart, with lines stride apart
+func boxBlur(dst, src []uint32, n, lines, step, stride int) {
+	const width = 2*bloomRadius + 1
+	for l := 0; l < lines; l++ {
+		base := l * stride
+		for i := 0; i < n; i++ {
+			var r, g, b uint32
+			for k := i - bloomRadius; k <= i+bloomRadius; k++ {
+				if k < 0 || k >= n {
+					continue
+				}
+				c := src[base+k*step]
+				r += (c >> 16) & 0xff
+				g += (c >> 8) & 0xff
+				b += c & 0xff
+			}
+			dst[base+i*step] = 0xff000000 | (r/width)<<16 | (g/width)<<8 | b/width
+		}
+	}
+}
+
+// Tint multiplies every pixel by a phosphor colour
+type Tint struct {
+	Color uint32
+	out   *Framebuffer
+}
+
+func (f *Tint) Apply(src *Framebuffer) *Framebuffer {
+	out := output(&f.out, src.Width, src.Height)
+	for i, c := range src.Pix {
+		out.Pix[i] = multiply(c, f.Color)
+	}
+	return out
+}
+
+func (f *Tint) Reset() {}
+
+// ScaleNx is the Scale2x / Scale3x (AdvMAME) pixel-art scaler: edges are
+// smoothed by copying neighbours only where they agree, so no new colours
+// appear
+type ScaleNx struct {
+	N   int
+	out *Framebuffer
+}
+
+func (f *ScaleNx) Apply(src *Framebuffer) *Framebuffer {
+	if f.N == 3 {
+		return f.scale3x(src)
+	}
+	return f.scale2x(src)
+}
+
+func (f *ScaleNx) Reset() {}
+
+// at returns the pixel at x, y, clamped to the edges
+func at(fb *Framebuffer, x, y int) uint32 {
+	return fb.At(clamp(x, 0, fb.Width-1), clamp(y, 0, fb.Height-1))
+}
+
+func (f *ScaleNx) scale2x(src *Framebuffer) *Framebuffer {
+	out := output(&f.out, src.Width*2, src.Height*2)
+	for y := 0; y < src.Height; y++ {
+		for x := 0; x < src.Width; x++ {
+			p := src.At(x, y)
+			a, b, c, d := at(src, x, y-1), at(src, x+1, y), at(src, x-1, y), at(src, x, y+1)
+			e0, e1, e2, e3 := p, p, p, p
+			if c == a && c != d && a != b {
+				e0 = a
+			}
+			if a == b && a != c && b != d {
+				e1 = b
+			}
+			if d == c && d != b && c != a {
+				e2 = c
+			}
+			if b == d && b != a && d != c {
+				e3 = d
+			}
+			out.Set(2*x, 2*y, e0)
+			out.Set(2*x+1, 2*y, e1)
+			out.Set(2*x, 2*y+1, e2)
+			out.Set(2*x+1, 2*y+1, e3)
+		}
+	}
+	return out
+}
+
+func (f *ScaleNx) scale3x(src *Framebuffer) *Framebuffer {
+	out := output(&f.out, src.Width*3, src.Height*3)
+	for y := 0; y < src.Height; y++ {
+		for x := 0; x < src.Width; x++ {
+			// a b c
+			// d e f
+			// g h i
+			a, b, c := at(src, x-1, y-1), at(src, x, y-1), at(src, x+1, y-1)
+			d, e, ff := at(src, x-1, y), src.At(x, y), at(src, x+1, y)
+			g, h, i := at(src, x-1, y+1), at(src, x, y+1), at(src, x+1, y+1)
+			px := [9]uint32{e, e, e, e, e, e, e, e, e}
+			if b != h && d != ff {
+				if d == b {
+					px[0] = d
+				}
+				if (d == b && e != c) || (b == ff && e != a) {
+					px[1] = b
+				}
+				if b == ff {
+					px[2] = ff
+				}
+				if (d == b && e != g) || (d == h && e != a) {
+					px[3] = d
+				}
+				if (b == ff && e != i) || (h == ff && e != c) {
+					px[5] = ff
+				}
+				if d == h {
+					px[6] = d
+				}
+				if (d == h && e != i) || (h == ff && e != g) {
+					px[7] = h
+				}
+				if h == ff {
+					px[8] = ff
+				}
+			}
+			for k, v := range px {
+				out.Set(3*x+k%3, 3*y+k/3, v)
+			}
+		}
+	}
+	return out
+}
+
+// scale multiplies each channel of c by s/256
+func scale(c uint32, s uint32) uint32 {
+	ch := func(shift uint) uint32 {
+		v := ((c >> shift) & 0xff) * s / 256
+		if v > 0xff {
+			v = 0xff
+		}
+		return v
+	}
+	return 0xff000000 | ch(16)<<16 | ch(8)<<8 | ch(0)
+}
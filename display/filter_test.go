@@ -0,0 +1,127 @@
+package display
+
+import (
+	"flag"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite golden images")
+
+func TestPersistence(t *testing.T) {
+	f := &Persistence{Decay: 0.5}
+	fb := NewFramebuffer(1, 1)
+	fb.Set(0, 0, 0xffff8000)
+	f.Apply(fb)
+	fb.Set(0, 0, 0xff000000)
+	if got := f.Apply(fb).At(0, 0); got != 0xff7f4000 {
+		t.Fatalf("expected the pixel to fade to half, got %08x\n", got)
+	}
+	if got := f.Apply(fb).At(0, 0); got != 0xff3f2000 {
+		t.Fatalf("expected the pixel to fade to a quarter, got %08x\n", got)
+	}
+	f.Reset()
+	if got := f.Apply(fb).At(0, 0); got != 0xff000000 {
+		t.Fatalf("expected Reset to clear the afterglow, got %08x\n", got)
+	}
+}
+
+func TestScanlines(t *testing.T) {
+	fb := NewFramebuffer(2, 1)
+	fb.Clear(0xffffffff)
+	out := (&Scanlines{Intensity: 0.5}).Apply(fb)
+	if out.Width != 2 || out.Height != 2 || out.At(1, 0) != 0xffffffff || out.At(1, 1) != 0xff7f7f7f {
+		t.Fatalf("unexpected horizontal scanlines: %dx%d %08x\n", out.Width, out.Height, out.Pix)
+	}
+	out = (&Scanlines{Intensity: 1, Vertical: true}).Apply(fb)
+	if out.Width != 4 || out.Height != 1 || out.At(2, 0) != 0xffffffff || out.At(3, 0) != 0xff000000 {
+		t.Fatalf("unexpected vertical scanlines: %dx%d %08x\n", out.Width, out.Height, out.Pix)
+	}
+}
+
+func TestScale2x(t *testing.T) {
+	// A diagonal edge gets smoothed; isolated pixels stay square
+	fb := NewFramebuffer(2, 2)
+	fb.Clear(0xff000000)
+	fb.Set(0, 0, 0xffffffff)
+	fb.Set(1, 0, 0xffffffff)
+	fb.Set(0, 1, 0xffffffff)
+	out := (&ScaleNx{N: 2}).Apply(fb)
+	// The black pixel's top left corner sits between two white neighbours
+	if out.Width != 4 || out.At(2, 2) != 0xffffffff || out.At(3, 3) != 0xff000000 || out.At(3, 2) != 0xff000000 {
+		t.Fatalf("unexpected Scale2x output %08x\n", out.Pix)
+	}
+	if out3 := (&ScaleNx{N: 3}).Apply(fb); out3.Width != 6 || out3.At(3, 3) != 0xffffffff || out3.At(5, 5) != 0xff000000 {
+		t.Fatalf("unexpected Scale3x output %08x\n", out3.Pix)
+	}
+}
+
+func TestParseFilters(t *testing.T) {
+	p, err := ParseFilters("persistence:0.8, vscanlines, tint:ff8000, scale2x")
+	if err != nil || len(p) != 4 {
+		t.Fatalf("ParseFilters failed: %v, %v\n", p, err)
+	}
+	if f := p[0].(*Persistence); f.Decay != 0.8 {
+		t.Errorf("expected decay 0.8, got %v\n", f.Decay)
+	}
+	if f := p[1].(*Scanlines); !f.Vertical || f.Intensity != 0.5 {
+		t.Errorf("unexpected scanlines %+v\n", f)
+	}
+	if f := p[2].(*Tint); f.Color != 0xffff8000 {
+		t.Errorf("expected tint ffff8000, got %08x\n", f.Color)
+	}
+	for _, bad := range []string{"blur", "bloom:x", "tint:zz"} {
+		if _, err := ParseFilters(bad); err == nil {
+			t.Errorf("expected error for %q\n", bad)
+		}
+	}
+	if p, err := ParseFilters(""); err != nil || len(p) != 0 {
+		t.Errorf("expected an empty pipeline, got %v, %v\n", p, err)
+	}
+}
+
+// TestFilterGolden runs a full chain over a test pattern and compares it with
+// testdata/filters.png. Run with -update to rewrite the golden image after an
+// intended change.
+func TestFilterGolden(t *testing.T) {
+	fb := NewFramebuffer(16, 16)
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 16; x++ {
+			if (x+y)%5 == 0 || x == 8 {
+				fb.Set(x, y, 0xffffffff)
+			} else {
+				fb.Set(x, y, 0xff000000)
+			}
+		}
+	}
+	fb.Set(3, 12, 0xffff0000)
+
+	p, err := ParseFilters("persistence,tint,bloom,vscanlines,scale2x")
+	if err != nil {
+		t.Fatalf("ParseFilters failed: %v\n", err)
+	}
+	p.Apply(fb)
+	// Second frame with the diagonal gone, so persistence shows
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 16; x++ {
+			if x != 8 {
+				fb.Set(x, y, 0xff000000)
+			}
+		}
+	}
+	out := p.Apply(fb)
+
+	golden := filepath.Join("testdata", "filters.png")
+	if *update {
+		if err := SavePNG(golden, out); err != nil {
+			t.Fatalf("SavePNG failed: %v\n", err)
+		}
+	}
+	want, err := LoadPNG(golden)
+	if err != nil {
+		t.Fatalf("LoadPNG failed: %v\n", err)
+	}
+	if n := Diff(out, want); n != 0 {
+		t.Fatalf("output differs from %s in %d pixels (run with -update if intended)\n", golden, n)
+	}
+}
@@ -0,0 +1,59 @@
+package display
+
+import (
+	"image"
+	"image/color"
+)
+
+// Framebuffer is a machine-agnostic image handed to renderers. Pixels are
+// 0xAARRGGBB, stored row by row from the top left.
+type Framebuffer struct {
+	Width  int
+	Height int
+	Pix    []uint32
+}
+
+func NewFramebuffer(width, height int) *Framebuffer {
+	return &Framebuffer{
+		Width:  width,
+		Height: height,
+		Pix:    make([]uint32, width*height),
+	}
+}
+
+func (fb *Framebuffer) At(x, y int) uint32 {
+	return fb.Pix[y*fb.Width+x]
+}
+
+func (fb *Framebuffer) Set(x, y int, c uint32) {
+	fb.Pix[y*fb.Width+x] = c
+}
+
+// Clear fills the framebuffer with c
+func (fb *Framebuffer) Clear(c uint32) {
+	for i := range fb.Pix {
+		fb.Pix[i] = c
+	}
+}
+
+// Copy returns a deep copy of the framebuffer
+func (fb *Framebuffer) Copy() *Framebuffer {
+	c := NewFramebuffer(fb.Width, fb.Height)
+	copy(c.Pix, fb.Pix)
+	return c
+}
+
+// Image converts the framebuffer to an RGBA image
+func (fb *Framebuffer) Image() *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, fb.Width, fb.Height))
+	for y := 0; y < fb.Height; y++ {
+		for x := 0; x < fb.Width; x++ {
+			img.SetRGBA(x, y, ARGBToRGBA(fb.At(x, y)))
+		}
+	}
+	return img
+}
+
+func ARGBToRGBA(c uint32) color.RGBA {
+	return color.RGBA{R: uint8(c >> 16), G: uint8(c >> 8), B: uint8(c), A: uint8(c >> 24)}
+}
@@ -0,0 +1,129 @@
+package display
+
+import (
+	"image"
+	"image/color"
+	"image/color/palette"
+	"image/draw"
+	"image/gif"
+	"io"
+	"math"
+	"os"
+)
+
+// GIFRecorder collects frames into an animated GIF timed by emulated frames,
+// not the wall clock, so recordings play at the right speed however fast the
+// emulator ran. Frames are kept in memory until Encode.
+type GIFRecorder struct {
+	frameRate float64
+	// Skip records every Skip-th frame. GIF delays are whole hundredths of a
+	// second and browsers slow down delays under 2, so at ~60 Hz every
+	// second frame (the default) gives the smoothest playback.
+	Skip int
+
+	anim    gif.GIF
+	frames  uint64 // emulated frames seen
+	starts  []uint64
+	lastPix []uint32
+}
+
+func NewGIFRecorder(frameRate float64) *GIFRecorder {
+	return &GIFRecorder{frameRate: frameRate, Skip: 2}
+}
+
+// AddFrame is called once per emulated frame
+func (g *GIFRecorder) AddFrame(fb *Framebuffer) {
+	n := g.frames
+	g.frames++
+	skip := uint64(g.Skip)
+	if skip == 0 {
+		skip = 1
+	}
+	if n%skip != 0 {
+		return
+	}
+	// Unchanged frames just extend the previous one. All frames must be the
+	// size of the first, so frames resized mid-recording are skipped.
+	if g.lastPix != nil && equalPix(g.lastPix, fb.Pix) {
+		return
+	}
+	if len(g.anim.Image) > 0 && (fb.Width != g.anim.Config.Width || fb.Height != g.anim.Config.Height) {
+		return
+	}
+	g.lastPix = append(g.lastPix[:0], fb.Pix...)
+	g.anim.Image = append(g.anim.Image, paletted(fb))
+	g.starts = append(g.starts, n)
+	g.anim.Config.Width, g.anim.Config.Height = fb.Width, fb.Height
+}
+
+// Frames returns the number of distinct images recorded
+func (g *GIFRecorder) Frames() int {
+	return len(g.anim.Image)
+}
+
+// Encode writes the animation, looping forever
+func (g *GIFRecorder) Encode(w io.Writer) error {
+	g.anim.Delay = g.anim.Delay[:0]
+	for i, start := range g.starts {
+		end := g.frames
+		if i+1 < len(g.starts) {
+			end = g.starts[i+1]
+		}
+		// Delays are rounded from absolute times so errors don't accumulate
+		g.anim.Delay = append(g.anim.Delay, g.centiseconds(end)-g.centiseconds(start))
+	}
+	return gif.EncodeAll(w, &g.anim)
+}
+
+func (g *GIFRecorder) Save(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := g.Encode(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (g *GIFRecorder) centiseconds(frame uint64) int {
+	return int(math.Round(float64(frame) * 100 / g.frameRate))
+}
+
+// paletted converts a frame using its exact colours when there are at most
+// 256 of them, and dithers to the Plan 9 palette otherwise
+func paletted(fb *Framebuffer) *image.Paletted {
+	bounds := image.Rect(0, 0, fb.Width, fb.Height)
+	index := map[uint32]uint8{}
+	var pal color.Palette
+	for _, c := range fb.Pix {
+		if _, ok := index[c]; ok {
+			continue
+		}
+		if len(pal) == 256 {
+			img := image.NewPaletted(bounds, palette.Plan9)
+			draw.FloydSteinberg.Draw(img, bounds, fb.Image(), image.Point{})
+			return img
+		}
+		index[c] = uint8(len(pal))
+		pal = append(pal, ARGBToRGBA(c))
+	}
+	img := image.NewPaletted(bounds, pal)
+	for i, c := range fb.Pix {
+		img.Pix[i] = index[c]
+	}
+	return img
+}
+
+func equalPix(a, b []uint32) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
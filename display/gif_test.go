@@ -0,0 +1,39 @@
+package display
+
+import (
+	"bytes"
+	"image/gif"
+	"reflect"
+	"testing"
+)
+
+func TestGIFRecorder(t *testing.T) {
+	fb := NewFramebuffer(8, 8)
+	g := NewGIFRecorder(60)
+	for i := 0; i < 12; i++ {
+		// Changes every 4 frames, so pairs of recorded frames are identical
+		fb.Clear(0xff000000)
+		fb.Set(i/4, 0, 0xff00ff00)
+		g.AddFrame(fb)
+	}
+	if g.Frames() != 3 {
+		t.Fatalf("expected 3 distinct frames, got %d\n", g.Frames())
+	}
+
+	var buf bytes.Buffer
+	if err := g.Encode(&buf); err != nil {
+		t.Fatalf("Encode failed: %v\n", err)
+	}
+	anim, err := gif.DecodeAll(&buf)
+	if err != nil {
+		t.Fatalf("DecodeAll failed: %v\n", err)
+	}
+	// 4 frames at 60 Hz is 6.67cs, rounded from absolute times: 7, 6, 7
+	if !reflect.DeepEqual(anim.Delay, []int{7, 6, 7}) {
+		t.Fatalf("unexpected delays %v\n", anim.Delay)
+	}
+	r, gr, b, _ := anim.Image[2].At(2, 0).RGBA()
+	if r != 0 || gr != 0xffff || b != 0 {
+		t.Fatalf("expected exact green in the last frame, got %x %x %x\n", r, gr, b)
+	}
+}
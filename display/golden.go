@@ -0,0 +1,38 @@
+package display
+
+import (
+	"image"
+	"image/draw"
+)
+
+// LoadPNG reads a PNG file into a framebuffer
+func LoadPNG(path string) (*Framebuffer, error) {
+	img, err := loadPNG(path)
+	if err != nil {
+		return nil, err
+	}
+	b := img.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(rgba, rgba.Bounds(), img, b.Min, draw.Src)
+	fb := NewFramebuffer(b.Dx(), b.Dy())
+	for i := range fb.Pix {
+		p := rgba.Pix[4*i : 4*i+4]
+		fb.Pix[i] = uint32(p[3])<<24 | uint32(p[0])<<16 | uint32(p[1])<<8 | uint32(p[2])
+	}
+	return fb, nil
+}
+
+// Diff returns the number of pixels that differ between a and b, or -1 if
+// their sizes differ. It is meant for golden image tests.
+func Diff(a, b *Framebuffer) int {
+	if a.Width != b.Width || a.Height != b.Height {
+		return -1
+	}
+	n := 0
+	for i := range a.Pix {
+		if a.Pix[i] != b.Pix[i] {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,70 @@
+package display
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Orientation is how a machine's raw picture is turned to face the viewer:
+// rotated clockwise by Rotate degrees (0, 90, 180 or 270), then mirrored
+// left to right if Mirror is set
+type Orientation struct {
+	Rotate int
+	Mirror bool
+}
+
+// ParseOrientation parses degrees with an optional "m" suffix for mirroring,
+// such as "270" or "0m"
+func ParseOrientation(s string) (Orientation, error) {
+	var o Orientation
+	if strings.HasSuffix(s, "m") {
+		o.Mirror = true
+		s = strings.TrimSuffix(s, "m")
+	}
+	deg, err := strconv.Atoi(s)
+	if err != nil || deg%90 != 0 || deg < 0 || deg >= 360 {
+		return Orientation{}, fmt.Errorf("bad orientation %q (want 0, 90, 180 or 270, optionally followed by m)", s)
+	}
+	o.Rotate = deg
+	return o, nil
+}
+
+func (o Orientation) String() string {
+	if o.Mirror {
+		return fmt.Sprintf("%dm", o.Rotate)
+	}
+	return strconv.Itoa(o.Rotate)
+}
+
+// Flipped returns the orientation turned a further 180 degrees, as cocktail
+// cabinets do for the second player
+func (o Orientation) Flipped() Orientation {
+	o.Rotate = (o.Rotate + 180) % 360
+	return o
+}
+
+// Size returns the size of a w x h picture after turning
+func (o Orientation) Size(w, h int) (int, int) {
+	if o.Rotate == 90 || o.Rotate == 270 {
+		return h, w
+	}
+	return w, h
+}
+
+// Map returns where pixel x, y of a w x h picture ends up
+func (o Orientation) Map(x, y, w, h int) (int, int) {
+	switch o.Rotate {
+	case 90:
+		x, y = h-1-y, x
+	case 180:
+		x, y = w-1-x, h-1-y
+	case 270:
+		x, y = y, w-1-x
+	}
+	if o.Mirror {
+		ow, _ := o.Size(w, h)
+		x = ow - 1 - x
+	}
+	return x, y
+}
@@ -0,0 +1,41 @@
+package display
+
+import "testing"
+
+func TestOrientation(t *testing.T) {
+	// Where the top right pixel of a 4x2 picture lands
+	tests := []struct {
+		spec         string
+		wantW        int
+		wantX, wantY int
+	}{
+		{"0", 4, 3, 0},
+		{"90", 2, 1, 3},
+		{"180", 4, 0, 1},
+		{"270", 2, 0, 0},
+		{"270m", 2, 1, 0},
+		{"0m", 4, 0, 0},
+	}
+	for _, tt := range tests {
+		o, err := ParseOrientation(tt.spec)
+		if err != nil {
+			t.Fatalf("ParseOrientation(%q) failed: %v\n", tt.spec, err)
+		}
+		if o.String() != tt.spec {
+			t.Errorf("expected %q to round trip, got %q\n", tt.spec, o.String())
+		}
+		w, _ := o.Size(4, 2)
+		x, y := o.Map(3, 0, 4, 2)
+		if w != tt.wantW || x != tt.wantX || y != tt.wantY {
+			t.Errorf("%s: expected width %d and (%d,%d), got %d and (%d,%d)\n", tt.spec, tt.wantW, tt.wantX, tt.wantY, w, x, y)
+		}
+	}
+	if o := (Orientation{Rotate: 270}).Flipped(); o.Rotate != 90 {
+		t.Errorf("expected 270 flipped to be 90, got %d\n", o.Rotate)
+	}
+	for _, bad := range []string{"45", "360", "x", "-90"} {
+		if _, err := ParseOrientation(bad); err == nil {
+			t.Errorf("expected error for %q\n", bad)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package display
+
+import (
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+)
+
+// Overlay colours a monochrome picture the way cabinets did: coloured
+// cellophane on the glass tints the phosphor, and on some cabinets a lit
+// backdrop (the Invaders moon) shows through a half-silvered mirror behind
+// the picture. Both are flat arrays in framebuffer order.
+type Overlay struct {
+	Width  int
+	Height int
+	// Tint multiplies each pixel (white = clear cellophane)
+	Tint []uint32
+	// Backdrop is added to each pixel after tinting; nil for none
+	Backdrop []uint32
+}
+
+// NewOverlay returns a clear overlay with no backdrop
+func NewOverlay(width, height int) *Overlay {
+	o := &Overlay{Width: width, Height: height, Tint: make([]uint32, width*height)}
+	for i := range o.Tint {
+		o.Tint[i] = 0xffffffff
+	}
+	return o
+}
+
+// AddBox tints the pixels in [x0,x1) x [y0,y1), clipped to the overlay
+func (o *Overlay) AddBox(x0, y0, x1, y1 int, color uint32) {
+	x0, x1 = clamp(x0, 0, o.Width), clamp(x1, 0, o.Width)
+	y0, y1 = clamp(y0, 0, o.Height), clamp(y1, 0, o.Height)
+	for y := y0; y < y1; y++ {
+		for x := x0; x < x1; x++ {
+			o.Tint[y*o.Width+x] = color
+		}
+	}
+}
+
+// Apply tints fb and adds the backdrop, in place
+func (o *Overlay) Apply(fb *Framebuffer) error {
+	if fb.Width != o.Width || fb.Height != o.Height {
+		return fmt.Errorf("overlay is %dx%d, frame is %dx%d", o.Width, o.Height, fb.Width, fb.Height)
+	}
+	for i, c := range fb.Pix {
+		c = multiply(c, o.Tint[i])
+		if o.Backdrop != nil {
+			c = add(c, o.Backdrop[i])
+		}
+		fb.Pix[i] = c
+	}
+	return nil
+}
+
+// LoadTint loads cellophane colours from an image. Transparent areas are
+// clear, and the image is scaled to the overlay size if needed.
+func (o *Overlay) LoadTint(path string) error {
+	img, err := loadPNG(path)
+	if err != nil {
+		return err
+	}
+	o.Tint = o.resample(img, 0xffffffff)
+	return nil
+}
+
+// LoadBackdrop loads the backdrop from an image, scaled to the overlay size
+// if needed. Transparent areas are black.
+func (o *Overlay) LoadBackdrop(path string) error {
+	img, err := loadPNG(path)
+	if err != nil {
+		return err
+	}
+	o.Backdrop = o.resample(img, 0xff000000)
+	return nil
+}
+
+// resample converts img to the overlay size with nearest neighbour sampling,
+// compositing it over background
+func (o *Overlay) resample(img image.Image, background uint32) []uint32 {
+	b := img.Bounds()
+	pix := make([]uint32, o.Width*o.Height)
+	for y := 0; y < o.Height; y++ {
+		sy := b.Min.Y + y*b.Dy()/o.Height
+		for x := 0; x < o.Width; x++ {
+			sx := b.Min.X + x*b.Dx()/o.Width
+			r, g, bl, a := img.At(sx, sy).RGBA()
+			pix[y*o.Width+x] = over(r>>8, g>>8, bl>>8, a>>8, background)
+		}
+	}
+	return pix
+}
+
+func loadPNG(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return img, nil
+}
+
+// over composites premultiplied r, g, b with alpha a over an opaque background
+func over(r, g, b, a uint32, background uint32) uint32 {
+	blend := func(c uint32, shift uint) uint32 {
+		bg := (background >> shift) & 0xff
+		return (c + bg*(255-a)/255) & 0xff
+	}
+	return 0xff000000 | blend(r, 16)<<16 | blend(g, 8)<<8 | blend(b, 0)
+}
+
+func multiply(a, b uint32) uint32 {
+	ch := func(shift uint) uint32 {
+		return ((a >> shift) & 0xff) * ((b >> shift) & 0xff) / 255
+	}
+	return 0xff000000 | ch(16)<<16 | ch(8)<<8 | ch(0)
+}
+
+func add(a, b uint32) uint32 {
+	ch := func(shift uint) uint32 {
+		c := (a>>shift)&0xff + (b>>shift)&0xff
+		if c > 0xff {
+			c = 0xff
+		}
+		return c
+	}
+	return 0xff000000 | ch(16)<<16 | ch(8)<<8 | ch(0)
+}
+
+func clamp(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
@@ -0,0 +1,69 @@
+package display
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTestPNG(t *testing.T, path string, img image.Image) {
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Create failed: %v\n", err)
+	}
+	defer f.Close()
+	if err := png.Encode(f, img); err != nil {
+		t.Fatalf("Encode failed: %v\n", err)
+	}
+}
+
+func TestOverlay(t *testing.T) {
+	dir := t.TempDir()
+
+	// A 2x2 tint scaled up to 4x4: red top left, transparent elsewhere
+	tint := image.NewNRGBA(image.Rect(0, 0, 2, 2))
+	tint.Set(0, 0, color.NRGBA{R: 0xff, A: 0xff})
+	writeTestPNG(t, filepath.Join(dir, "tint.png"), tint)
+
+	backdrop := image.NewRGBA(image.Rect(0, 0, 4, 4))
+	backdrop.Set(3, 3, color.RGBA{R: 0x20, G: 0x20, B: 0x40, A: 0xff})
+	writeTestPNG(t, filepath.Join(dir, "backdrop.png"), backdrop)
+
+	o := NewOverlay(4, 4)
+	if err := o.LoadTint(filepath.Join(dir, "tint.png")); err != nil {
+		t.Fatalf("LoadTint failed: %v\n", err)
+	}
+	if err := o.LoadBackdrop(filepath.Join(dir, "backdrop.png")); err != nil {
+		t.Fatalf("LoadBackdrop failed: %v\n", err)
+	}
+	o.AddBox(2, 0, 10, 1, 0xff00ff00) // clipped to the overlay
+
+	fb := NewFramebuffer(4, 4)
+	fb.Clear(0xffffffff)
+	fb.Set(3, 3, 0xff000000)
+	fb.Set(2, 3, 0xff808080)
+	if err := o.Apply(fb); err != nil {
+		t.Fatalf("Apply failed: %v\n", err)
+	}
+
+	want := map[[2]int]uint32{
+		{1, 1}: 0xffff0000, // tinted red
+		{2, 0}: 0xff00ff00, // box
+		{3, 0}: 0xff00ff00,
+		{0, 2}: 0xffffffff, // clear cellophane
+		{2, 3}: 0xff808080, // half lit, clear
+		{3, 3}: 0xff202040, // unlit: backdrop shows through
+	}
+	for p, c := range want {
+		if got := fb.At(p[0], p[1]); got != c {
+			t.Errorf("pixel %v: expected %08x, got %08x\n", p, c, got)
+		}
+	}
+
+	if err := o.Apply(NewFramebuffer(2, 2)); err == nil {
+		t.Fatalf("expected a size mismatch error\n")
+	}
+}
@@ -0,0 +1,24 @@
+package display
+
+import (
+	"image/png"
+	"io"
+	"os"
+)
+
+func WritePNG(w io.Writer, fb *Framebuffer) error {
+	return png.Encode(w, fb.Image())
+}
+
+// SavePNG writes the framebuffer to a PNG file
+func SavePNG(path string, fb *Framebuffer) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := WritePNG(f, fb); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
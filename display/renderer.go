@@ -0,0 +1,38 @@
+// Package display presents framebuffers produced by a machine. Backends are
+// independent instances, so several may be used in one process.
+package display
+
+// Renderer is a display backend
+type Renderer interface {
+	// Render presents one frame
+	Render(fb *Framebuffer) error
+	Close() error
+}
+
+// ImageRenderer keeps the last rendered frame in memory, for tests and headless runs
+type ImageRenderer struct {
+	frame  *Framebuffer
+	Frames uint64
+}
+
+func NewImageRenderer() *ImageRenderer {
+	return &ImageRenderer{}
+}
+
+func (r *ImageRenderer) Render(fb *Framebuffer) error {
+	if r.frame == nil || r.frame.Width != fb.Width || r.frame.Height != fb.Height {
+		r.frame = NewFramebuffer(fb.Width, fb.Height)
+	}
+	copy(r.frame.Pix, fb.Pix)
+	r.Frames++
+	return nil
+}
+
+// Frame returns the last rendered frame, or nil if nothing was rendered yet
+func (r *ImageRenderer) Frame() *Framebuffer {
+	return r.frame
+}
+
+func (r *ImageRenderer) Close() error {
+	return nil
+}
@@ -0,0 +1,84 @@
+package display
+
+import (
+	"fmt"
+	"math"
+)
+
+// ScaleMode controls how a framebuffer is fitted to a window
+type ScaleMode int
+
+const (
+	// ScaleFit scales as large as possible, keeping the aspect ratio
+	ScaleFit ScaleMode = iota
+	// ScaleInteger scales by the largest whole multiple that fits, for sharp pixels
+	ScaleInteger
+	// ScaleStretch fills the window, ignoring the aspect ratio
+	ScaleStretch
+)
+
+func (m ScaleMode) String() string {
+	switch m {
+	case ScaleFit:
+		return "fit"
+	case ScaleInteger:
+		return "integer"
+	case ScaleStretch:
+		return "stretch"
+	}
+	return fmt.Sprintf("ScaleMode(%d)", int(m))
+}
+
+func ParseScaleMode(s string) (ScaleMode, error) {
+	for _, m := range []ScaleMode{ScaleFit, ScaleInteger, ScaleStretch} {
+		if m.String() == s {
+			return m, nil
+		}
+	}
+	return ScaleFit, fmt.Errorf("unknown scale mode %q (want fit, integer or stretch)", s)
+}
+
+// Viewport returns where a srcW x srcH framebuffer is drawn in an outW x outH
+// window, centred. aspect is the width / height the picture should have on
+// screen; 0 means square pixels. In integer mode the height is a whole
+// multiple of srcH and, when aspect is set, the width follows from it.
+func Viewport(mode ScaleMode, srcW, srcH int, aspect float64, outW, outH int) (x, y, w, h int) {
+	if srcW <= 0 || srcH <= 0 || outW <= 0 || outH <= 0 {
+		return 0, 0, 0, 0
+	}
+	if mode == ScaleStretch {
+		return 0, 0, outW, outH
+	}
+	if aspect <= 0 {
+		aspect = float64(srcW) / float64(srcH)
+	}
+
+	if mode == ScaleInteger {
+		n := outH / srcH
+		if aspect == float64(srcW)/float64(srcH) {
+			if nw := outW / srcW; nw < n {
+				n = nw
+			}
+		} else {
+			for n > 1 && int(math.Round(float64(n*srcH)*aspect)) > outW {
+				n--
+			}
+		}
+		if n < 1 {
+			// Too small for even 1x; fall back to fitting
+			mode = ScaleFit
+		} else {
+			h = n * srcH
+			w = int(math.Round(float64(h) * aspect))
+		}
+	}
+	if mode == ScaleFit {
+		h = outH
+		w = int(math.Round(float64(h) * aspect))
+		if w > outW {
+			w = outW
+			h = int(math.Round(float64(w) / aspect))
+		}
+	}
+	return (outW - w) / 2, (outH - h) / 2, w, h
+}
@@ -0,0 +1,34 @@
+package display
+
+import "testing"
+
+func TestViewport(t *testing.T) {
+	tests := []struct {
+		mode       ScaleMode
+		aspect     float64
+		outW, outH int
+		want       [4]int
+	}{
+		{ScaleStretch, 0.75, 1000, 500, [4]int{0, 0, 1000, 500}},
+		{ScaleFit, 0, 1000, 512, [4]int{276, 0, 448, 512}},
+		{ScaleFit, 0.75, 1000, 512, [4]int{308, 0, 384, 512}},
+		{ScaleFit, 0.75, 300, 1000, [4]int{0, 300, 300, 400}},
+		{ScaleInteger, 0, 700, 800, [4]int{14, 16, 672, 768}},
+		{ScaleInteger, 0.75, 500, 800, [4]int{58, 144, 384, 512}},
+		{ScaleInteger, 0.75, 300, 800, [4]int{54, 272, 192, 256}},
+		{ScaleInteger, 0, 100, 100, [4]int{6, 0, 88, 100}},
+	}
+	for _, tt := range tests {
+		x, y, w, h := Viewport(tt.mode, 224, 256, tt.aspect, tt.outW, tt.outH)
+		if got := [4]int{x, y, w, h}; got != tt.want {
+			t.Errorf("%v aspect %v in %dx%d: expected %v, got %v\n", tt.mode, tt.aspect, tt.outW, tt.outH, tt.want, got)
+		}
+	}
+
+	if _, err := ParseScaleMode("integer"); err != nil {
+		t.Fatalf("ParseScaleMode failed: %v\n", err)
+	}
+	if _, err := ParseScaleMode("zoom"); err == nil {
+		t.Fatalf("expected error for unknown scale mode\n")
+	}
+}
@@ -0,0 +1,195 @@
+// Package sdlrender is the SDL window backend for display.Renderer
+package sdlrender
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/veandco/go-sdl2/sdl"
+	"intel8080/display"
+)
+
+// open counts live windows so SDL is shut down with the last one
+var open int
+
+// Options configures a window
+type Options struct {
+	Title  string
+	Width  int32
+	Height int32
+
+	Scale display.ScaleMode
+	// Aspect is the width / height of the picture on screen (0 for square pixels)
+	Aspect float64
+	// VSync waits for the monitor's vertical blank when presenting
+	VSync      bool
+	Fullscreen bool
+	// Smooth uses linear filtering when scaling instead of nearest neighbour
+	Smooth bool
+}
+
+// Renderer draws framebuffers into its own resizable SDL window. Each frame is
+// uploaded to a streaming texture and scaled by the GPU.
+type Renderer struct {
+	opts     Options
+	window   *sdl.Window
+	renderer *sdl.Renderer
+
+	texture *sdl.Texture
+	texW    int
+	texH    int
+}
+
+func New(opts Options) (*Renderer, error) {
+	// Audio is left to sdlaudio, so a machine without sound still opens a window
+	if err := sdl.Init(sdl.INIT_VIDEO); err != nil {
+		return nil, fmt.Errorf("sdl init failed: %v", err)
+	}
+	r := &Renderer{opts: opts}
+	if err := r.open(); err != nil {
+		r.destroy()
+		if open == 0 {
+			sdl.Quit()
+		}
+		return nil, err
+	}
+	open++
+	return r, nil
+}
+
+func (r *Renderer) open() error {
+	flags := uint32(sdl.WINDOW_SHOWN | sdl.WINDOW_RESIZABLE | sdl.WINDOW_ALLOW_HIGHDPI)
+	if r.opts.Fullscreen {
+		flags |= sdl.WINDOW_FULLSCREEN_DESKTOP
+	}
+	window, err := sdl.CreateWindow(r.opts.Title, sdl.WINDOWPOS_UNDEFINED, sdl.WINDOWPOS_UNDEFINED,
+		r.opts.Width, r.opts.Height, flags)
+	if err != nil {
+		return fmt.Errorf("create window failed: %v", err)
+	}
+	r.window = window
+
+	rendererFlags := uint32(sdl.RENDERER_ACCELERATED)
+	if r.opts.VSync {
+		rendererFlags |= sdl.RENDERER_PRESENTVSYNC
+	}
+	quality := "0"
+	if r.opts.Smooth {
+		quality = "1"
+	}
+	// The hint is read when textures are created
+	sdl.SetHint(sdl.HINT_RENDER_SCALE_QUALITY, quality)
+	renderer, err := sdl.CreateRenderer(window, -1, rendererFlags)
+	if err != nil {
+		return fmt.Errorf("create renderer failed: %v", err)
+	}
+	r.renderer = renderer
+	return nil
+}
+
+func (r *Renderer) Render(fb *display.Framebuffer) error {
+	if r.texture == nil || r.texW != fb.Width || r.texH != fb.Height {
+		if err := r.createTexture(fb.Width, fb.Height); err != nil {
+			return err
+		}
+	}
+	if err := r.upload(fb); err != nil {
+		return err
+	}
+
+	outW, outH, err := r.renderer.GetOutputSize()
+	if err != nil {
+		return fmt.Errorf("draw: GetOutputSize failed: %v", err)
+	}
+	x, y, w, h := display.Viewport(r.opts.Scale, fb.Width, fb.Height, r.opts.Aspect, int(outW), int(outH))
+
+	_ = r.renderer.SetDrawColor(0, 0, 0, 0xff)
+	if err := r.renderer.Clear(); err != nil {
+		return fmt.Errorf("draw: Clear failed: %v", err)
+	}
+	dst := sdl.Rect{X: int32(x), Y: int32(y), W: int32(w), H: int32(h)}
+	if err := r.renderer.Copy(r.texture, nil, &dst); err != nil {
+		return fmt.Errorf("draw: Copy failed: %v", err)
+	}
+	r.renderer.Present()
+	return nil
+}
+
+func (r *Renderer) createTexture(width, height int) error {
+	if r.texture != nil {
+		_ = r.texture.Destroy()
+		r.texture = nil
+	}
+	texture, err := r.renderer.CreateTexture(sdl.PIXELFORMAT_ARGB8888, sdl.TEXTUREACCESS_STREAMING,
+		int32(width), int32(height))
+	if err != nil {
+		return fmt.Errorf("create texture failed: %v", err)
+	}
+	r.texture, r.texW, r.texH = texture, width, height
+	return nil
+}
+
+// upload copies the framebuffer into the texture. ARGB8888 is a packed
+// format, so each pixel is stored as a native-endian uint32 (little-endian on
+// every platform we build for).
+func (r *Renderer) upload(fb *display.Framebuffer) error {
+	pixels, pitch, err := r.texture.Lock(nil)
+	if err != nil {
+		return fmt.Errorf("draw: texture Lock failed: %v", err)
+	}
+	defer r.texture.Unlock()
+	for y := 0; y < fb.Height; y++ {
+		row := pixels[y*pitch:]
+		for x, c := range fb.Pix[y*fb.Width : (y+1)*fb.Width] {
+			binary.LittleEndian.PutUint32(row[x*4:], c)
+		}
+	}
+	return nil
+}
+
+// ToggleFullscreen switches between a window and borderless desktop fullscreen
+func (r *Renderer) ToggleFullscreen() error {
+	var flags uint32
+	if r.window.GetFlags()&sdl.WINDOW_FULLSCREEN_DESKTOP == 0 {
+		flags = sdl.WINDOW_FULLSCREEN_DESKTOP
+	}
+	return r.window.SetFullscreen(flags)
+}
+
+// SetScale changes the scaling mode from the next frame
+func (r *Renderer) SetScale(mode display.ScaleMode) {
+	r.opts.Scale = mode
+}
+
+func (r *Renderer) Scale() display.ScaleMode {
+	return r.opts.Scale
+}
+
+func (r *Renderer) Close() error {
+	if r.window == nil {
+		return nil
+	}
+	err := r.destroy()
+	open--
+	if open == 0 {
+		sdl.Quit()
+	}
+	return err
+}
+
+func (r *Renderer) destroy() error {
+	var err error
+	if r.texture != nil {
+		_ = r.texture.Destroy()
+		r.texture = nil
+	}
+	if r.renderer != nil {
+		_ = r.renderer.Destroy()
+		r.renderer = nil
+	}
+	if r.window != nil {
+		err = r.window.Destroy()
+		r.window = nil
+	}
+	return err
+}
@@ -0,0 +1,21 @@
+package sdlrender
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestNewWithoutAudio opens a window where no audio driver works: sound is
+// opened on its own by sdlaudio, so the window must not need it
+func TestNewWithoutAudio(t *testing.T) {
+	t.Setenv("SDL_VIDEODRIVER", "dummy")
+	t.Setenv("SDL_AUDIODRIVER", "none")
+	r, err := New(Options{Title: "test", Width: 224, Height: 256})
+	if err != nil && strings.HasPrefix(err.Error(), "sdl init failed") {
+		t.Fatalf("New failed without audio: %v\n", err)
+	}
+	if err != nil {
+		t.Skipf("no window on the dummy video driver: %v\n", err)
+	}
+	_ = r.Close()
+}
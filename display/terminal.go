@@ -0,0 +1,207 @@
+package display
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// TerminalMode selects how pixels are packed into characters
+type TerminalMode int
+
+const (
+	// TerminalBraille draws 2x4 pixels per character with Unicode braille patterns
+	TerminalBraille TerminalMode = iota
+	// TerminalHalfBlock draws 1x2 pixels per character with upper half blocks,
+	// the top pixel in the foreground colour and the bottom one in the background
+	TerminalHalfBlock
+	// TerminalASCII draws 2x4 pixels per character as '#' when any is lit
+	TerminalASCII
+)
+
+func (m TerminalMode) String() string {
+	switch m {
+	case TerminalBraille:
+		return "braille"
+	case TerminalHalfBlock:
+		return "halfblock"
+	case TerminalASCII:
+		return "ascii"
+	}
+	return fmt.Sprintf("TerminalMode(%d)", int(m))
+}
+
+func ParseTerminalMode(s string) (TerminalMode, error) {
+	for _, m := range []TerminalMode{TerminalBraille, TerminalHalfBlock, TerminalASCII} {
+		if m.String() == s {
+			return m, nil
+		}
+	}
+	return TerminalBraille, fmt.Errorf("unknown terminal mode %q (want braille, halfblock or ascii)", s)
+}
+
+// TerminalRenderer draws frames as text with ANSI colours, so the emulator
+// can be played over SSH. Colours are reduced to the 8 basic ANSI colours,
+// which is enough for the Invaders overlay bands and works on any terminal.
+type TerminalRenderer struct {
+	Mode TerminalMode
+	// Mono disables colour escapes
+	Mono bool
+
+	out     *bufio.Writer
+	fg      int
+	bg      int
+	started bool
+}
+
+func NewTerminalRenderer(out io.Writer, mode TerminalMode) *TerminalRenderer {
+	return &TerminalRenderer{Mode: mode, out: bufio.NewWriter(out)}
+}
+
+// brailleDots maps a pixel offset within a 2x4 cell to its braille dot bit
+var brailleDots = [4][2]rune{
+	{0x01, 0x08},
+	{0x02, 0x10},
+	{0x04, 0x20},
+	{0x40, 0x80},
+}
+
+func (r *TerminalRenderer) Render(fb *Framebuffer) error {
+	if !r.started {
+		// Clear the screen and hide the cursor
+		_, _ = r.out.WriteString("\x1b[2J\x1b[?25l")
+		r.started = true
+	}
+	// Move the cursor home so each frame overwrites the last
+	_, _ = r.out.WriteString("\x1b[H")
+	r.fg, r.bg = -1, -1
+	switch r.Mode {
+	case TerminalHalfBlock:
+		r.renderHalfBlocks(fb)
+	default:
+		r.renderCells(fb)
+	}
+	r.setColors(-1, -1)
+	return r.out.Flush()
+}
+
+// renderCells draws braille or ASCII cells of 2x4 pixels. A cell takes the
+// colour of its most common lit pixel.
+func (r *TerminalRenderer) renderCells(fb *Framebuffer) {
+	for y := 0; y < fb.Height; y += 4 {
+		for x := 0; x < fb.Width; x += 2 {
+			var dots rune
+			var counts [8]int
+			for dy := 0; dy < 4 && y+dy < fb.Height; dy++ {
+				for dx := 0; dx < 2 && x+dx < fb.Width; dx++ {
+					if c := ansiColor(fb.At(x+dx, y+dy)); c != 0 {
+						dots |= brailleDots[dy][dx]
+						counts[c]++
+					}
+				}
+			}
+			if dots == 0 {
+				_ = r.out.WriteByte(' ')
+				continue
+			}
+			fg := 0
+			for c := 1; c < len(counts); c++ {
+				if counts[c] > counts[fg] {
+					fg = c
+				}
+			}
+			r.setColors(fg, -1)
+			if r.Mode == TerminalASCII {
+				_ = r.out.WriteByte('#')
+			} else {
+				_, _ = r.out.WriteRune(0x2800 + dots)
+			}
+		}
+		r.newline()
+	}
+}
+
+func (r *TerminalRenderer) renderHalfBlocks(fb *Framebuffer) {
+	for y := 0; y < fb.Height; y += 2 {
+		for x := 0; x < fb.Width; x++ {
+			top := ansiColor(fb.At(x, y))
+			bottom := 0
+			if y+1 < fb.Height {
+				bottom = ansiColor(fb.At(x, y+1))
+			}
+			switch {
+			case top == 0 && bottom == 0:
+				// Blank cells must not pick up the last cell's background
+				if r.bg > 0 {
+					r.setColors(r.fg, 0)
+				}
+				_ = r.out.WriteByte(' ')
+			case r.Mono:
+				// Without colours only the shape of the lit pixels is kept
+				_, _ = r.out.WriteString(monoBlocks[btoi(top != 0)<<1|btoi(bottom != 0)])
+			default:
+				r.setColors(top, bottom)
+				_, _ = r.out.WriteString("▀")
+			}
+		}
+		r.newline()
+	}
+}
+
+var monoBlocks = [4]string{" ", "▄", "▀", "█"}
+
+func btoi(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func (r *TerminalRenderer) newline() {
+	// Reset the background so it doesn't bleed to the edge of the terminal
+	if r.bg >= 0 {
+		r.setColors(-1, -1)
+	}
+	_, _ = r.out.WriteString("\r\n")
+}
+
+// setColors switches to ANSI colours fg and bg (0-7), or the defaults for -1,
+// writing escapes only when they change
+func (r *TerminalRenderer) setColors(fg, bg int) {
+	if r.Mono || (fg == r.fg && bg == r.bg) {
+		return
+	}
+	if (fg < 0 && r.fg >= 0) || (bg < 0 && r.bg >= 0) {
+		_, _ = r.out.WriteString("\x1b[0m")
+		r.fg, r.bg = -1, -1
+	}
+	if fg >= 0 && fg != r.fg {
+		_, _ = fmt.Fprintf(r.out, "\x1b[%dm", 30+fg)
+	}
+	if bg >= 0 && bg != r.bg {
+		_, _ = fmt.Fprintf(r.out, "\x1b[%dm", 40+bg)
+	}
+	r.fg, r.bg = fg, bg
+}
+
+// ansiColor maps an ARGB pixel to the nearest basic ANSI colour: bit 0 red,
+// bit 1 green, bit 2 blue. 0 (black) is unlit.
+func ansiColor(c uint32) int {
+	var ansi int
+	if (c>>16)&0xff >= 0x80 {
+		ansi |= 1
+	}
+	if (c>>8)&0xff >= 0x80 {
+		ansi |= 2
+	}
+	if c&0xff >= 0x80 {
+		ansi |= 4
+	}
+	return ansi
+}
+
+func (r *TerminalRenderer) Close() error {
+	// Restore colours and show the cursor again
+	_, _ = r.out.WriteString("\x1b[0m\x1b[?25h")
+	return r.out.Flush()
+}
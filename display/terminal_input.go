@@ -0,0 +1,183 @@
+package display
+
+import (
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// TerminalKeys decodes key presses from a raw terminal. Terminals only report
+// presses (repeated while a key is held), never releases, so callers treat a
+// key as held for a short time after each press.
+type TerminalKeys struct {
+	// Keys receives key names: single characters ("a", "1"), or "space",
+	// "enter", "tab", "esc", "backspace", "up", "down", "left", "right",
+	// "f1"-"f12", or a character with "alt+". It is closed when the input
+	// ends.
+	Keys <-chan string
+}
+
+// EscapeWait is how long a lone ESC, or the start of an escape sequence,
+// waits for the rest of the sequence: a terminal may send an arrow key in
+// two reads, which must not count as the Esc key
+const EscapeWait = 50 * time.Millisecond
+
+// NewTerminalKeys starts decoding keys read from in
+func NewTerminalKeys(in io.Reader) *TerminalKeys {
+	keys := make(chan string, 64)
+	reads := make(chan []byte)
+	go func() {
+		defer close(reads)
+		for {
+			buf := make([]byte, 64)
+			n, err := in.Read(buf)
+			if n > 0 {
+				reads <- buf[:n]
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	go func() {
+		defer close(keys)
+		// pending is an escape sequence still missing its end
+		var pending []byte
+		send := func(decoded []string) {
+			for _, key := range decoded {
+				keys <- key
+			}
+		}
+		for {
+			var wait <-chan time.Time
+			if len(pending) > 0 {
+				wait = time.After(EscapeWait)
+			}
+			select {
+			case b, ok := <-reads:
+				if !ok {
+					send(DecodeKeys(pending))
+					return
+				}
+				var decoded []string
+				decoded, pending = splitKeys(append(pending, b...))
+				send(decoded)
+			case <-wait:
+				send(DecodeKeys(pending))
+				pending = nil
+			}
+		}
+	}()
+	return &TerminalKeys{Keys: keys}
+}
+
+// escapeKeys maps the escape sequences sent by xterm compatible terminals
+var escapeKeys = map[string]string{
+	"[A": "up", "[B": "down", "[C": "right", "[D": "left",
+	"OA": "up", "OB": "down", "OC": "right", "OD": "left",
+	"OP": "f1", "OQ": "f2", "OR": "f3", "OS": "f4",
+	"[15~": "f5", "[17~": "f6", "[18~": "f7", "[19~": "f8",
+	"[20~": "f9", "[21~": "f10", "[23~": "f11", "[24~": "f12",
+}
+
+// DecodeKeys splits one read from a raw terminal into key names. Only a lone
+// ESC is reported as "esc"; sequences it doesn't know, such as Home, Shift+Up
+// or mouse reports, are dropped whole, and ESC before a character is Alt
+// ("alt+x").
+func DecodeKeys(b []byte) []string {
+	keys, rest := splitKeys(b)
+	if len(rest) == 1 {
+		keys = append(keys, "esc")
+	}
+	return keys
+}
+
+// splitKeys decodes the keys in b, returning an escape sequence at its end
+// that may still be unfinished
+func splitKeys(b []byte) (keys []string, rest []byte) {
+	for i := 0; i < len(b); i++ {
+		c := b[i]
+		switch {
+		case c == 0x1b:
+			key, n, ok := decodeEscape(b[i+1:])
+			if !ok {
+				return keys, b[i:]
+			}
+			if key != "" {
+				keys = append(keys, key)
+			}
+			i += n
+		case c == ' ':
+			keys = append(keys, "space")
+		case c == '\r' || c == '\n':
+			keys = append(keys, "enter")
+		case c == '\t':
+			keys = append(keys, "tab")
+		case c == 0x7f || c == 0x08:
+			keys = append(keys, "backspace")
+		case c == 0x03:
+			// Ctrl+C arrives as a byte in raw mode
+			keys = append(keys, "ctrl+c")
+		case c >= 0x20 && c < 0x7f:
+			keys = append(keys, strings.ToLower(string(c)))
+		}
+	}
+	return keys, nil
+}
+
+// decodeEscape decodes the sequence after ESC, returning the key ("" for
+// one to ignore) and the number of bytes used, or false when b ends before
+// the sequence does
+func decodeEscape(b []byte) (string, int, bool) {
+	switch {
+	case len(b) == 0:
+		return "", 0, false
+	case b[0] == '[':
+		// CSI: parameter and intermediate bytes, then a final byte in 0x40-0x7e
+		for n := 1; n < len(b); n++ {
+			if c := b[n]; c >= 0x40 && c <= 0x7e {
+				return escapeKeys[string(b[:n+1])], n + 1, true
+			} else if c < 0x20 || c > 0x3f {
+				// Not a sequence after all: drop what came before
+				return "", n, true
+			}
+		}
+		return "", 0, false
+	case b[0] == 'O':
+		// SS3: a single final byte
+		if len(b) < 2 {
+			return "", 0, false
+		}
+		return escapeKeys[string(b[:2])], 2, true
+	case b[0] > 0x20 && b[0] < 0x7f:
+		// Alt held with a character
+		return "alt+" + strings.ToLower(string(b[0])), 1, true
+	}
+	// ESC before a control character: leave that to be read on its own
+	return "", 0, true
+}
+
+// MakeRaw puts the terminal on f into raw mode with stty, and returns a
+// function restoring the previous settings. It needs a Unix-like system.
+func MakeRaw(f *os.File) (restore func() error, err error) {
+	stty := func(args ...string) (string, error) {
+		cmd := exec.Command("stty", args...)
+		cmd.Stdin = f
+		out, err := cmd.Output()
+		return strings.TrimSpace(string(out)), err
+	}
+	saved, err := stty("-g")
+	if err != nil {
+		return nil, errors.New("stdin is not a terminal (or stty is missing)")
+	}
+	if _, err := stty("raw", "-echo"); err != nil {
+		return nil, err
+	}
+	return func() error {
+		_, err := stty(saved)
+		return err
+	}, nil
+}
@@ -0,0 +1,80 @@
+package display
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTerminalRenderer(t *testing.T) {
+	fb := NewFramebuffer(4, 4)
+	fb.Clear(0xff000000)
+	fb.Set(0, 0, 0xffffffff) // white dot 1 of the first cell
+	fb.Set(1, 3, 0xff00ff00) // green dot 8 of the first cell
+	fb.Set(1, 2, 0xff00ff00) // green dot 6 of the first cell
+	fb.Set(2, 1, 0xffff0000) // red, second cell
+
+	tests := []struct {
+		mode TerminalMode
+		mono bool
+		want string
+	}{
+		{TerminalBraille, false, "\x1b[32m⢡\x1b[31m⠂\r\n\x1b[0m"},
+		{TerminalBraille, true, "⢡⠂\r\n"},
+		{TerminalASCII, true, "##\r\n"},
+		{TerminalHalfBlock, false, "\x1b[37m\x1b[40m▀ \x1b[30m\x1b[41m▀\x1b[40m \x1b[0m\r\n" +
+			" \x1b[32m\x1b[42m▀\x1b[40m  \x1b[0m\r\n"},
+		{TerminalHalfBlock, true, "▀ ▄ \r\n █  \r\n"},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		r := NewTerminalRenderer(&out, tt.mode)
+		r.Mono = tt.mono
+		if err := r.Render(fb); err != nil {
+			t.Fatalf("Render failed: %v\n", err)
+		}
+		got := strings.TrimPrefix(out.String(), "\x1b[2J\x1b[?25l\x1b[H")
+		if got != tt.want {
+			t.Errorf("%v (mono %v): expected %q, got %q\n", tt.mode, tt.mono, tt.want, got)
+		}
+	}
+}
+
+func TestDecodeKeys(t *testing.T) {
+	// Unknown sequences (Alt+Esc, Home, Shift+Up, a mouse report) are dropped
+	got := DecodeKeys([]byte("aW \r\x1b[D\x1bOP\x1b[15~\x1b\x1b[9z\x1b[H\x1b[1;2A\x1b[<0;10;5M\x1bX\x03"))
+	want := []string{"a", "w", "space", "enter", "left", "f1", "f5", "alt+x", "ctrl+c"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v\n", want, got)
+	}
+	if got := DecodeKeys([]byte("\x1b")); !reflect.DeepEqual(got, []string{"esc"}) {
+		t.Fatalf("expected a lone ESC to be esc, got %v\n", got)
+	}
+
+	keys := NewTerminalKeys(strings.NewReader("c\x1b[A"))
+	var read []string
+	for key := range keys.Keys {
+		read = append(read, key)
+	}
+	if !reflect.DeepEqual(read, []string{"c", "up"}) {
+		t.Fatalf("expected [c up], got %v\n", read)
+	}
+
+	// An arrow key split over two reads is not Esc
+	in, out := io.Pipe()
+	go func() {
+		for _, part := range []string{"\x1b", "[A", "\x1b[", "D", "\x1b"} {
+			_, _ = out.Write([]byte(part))
+		}
+		_ = out.Close()
+	}()
+	read = nil
+	for key := range NewTerminalKeys(in).Keys {
+		read = append(read, key)
+	}
+	if !reflect.DeepEqual(read, []string{"up", "left", "esc"}) {
+		t.Fatalf("expected [up left esc], got %v\n", read)
+	}
+}
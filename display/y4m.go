@@ -0,0 +1,70 @@
+package display
+
+import (
+	"bufio"
+	"fmt"
+	"image/color"
+	"io"
+)
+
+// Y4MWriter streams frames as uncompressed YUV4MPEG2 (4:4:4, full range),
+// which ffmpeg and most video tools read directly. The stream takes its size
+// from the first frame.
+type Y4MWriter struct {
+	w                *bufio.Writer
+	rateNum, rateDen int
+	darNum, darDen   int
+	width, height    int
+	plane            []byte
+}
+
+// NewY4MWriter writes frames at rateNum/rateDen frames per second, to be shown
+// with a darNum:darDen display aspect ratio (0:0 for square pixels)
+func NewY4MWriter(w io.Writer, rateNum, rateDen, darNum, darDen int) *Y4MWriter {
+	return &Y4MWriter{w: bufio.NewWriter(w), rateNum: rateNum, rateDen: rateDen, darNum: darNum, darDen: darDen}
+}
+
+func (y *Y4MWriter) WriteFrame(fb *Framebuffer) error {
+	if y.plane == nil {
+		if err := y.writeHeader(fb.Width, fb.Height); err != nil {
+			return err
+		}
+	}
+	if fb.Width != y.width || fb.Height != y.height {
+		return fmt.Errorf("y4m: frame is %dx%d, stream is %dx%d", fb.Width, fb.Height, y.width, y.height)
+	}
+	n := y.width * y.height
+	for i, c := range fb.Pix {
+		y.plane[i], y.plane[n+i], y.plane[2*n+i] = color.RGBToYCbCr(uint8(c>>16), uint8(c>>8), uint8(c))
+	}
+	if _, err := y.w.WriteString("FRAME\n"); err != nil {
+		return err
+	}
+	_, err := y.w.Write(y.plane)
+	return err
+}
+
+func (y *Y4MWriter) writeHeader(width, height int) error {
+	y.width, y.height = width, height
+	y.plane = make([]byte, 3*width*height)
+	// The pixel aspect ratio is whatever stretches the frame to the display aspect
+	parNum, parDen := 1, 1
+	if y.darNum > 0 && y.darDen > 0 {
+		parNum, parDen = y.darNum*height, y.darDen*width
+	}
+	rate, par := gcd(y.rateNum, y.rateDen), gcd(parNum, parDen)
+	_, err := fmt.Fprintf(y.w, "YUV4MPEG2 W%d H%d F%d:%d Ip A%d:%d C444 XCOLORRANGE=FULL\n",
+		width, height, y.rateNum/rate, y.rateDen/rate, parNum/par, parDen/par)
+	return err
+}
+
+func (y *Y4MWriter) Flush() error {
+	return y.w.Flush()
+}
+
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
+}
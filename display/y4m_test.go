@@ -0,0 +1,31 @@
+package display
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestY4MWriter(t *testing.T) {
+	var buf bytes.Buffer
+	y := NewY4MWriter(&buf, 120, 2, 3, 4)
+	fb := NewFramebuffer(2, 1)
+	fb.Set(0, 0, 0xffffffff)
+	fb.Set(1, 0, 0xff000000)
+	for i := 0; i < 2; i++ {
+		if err := y.WriteFrame(fb); err != nil {
+			t.Fatalf("WriteFrame failed: %v\n", err)
+		}
+	}
+	_ = y.Flush()
+
+	// 2x1 pixels shown at 3:4 are 3:8 each
+	header := "YUV4MPEG2 W2 H1 F60:1 Ip A3:8 C444 XCOLORRANGE=FULL\n"
+	frame := "FRAME\n\xff\x00\x80\x80\x80\x80"
+	if want := header + frame + frame; buf.String() != want {
+		t.Fatalf("expected %q, got %q\n", want, buf.String())
+	}
+	if err := y.WriteFrame(NewFramebuffer(1, 1)); err == nil || !strings.Contains(err.Error(), "1x1") {
+		t.Fatalf("expected a size mismatch error, got %v\n", err)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+
+	"intel8080/display"
+)
+
+// filterPresets are filter chains that can be named with -filter. The
+// Invaders monitor is mounted on its side, so its scanlines run vertically.
+var filterPresets = []struct{ name, spec string }{
+	{"crt", "persistence,tint,bloom,vscanlines"},
+	{"smooth", "persistence,scale2x"},
+}
+
+// filters is the video filter chain, cycled at runtime with F8 through off,
+// -filter and the presets
+type filters struct {
+	choices []string
+	current int
+	display.Pipeline
+}
+
+func newFilters(spec string) (*filters, error) {
+	f := &filters{choices: []string{""}}
+	if spec != "" && presetSpec(spec) == "" {
+		f.choices = append(f.choices, spec)
+	}
+	for _, p := range filterPresets {
+		f.choices = append(f.choices, p.name)
+	}
+	for i, c := range f.choices {
+		if c == spec {
+			f.current = i
+		}
+	}
+	return f, f.load()
+}
+
+func presetSpec(name string) string {
+	for _, p := range filterPresets {
+		if p.name == name {
+			return p.spec
+		}
+	}
+	return ""
+}
+
+func (f *filters) load() error {
+	spec := f.choices[f.current]
+	if preset := presetSpec(spec); preset != "" {
+		spec = preset
+	}
+	p, err := display.ParseFilters(spec)
+	if err != nil {
+		return err
+	}
+	f.Pipeline = p
+	return nil
+}
+
+// next switches to the next filter chain. Filters can change the frame
+// size, which GIF and Y4M recordings can't follow, so not while c records.
+func (f *filters) next(c *capture) {
+	if c.recording() {
+		fmt.Println("filter: can't change while recording")
+		return
+	}
+	f.current = (f.current + 1) % len(f.choices)
+	if err := f.load(); err != nil {
+		fmt.Printf("%v\n", err)
+		return
+	}
+	name := f.choices[f.current]
+	if name == "" {
+		name = "off"
+	}
+	fmt.Printf("filter: %s\n", name)
+}
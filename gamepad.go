@@ -0,0 +1,147 @@
+//go:build !nosdl
+// +build !nosdl
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/veandco/go-sdl2/sdl"
+	"intel8080/input"
+)
+
+// gamepads tracks connected game controllers and plain joysticks. The first
+// device plays as player 1 and the second as player 2; devices may be plugged
+// in and out while the game runs.
+type gamepads struct {
+	keys *input.Map
+	pads [input.Players]*gamepad
+}
+
+type gamepad struct {
+	id         sdl.JoystickID
+	controller *sdl.GameController
+	// joystick is set for joysticks SDL has no controller mapping for
+	joystick *sdl.Joystick
+	// held records the buttons and axis directions down, so a stick
+	// wobbling past the deadzone only reports changes
+	held map[string]bool
+}
+
+// handle processes controller and joystick events, reporting binding changes
+// to do. It reports whether event was one of them.
+func (g *gamepads) handle(event sdl.Event, do func(b input.Binding, pressed bool)) bool {
+	switch t := event.(type) {
+	case *sdl.ControllerDeviceEvent:
+		if t.Type == sdl.CONTROLLERDEVICEADDED {
+			// Which is a device index here, and an instance id otherwise
+			if c := sdl.GameControllerOpen(int(t.Which)); c != nil {
+				g.add(&gamepad{id: c.Joystick().InstanceID(), controller: c}, c.Name())
+			}
+		} else if t.Type == sdl.CONTROLLERDEVICEREMOVED {
+			g.remove(t.Which, do)
+		}
+	case *sdl.JoyDeviceAddedEvent:
+		// Controllers are opened by their own event
+		if !sdl.IsGameController(int(t.Which)) {
+			if j := sdl.JoystickOpen(int(t.Which)); j != nil {
+				g.add(&gamepad{id: j.InstanceID(), joystick: j}, j.Name())
+			}
+		}
+	case *sdl.JoyDeviceRemovedEvent:
+		g.remove(t.Which, do)
+	case *sdl.ControllerButtonEvent:
+		name := sdl.GameControllerGetStringForButton(sdl.GameControllerButton(t.Button))
+		g.set(t.Which, name, t.State == sdl.PRESSED, false, do)
+	case *sdl.ControllerAxisEvent:
+		g.axis(t.Which, sdl.GameControllerGetStringForAxis(sdl.GameControllerAxis(t.Axis)), t.Value, false, do)
+	case *sdl.JoyButtonEvent:
+		g.set(t.Which, fmt.Sprintf("button%d", t.Button), t.State == sdl.PRESSED, true, do)
+	case *sdl.JoyHatEvent:
+		// Hats act as the d-pad
+		g.set(t.Which, "dpup", t.Value&sdl.HAT_UP != 0, true, do)
+		g.set(t.Which, "dpdown", t.Value&sdl.HAT_DOWN != 0, true, do)
+		g.set(t.Which, "dpleft", t.Value&sdl.HAT_LEFT != 0, true, do)
+		g.set(t.Which, "dpright", t.Value&sdl.HAT_RIGHT != 0, true, do)
+	case *sdl.JoyAxisEvent:
+		name := fmt.Sprintf("axis%d", t.Axis)
+		switch t.Axis {
+		case 0:
+			name = "leftx"
+		case 1:
+			name = "lefty"
+		}
+		g.axis(t.Which, name, t.Value, true, do)
+	default:
+		return false
+	}
+	return true
+}
+
+func (g *gamepads) add(pad *gamepad, name string) {
+	pad.held = map[string]bool{}
+	for player, p := range g.pads {
+		if p == nil {
+			g.pads[player] = pad
+			fmt.Printf("%s connected as player %d\n", name, player+1)
+			return
+		}
+	}
+	fmt.Printf("%s connected, but both players already have a controller\n", name)
+	pad.close()
+}
+
+// remove closes a disconnected device, releasing whatever it held
+func (g *gamepads) remove(id sdl.JoystickID, do func(input.Binding, bool)) {
+	for player, pad := range g.pads {
+		if pad != nil && pad.id == id {
+			for name, down := range pad.held {
+				if down {
+					g.set(id, name, false, pad.joystick != nil, do)
+				}
+			}
+			pad.close()
+			g.pads[player] = nil
+			fmt.Printf("player %d's controller disconnected\n", player+1)
+		}
+	}
+}
+
+func (g *gamepads) axis(id sdl.JoystickID, name string, value int16, plain bool, do func(input.Binding, bool)) {
+	negative, positive := g.keys.AxisDirections(value)
+	g.set(id, name+"-", negative, plain, do)
+	g.set(id, name+"+", positive, plain, do)
+}
+
+// set records the state of a button on device id. Game controllers also
+// report as plain joysticks, so plain events only count for devices without a
+// controller mapping.
+func (g *gamepads) set(id sdl.JoystickID, name string, down, plain bool, do func(input.Binding, bool)) {
+	for player, pad := range g.pads {
+		if pad == nil || pad.id != id || plain != (pad.joystick != nil) || pad.held[name] == down {
+			continue
+		}
+		pad.held[name] = down
+		if b, ok := g.keys.Button(player, name); ok {
+			do(b, down)
+		}
+	}
+}
+
+func (g *gamepads) close() {
+	for player, pad := range g.pads {
+		if pad != nil {
+			pad.close()
+			g.pads[player] = nil
+		}
+	}
+}
+
+func (pad *gamepad) close() {
+	if pad.controller != nil {
+		pad.controller.Close()
+	}
+	if pad.joystick != nil {
+		pad.joystick.Close()
+	}
+}
@@ -0,0 +1,288 @@
+// Package input maps keys and game controller buttons to cabinet inputs and
+// emulator actions. It knows nothing about SDL or terminals: front-ends look
+// bindings up by key and button name.
+package input
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"intel8080/machine"
+)
+
+// Action is an emulator function a key or button can be bound to
+type Action string
+
+const (
+	ActionQuit         Action = "quit"
+	ActionPause        Action = "pause"
+	ActionFrameAdvance Action = "frame-advance"
+	// ActionFastForward lasts while its key is held
+	ActionFastForward Action = "fast-forward"
+	ActionSlowMotion  Action = "slow-motion"
+	ActionSpeedDown   Action = "speed-down"
+	ActionSpeedUp     Action = "speed-up"
+	ActionScreenshot  Action = "screenshot"
+	ActionGIF         Action = "gif"
+	ActionFilter      Action = "filter"
+	ActionFullscreen  Action = "fullscreen"
+	ActionSaveState   Action = "save-state"
+	ActionLoadState   Action = "load-state"
+	ActionDebugCPU    Action = "debug-cpu"
+	ActionDebugIO     Action = "debug-io"
+	ActionDebugMemory Action = "debug-memory"
+	// ActionDIPMenu shows the DIP switches, selecting the next one on each
+	// press; ActionDIPChange steps the selected switch to its next setting
+	ActionDIPMenu   Action = "dip-menu"
+	ActionDIPChange Action = "dip-change"
+)
+
+var actions = map[Action]bool{
+	ActionQuit: true, ActionPause: true, ActionFrameAdvance: true, ActionFastForward: true,
+	ActionSlowMotion: true, ActionSpeedDown: true, ActionSpeedUp: true, ActionScreenshot: true,
+	ActionGIF: true, ActionFilter: true, ActionFullscreen: true, ActionSaveState: true,
+	ActionLoadState: true, ActionDebugCPU: true, ActionDebugIO: true, ActionDebugMemory: true,
+	ActionDIPMenu: true, ActionDIPChange: true,
+}
+
+// ActionNames returns the action names, sorted
+func ActionNames() []string {
+	var names []string
+	for a := range actions {
+		names = append(names, string(a))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Binding is what a key or button does: hold a cabinet input, or run an
+// action when Action is set
+type Binding struct {
+	Input  machine.Input
+	Action Action
+}
+
+// playerInputs are the controller targets that follow the controller's
+// player: "fire" is p1fire on the first controller and p2fire on the second
+var playerInputs = map[string]bool{"fire": true, "left": true, "right": true, "start": true}
+
+// Players is the number of controllers mapped
+const Players = 2
+
+// CabinetKeys are the cabinet inputs of both key maps, so the game plays the
+// same in the SDL window and the terminal
+var CabinetKeys = map[string]string{
+	"c": "coin", "space": "p1start", "1": "p1start", "2": "p2start",
+	"w": "p1fire", "up": "p1fire", "a": "p1left", "left": "p1left", "d": "p1right", "right": "p1right",
+	"t": "tilt", "i": "p2fire", "j": "p2left", "l": "p2right",
+}
+
+// DefaultKeys are the bindings of the SDL window, besides CabinetKeys
+var DefaultKeys = withCabinetKeys(map[string]string{
+	"tab": "fast-forward", "esc": "quit",
+	"f2": "save-state", "f4": "load-state", "f3": "dip-menu", "f9": "dip-change",
+	"f5": "pause", "f6": "frame-advance", "f7": "slow-motion", ",": "speed-down", ".": "speed-up",
+	"f8": "filter", "f10": "gif", "f11": "fullscreen", "alt+enter": "fullscreen", "f12": "screenshot",
+	"[": "debug-cpu", "]": "debug-io", "p": "debug-memory",
+})
+
+// DefaultTerminalKeys are the bindings of -display=terminal. Terminals may
+// swallow function keys, so every action also has a letter.
+var DefaultTerminalKeys = withCabinetKeys(map[string]string{
+	"esc": "quit", "q": "quit", "ctrl+c": "quit",
+	"f5": "pause", "p": "pause", "f6": "frame-advance", "f12": "screenshot", "s": "screenshot",
+	"f10": "gif", "g": "gif", "f8": "filter", "v": "filter",
+	"f2": "save-state", "f4": "load-state", "f3": "dip-menu", "m": "dip-menu", "f9": "dip-change", "n": "dip-change",
+})
+
+// withCabinetKeys adds CabinetKeys to the action keys of a key map
+func withCabinetKeys(actions map[string]string) map[string]string {
+	for key, target := range CabinetKeys {
+		actions[key] = target
+	}
+	return actions
+}
+
+// DefaultButtons bind game controllers (SDL button names) and plain
+// joysticks (button0, button1, ...; hats as the d-pad; the first two axes as
+// the left stick)
+var DefaultButtons = map[string]string{
+	"a": "fire", "b": "fire", "x": "fire", "y": "fire",
+	"dpleft": "left", "dpright": "right", "leftx-": "left", "leftx+": "right",
+	"start": "start", "back": "coin",
+	"button0": "fire", "button1": "fire",
+}
+
+// DefaultDeadzone is the stick travel ignored around the centre
+const DefaultDeadzone = 8000
+
+// Config is a key and controller map file (JSON). Its entries are added to
+// the defaults, replacing them for the same key or button; a target of
+// "none" unbinds one.
+//
+//	{
+//	  "keys": {"left ctrl": "p1fire", "f1": "save-state", "a": "none"},
+//	  "terminal_keys": {"z": "p1fire"},
+//	  "buttons": {"rightshoulder": "fire", "guide": "pause"},
+//	  "deadzone": 8000
+//	}
+//
+// Targets are cabinet inputs (see machine.Inputs, or "port.bit"), actions
+// (see ActionNames), or for buttons "fire", "left", "right" and "start",
+// which go to the controller's player.
+type Config struct {
+	Keys         map[string]string `json:"keys,omitempty"`
+	TerminalKeys map[string]string `json:"terminal_keys,omitempty"`
+	Buttons      map[string]string `json:"buttons,omitempty"`
+	// Deadzone is the stick travel (0-32767) ignored around the centre
+	Deadzone int `json:"deadzone,omitempty"`
+}
+
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("loadConfig: failed reading file: %v", err)
+	}
+	var c Config
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("loadConfig: %s: %v", path, err)
+	}
+	return &c, nil
+}
+
+// Map is a resolved config
+type Map struct {
+	Keys map[string]Binding
+	// Buttons holds the bindings of each player's controller
+	Buttons  [Players]map[string]Binding
+	Deadzone int16
+}
+
+// Resolve merges c (which may be nil) over the defaults for the SDL window,
+// or for the terminal, and checks every target
+func (c *Config) Resolve(terminal bool) (*Map, error) {
+	if c == nil {
+		c = &Config{}
+	}
+	defaultKeys, keys := DefaultKeys, c.Keys
+	if terminal {
+		defaultKeys, keys = DefaultTerminalKeys, c.TerminalKeys
+	}
+	m := &Map{Keys: map[string]Binding{}, Deadzone: DefaultDeadzone}
+	err := merge(defaultKeys, keys, KeyName, func(name, target string) error {
+		b, err := parseTarget(target, -1)
+		m.Keys[name] = b
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	for p := range m.Buttons {
+		m.Buttons[p] = map[string]Binding{}
+		player := p
+		err := merge(DefaultButtons, c.Buttons, strings.ToLower, func(name, target string) error {
+			b, err := parseTarget(target, player)
+			m.Buttons[player][name] = b
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if c.Deadzone < 0 || c.Deadzone > 32767 {
+		return nil, fmt.Errorf("deadzone %d out of range (0-32767)", c.Deadzone)
+	} else if c.Deadzone > 0 {
+		m.Deadzone = int16(c.Deadzone)
+	}
+	return m, nil
+}
+
+// merge calls bind for the defaults overridden by config, skipping "none"
+func merge(defaults, config map[string]string, normalise func(string) string, bind func(name, target string) error) error {
+	merged := map[string]string{}
+	for name, target := range defaults {
+		merged[normalise(name)] = target
+	}
+	for name, target := range config {
+		merged[normalise(name)] = target
+	}
+	for name, target := range merged {
+		if target == "none" || target == "" {
+			continue
+		}
+		if err := bind(name, target); err != nil {
+			return fmt.Errorf("binding %q: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// parseTarget resolves a binding target. player is the controller's player
+// (0 or 1), or -1 for keys.
+func parseTarget(target string, player int) (Binding, error) {
+	if actions[Action(target)] {
+		return Binding{Action: Action(target)}, nil
+	}
+	if playerInputs[target] {
+		if player < 0 {
+			return Binding{}, fmt.Errorf("%q is only for controllers (use p1%s or p2%s)", target, target, target)
+		}
+		target = fmt.Sprintf("p%d%s", player+1, target)
+	}
+	in, err := machine.ParseInput(target)
+	if err != nil {
+		return Binding{}, fmt.Errorf("%v, or an action (%s)", err, strings.Join(ActionNames(), ", "))
+	}
+	return Binding{Input: in}, nil
+}
+
+// keyAliases maps SDL key names to the terminal's
+var keyAliases = map[string]string{"return": "enter", "escape": "esc"}
+
+// KeyName normalises a key name, so SDL's ("Return", "Left Ctrl") and the
+// terminal's ("enter") agree. Modifiers come first, as in "alt+enter".
+func KeyName(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	prefix := ""
+	for _, mod := range []string{"ctrl+", "alt+"} {
+		if strings.HasPrefix(name, mod) && len(name) > len(mod) {
+			prefix += mod
+			name = name[len(mod):]
+		}
+	}
+	if alias, ok := keyAliases[name]; ok {
+		name = alias
+	}
+	return prefix + name
+}
+
+// Key looks up a key, trying it with its modifiers first (see KeyName)
+func (m *Map) Key(name string) (Binding, bool) {
+	name = KeyName(name)
+	if b, ok := m.Keys[name]; ok {
+		return b, true
+	}
+	if i := strings.LastIndexByte(name, '+'); i >= 0 && i < len(name)-1 {
+		b, ok := m.Keys[name[i+1:]]
+		return b, ok
+	}
+	return Binding{}, false
+}
+
+// Button looks up a controller button (or axis direction) of player
+func (m *Map) Button(player int, name string) (Binding, bool) {
+	if player < 0 || player >= Players {
+		return Binding{}, false
+	}
+	b, ok := m.Buttons[player][strings.ToLower(name)]
+	return b, ok
+}
+
+// AxisDirections turns a stick position into the states of its negative and
+// positive directions, named axis+"-" and axis+"+"
+func (m *Map) AxisDirections(value int16) (negative, positive bool) {
+	return value < -m.Deadzone, value > m.Deadzone
+}
@@ -0,0 +1,120 @@
+package input
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"intel8080/machine"
+)
+
+func TestDefaults(t *testing.T) {
+	for _, terminal := range []bool{false, true} {
+		m, err := (*Config)(nil).Resolve(terminal)
+		if err != nil {
+			t.Fatalf("terminal %v: Resolve failed: %v\n", terminal, err)
+		}
+		if b, ok := m.Key("c"); !ok || b.Input != machine.Inputs["coin"] {
+			t.Fatalf("terminal %v: expected C to insert a coin, got %+v\n", terminal, b)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	c := &Config{
+		Keys:    map[string]string{"Left Ctrl": "p1fire", "a": "none", "F9": "save-state", "K": "0.0"},
+		Buttons: map[string]string{"rightshoulder": "fire", "guide": "pause"},
+	}
+	m, err := c.Resolve(false)
+	if err != nil {
+		t.Fatalf("Resolve failed: %v\n", err)
+	}
+	if b, _ := m.Key("left ctrl"); b.Input != machine.Inputs["p1fire"] {
+		t.Fatalf("expected Left Ctrl to fire, got %+v\n", b)
+	}
+	if _, ok := m.Key("A"); ok {
+		t.Fatalf("expected A to be unbound\n")
+	}
+	if b, _ := m.Key("f9"); b.Action != ActionSaveState {
+		t.Fatalf("expected F9 to save state, got %+v\n", b)
+	}
+	if b, _ := m.Key("k"); b.Input != (machine.Input{Port: 0, Bit: 0}) {
+		t.Fatalf("expected K to be port 0 bit 0, got %+v\n", b)
+	}
+	// Defaults not mentioned are kept
+	if b, _ := m.Key("d"); b.Input != machine.Inputs["p1right"] {
+		t.Fatalf("expected D to stay bound, got %+v\n", b)
+	}
+
+	// Player inputs follow the controller
+	if b, _ := m.Button(0, "rightshoulder"); b.Input != machine.Inputs["p1fire"] {
+		t.Fatalf("expected P1's shoulder to fire for P1, got %+v\n", b)
+	}
+	if b, _ := m.Button(1, "rightshoulder"); b.Input != machine.Inputs["p2fire"] {
+		t.Fatalf("expected P2's shoulder to fire for P2, got %+v\n", b)
+	}
+	if b, _ := m.Button(1, "start"); b.Input != machine.Inputs["p2start"] {
+		t.Fatalf("expected P2's start button to start P2, got %+v\n", b)
+	}
+	if b, _ := m.Button(1, "guide"); b.Action != ActionPause {
+		t.Fatalf("expected the guide button to pause, got %+v\n", b)
+	}
+	if _, ok := m.Button(2, "a"); ok {
+		t.Fatalf("expected no third player\n")
+	}
+}
+
+func TestResolveErrors(t *testing.T) {
+	for _, c := range []*Config{
+		{Keys: map[string]string{"x": "jump"}},
+		{Keys: map[string]string{"x": "fire"}}, // player inputs are for controllers
+		{Buttons: map[string]string{"a": "9.9"}},
+		{Deadzone: 40000},
+	} {
+		if _, err := c.Resolve(false); err == nil {
+			t.Errorf("expected an error for %+v\n", c)
+		}
+	}
+}
+
+func TestKeyNames(t *testing.T) {
+	m, _ := (&Config{}).Resolve(false)
+	if b, _ := m.Key("Return"); b.Action != "" {
+		t.Fatalf("expected Enter alone to be unbound, got %+v\n", b)
+	}
+	if b, _ := m.Key("alt+Return"); b.Action != ActionFullscreen {
+		t.Fatalf("expected Alt+Enter to toggle fullscreen, got %+v\n", b)
+	}
+	// Unbound modifier combinations fall back to the key
+	if b, _ := m.Key("ctrl+Escape"); b.Action != ActionQuit {
+		t.Fatalf("expected Ctrl+Esc to fall back to Esc, got %+v\n", b)
+	}
+}
+
+func TestAxisDirections(t *testing.T) {
+	m, _ := (&Config{Deadzone: 1000}).Resolve(false)
+	for _, tc := range []struct {
+		value    int16
+		neg, pos bool
+	}{{0, false, false}, {-1000, false, false}, {-1001, true, false}, {1001, false, true}, {-32768, true, false}} {
+		if neg, pos := m.AxisDirections(tc.value); neg != tc.neg || pos != tc.pos {
+			t.Errorf("%d: expected %v/%v, got %v/%v\n", tc.value, tc.neg, tc.pos, neg, pos)
+		}
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	_ = os.WriteFile(path, []byte(`{"keys": {"z": "p1fire"}, "deadzone": 5000}`), 0o600)
+	c, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v\n", err)
+	}
+	if c.Keys["z"] != "p1fire" || c.Deadzone != 5000 {
+		t.Fatalf("unexpected config %+v\n", c)
+	}
+	_ = os.WriteFile(path, []byte(`{"keys": [}`), 0o600)
+	if _, err := LoadConfig(path); err == nil {
+		t.Fatalf("expected an error for bad JSON\n")
+	}
+}
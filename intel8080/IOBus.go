@@ -4,14 +4,51 @@ import (
 	"fmt"
 )
 
+// IOBus is the Space Invaders I/O board. The port map:
+//
+//	IN 0	inputs: bit 0 DIP switch 4, bits 1-3 always 1, bits 4-6 P1 shoot/left/right
+//	IN 1	inputs: coin, P2 start, P1 start, always 1, P1 shoot/left/right
+//	IN 2	inputs: DIP switches, tilt, P2 shoot/left/right
+//	IN 3	shift register result
+//	OUT 2	shift amount (bits 0-2)
+//	OUT 3	sound 1 (UFO, shot, player death, invader death, extended play, amp enable)
+//	OUT 4	shift register data
+//	OUT 5	sound 2 (fleet, UFO hit), flip screen, coin counter, lamp
+//	OUT 6	watchdog
+//
+// The bit by bit layout is documented in Read and Write.
 type IOBus struct {
-	DEBUG   bool
+	DEBUG bool
+	// Cocktail selects the cocktail cabinet: player 2 has their own controls
+	// and the screen flips for their turn. Upright cabinets share player 1's.
+	Cocktail bool
+	// OnSoundWrite, if set, is called for every write to the sound ports
+	// (3 and 5), so sound can be timed to the cycle
+	OnSoundWrite func(port, value byte)
+	// OnWatchdog, if set, is called when the game kicks the watchdog
+	OnWatchdog func()
+	// OnInput, if set, is called for every HandleInput, so input changes can
+	// be recorded
+	OnInput func(port, bit uint8, pressed bool)
+	// CoinCounter counts the pulses sent to the coin meter
+	CoinCounter uint64
+	// InputReads counts reads of the player input ports (1 and 2). A frame
+	// in which it doesn't change is a lag frame: inputs held through it
+	// make no difference.
+	InputReads uint64
+
 	bitMask byte
 	shiftH  byte
 	shiftL  byte
 	offset  byte
+	input0  byte
 	input1  byte
 	input2  byte
+	// Output latches of the sound ports
+	port3 byte
+	port5 byte
+
+	history *history
 }
 
 func NewIOBus() *IOBus {
@@ -24,20 +61,35 @@ func NewIOBus() *IOBus {
 
 func (bus *IOBus) Read(b byte) byte {
 	switch b {
+	case 0x00:
+		//Read 0 (only read by the self test)
+		//BIT	0	DIP switch 4
+		//1-3	always 1
+		//4	P1 shoot button
+		//5	P1 joystick left
+		//6	P1 joystick right
+		//7	not connected
+		value := bus.input0&0x01 | port0AlwaysSet | bus.input1&p2Controls
+		if bus.DEBUG {
+			fmt.Printf("IOBus.Read(0x%02x) = 0b%08b - read input\n", b, value)
+		}
+		return value
 	case 0x01:
 		//Read 1
-		//BIT	0	coin (0 when active)
+		//BIT	0	coin (1 while inserted)
 		//1	P2 start button
 		//2	P1 start button
-		//3	?
+		//3	always 1
 		//4	P1 shoot button
 		//5	P1 joystick left
 		//6	P1 joystick right
-		//7	?
+		//7	not connected
+		bus.InputReads++
+		value := bus.input1 | port1AlwaysSet
 		if bus.DEBUG {
-			fmt.Printf("IOBus.Read(0x%02x) = 0b%08b - read input\n", b, bus.input1)
+			fmt.Printf("IOBus.Read(0x%02x) = 0b%08b - read input\n", b, value)
 		}
-		return bus.input1
+		return value
 	case 0x02:
 		//Read 2
 		//BIT	0,1	dipswitch number of lives (0:3,1:4,2:5,3:6)
@@ -47,10 +99,17 @@ func (bus *IOBus) Read(b byte) byte {
 		//5	P2 joystick left
 		//6	P2 joystick right
 		//7	dipswitch coin info 1:off,0:on
+		//(bits 4-6 are P1's controls on upright cabinets)
+		bus.InputReads++
+		value := bus.input2
+		if !bus.Cocktail {
+			// Upright cabinets wire player 1's controls to player 2's bits too
+			value = value&^p2Controls | bus.input1&p2Controls
+		}
 		if bus.DEBUG {
-			fmt.Printf("IOBus.Read(0x%02x) = 0b%08b - read input\n", b, bus.input2)
+			fmt.Printf("IOBus.Read(0x%02x) = 0b%08b - read input\n", b, value)
 		}
-		return bus.input2
+		return value
 	case 0x03:
 		shift := uint16(bus.shiftH)<<8 | uint16(bus.shiftL)
 		result := byte(shift >> (8 - bus.offset))
@@ -73,6 +132,37 @@ func (bus *IOBus) Write(b byte, A byte) {
 		if bus.DEBUG {
 			fmt.Printf("IOBus.Write(0x%02x, 0b%08b) - offset = 0b%08b\n", b, A, bus.offset)
 		}
+	case 0x03:
+		//BIT	0	UFO (repeats)
+		//1	shot
+		//2	flash (player death)
+		//3	invader death
+		//4	extended play
+		//5	amp enable
+		bus.port3 = A
+		if bus.OnSoundWrite != nil {
+			bus.OnSoundWrite(b, A)
+		}
+	case 0x05:
+		//BIT	0-3	fleet movement, one tone per step
+		//4	UFO hit
+		//5	flip screen (cocktail cabinet, player 2's turn)
+		//6	coin counter, pulsed once per coin
+		//7	lamp
+		//(Invaders leaves bits 6 and 7 alone; they are spare outputs on the
+		//board, wired up here for hacks and other games)
+		if A&^bus.port5&coinCounterBit != 0 {
+			bus.CoinCounter++
+		}
+		bus.port5 = A
+		if bus.OnSoundWrite != nil {
+			bus.OnSoundWrite(b, A)
+		}
+	case 0x06:
+		//Any write resets the watchdog timer
+		if bus.OnWatchdog != nil {
+			bus.OnWatchdog()
+		}
 	case 0x04:
 		bus.shiftL = bus.shiftH
 		bus.shiftH = A
@@ -84,8 +174,92 @@ func (bus *IOBus) Write(b byte, A byte) {
 	}
 }
 
+const (
+	// p2Controls are the shoot, left and right bits, shared by ports 0, 1 and 2
+	p2Controls = 0x70
+	// Input bits pulled high on the board
+	port0AlwaysSet = 0x0E
+	port1AlwaysSet = 0x08
+
+	coinCounterBit = 0x40
+	lampBit        = 0x80
+)
+
+// Lamp reports whether the lamp output (port 5 bit 7) is lit
+func (bus *IOBus) Lamp() bool {
+	return bus.port5&lampBit != 0
+}
+
+// Reset clears the shift register and the output latches, as the board's
+// reset line does. Inputs are switches and stay as they are.
+func (bus *IOBus) Reset() {
+	bus.shiftH, bus.shiftL, bus.offset = 0, 0, 0
+	bus.port3, bus.port5 = 0, 0
+}
+
+// SoundLatches returns the last values written to the sound ports 3 and 5
+func (bus *IOBus) SoundLatches() (port3, port5 byte) {
+	return bus.port3, bus.port5
+}
+
+// FlipScreen reports whether the game asked for the screen to be turned for
+// player 2. Only cocktail cabinets act on it.
+func (bus *IOBus) FlipScreen() bool {
+	return bus.Cocktail && bus.port5&0x20 != 0
+}
+
+func (bus *IOBus) getState() IOState {
+	return IOState{
+		ShiftH:      bus.shiftH,
+		ShiftL:      bus.shiftL,
+		ShiftOffset: bus.offset,
+		Input0:      bus.input0,
+		Input1:      bus.input1,
+		Input2:      bus.input2,
+		Port3:       bus.port3,
+		Port5:       bus.port5,
+	}
+}
+
+func (bus *IOBus) setState(s IOState) {
+	bus.shiftH = s.ShiftH
+	bus.shiftL = s.ShiftL
+	bus.offset = s.ShiftOffset
+	bus.input0 = s.Input0
+	bus.input1 = s.Input1
+	bus.input2 = s.Input2
+	bus.port3 = s.Port3
+	bus.port5 = s.Port5
+}
+
+// InputBits returns the input bits set with HandleInput on port, without
+// the always-set bits or the upright cabinet's wiring
+func (bus *IOBus) InputBits(portNumber uint8) byte {
+	switch portNumber {
+	case 0:
+		return bus.input0
+	case 1:
+		return bus.input1
+	case 2:
+		return bus.input2
+	}
+	return 0
+}
+
 func (bus *IOBus) HandleInput(portNumber uint8, bitNumber uint8, pressed bool) {
-	if portNumber == 1 {
+	if bus.history != nil {
+		bus.history.addInput(InputEvent{Port: portNumber, Bit: bitNumber, Pressed: pressed})
+	}
+	if bus.OnInput != nil {
+		bus.OnInput(portNumber, bitNumber, pressed)
+	}
+	if portNumber == 0 {
+		if pressed {
+			bus.input0 |= 1 << bitNumber
+		} else {
+			bus.input0 &= ^(1 << bitNumber)
+		}
+	} else if portNumber == 1 {
 		if pressed {
 			bus.input1 |= 1 << bitNumber
 		} else {
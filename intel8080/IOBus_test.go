@@ -0,0 +1,69 @@
+package intel8080
+
+import "testing"
+
+func TestIOBusCabinet(t *testing.T) {
+	bus := NewIOBus()
+	bus.HandleInput(1, 4, true) // P1 shoot
+	bus.HandleInput(2, 6, true) // P2 right
+
+	// Upright: player 2 uses player 1's controls
+	if got := bus.Read(2) & p2Controls; got != 0x10 {
+		t.Fatalf("upright: expected P1 shoot on port 2, got %08b\n", got)
+	}
+	bus.Write(5, 0x20)
+	if bus.FlipScreen() {
+		t.Fatalf("upright cabinets never flip the screen\n")
+	}
+
+	bus.Cocktail = true
+	if got := bus.Read(2) & p2Controls; got != 0x40 {
+		t.Fatalf("cocktail: expected P2 right on port 2, got %08b\n", got)
+	}
+	if !bus.FlipScreen() {
+		t.Fatalf("cocktail: expected port 5 bit 5 to flip the screen\n")
+	}
+	bus.Write(5, 0x00)
+	if bus.FlipScreen() {
+		t.Fatalf("cocktail: expected the screen to turn back for player 1\n")
+	}
+}
+
+func TestIOBusPortMap(t *testing.T) {
+	bus := NewIOBus()
+	if got := bus.Read(0); got != 0x0E {
+		t.Fatalf("port 0: expected bits 1-3 set, got %08b\n", got)
+	}
+	if got := bus.Read(1); got != 0x08 {
+		t.Fatalf("port 1: expected bit 3 set, got %08b\n", got)
+	}
+
+	bus.HandleInput(0, 0, true) // DIP switch 4
+	bus.HandleInput(1, 1, true) // P2 start
+	bus.HandleInput(1, 5, true) // P1 left
+	if got := bus.Read(0); got != 0x2F {
+		t.Fatalf("port 0: expected the DIP switch and P1 left, got %08b\n", got)
+	}
+	if got := bus.Read(1); got != 0x2A {
+		t.Fatalf("port 1: expected P2 start and P1 left, got %08b\n", got)
+	}
+
+	// The coin counter counts rising edges of port 5 bit 6
+	for _, v := range []byte{0x40, 0x40, 0x00, 0xC0, 0x80} {
+		bus.Write(5, v)
+	}
+	if bus.CoinCounter != 2 {
+		t.Fatalf("expected 2 coin counter pulses, got %d\n", bus.CoinCounter)
+	}
+	if !bus.Lamp() {
+		t.Fatalf("expected the lamp to be lit\n")
+	}
+
+	kicks := 0
+	bus.OnWatchdog = func() { kicks++ }
+	bus.Write(6, 0)
+	bus.Write(6, 0xff)
+	if kicks != 2 {
+		t.Fatalf("expected 2 watchdog kicks, got %d\n", kicks)
+	}
+}
@@ -1,7 +1,9 @@
 package intel8080
 
 import (
+	"crypto/sha1"
 	"fmt"
+	"hash/crc32"
 	"os"
 )
 
@@ -9,6 +11,28 @@ type Memory struct {
 	DEBUG          bool
 	bytes          []byte
 	readOnlyBlocks []protectedBlock
+	roms           []RomInfo
+
+	// Called on every CPU access when a sanitizer is attached
+	readHook  func(address uint16)
+	writeHook func(address uint16)
+}
+
+// RomInfo identifies a ROM file (or the patched ROM image) loaded into memory
+type RomInfo struct {
+	Path  string `json:"path"`
+	Size  int    `json:"size"`
+	CRC32 string `json:"crc32"`
+	SHA1  string `json:"sha1"`
+}
+
+func newRomInfo(path string, data []byte) RomInfo {
+	return RomInfo{
+		Path:  path,
+		Size:  len(data),
+		CRC32: fmt.Sprintf("%08x", crc32.ChecksumIEEE(data)),
+		SHA1:  fmt.Sprintf("%x", sha1.Sum(data)),
+	}
 }
 
 type protectedBlock struct {
@@ -37,27 +61,49 @@ func (m *Memory) GetSlice(start uint16, end uint16) []byte {
 
 func (m *Memory) GetMemoryCopy() []byte {
 	bytesCopy := make([]byte, len(m.bytes))
-	copy(m.bytes, bytesCopy)
+	copy(bytesCopy, m.bytes)
 	return bytesCopy
 }
 
+// LoadedRoms returns the ROM files loaded so far, with their checksums
+func (m *Memory) LoadedRoms() []RomInfo {
+	return m.roms
+}
+
+// IsMapped reports whether address is backed by memory
+func (m *Memory) IsMapped(address uint16) bool {
+	return int(address) < len(m.bytes)
+}
+
+// Read returns the byte at address, or 0 if address is unmapped
 func (m *Memory) Read(address uint16) byte {
+	if !m.IsMapped(address) {
+		return 0
+	}
 	byte := m.bytes[address]
+	if m.readHook != nil {
+		m.readHook(address)
+	}
 	if m.DEBUG {
 		// fmt.Printf("READ 0b%08b / 0x%02x <- (0x%04x)\n", byte, byte, address)
 	}
 	return byte
 }
 
-func (m *Memory) Write(address uint16, b byte) {
+// Write stores b at address. Writes to protected blocks return a *ProtectedWriteError
+// and writes outside of memory an *UnmappedAccessError; neither modifies memory.
+func (m *Memory) Write(address uint16, b byte) error {
 	address = address & 0x3FFF // mirror from 0x4000 - 0xFFFF (technically not needed)
+	if !m.IsMapped(address) {
+		return &UnmappedAccessError{Address: address, Write: true}
+	}
 
 	// Protect read only blocks
 	for _, protectedBlock := range m.readOnlyBlocks {
 		start := protectedBlock.start
 		end := protectedBlock.end
 		if address >= start && address <= end {
-			panic(fmt.Sprintf("Write to read-only memory location %d in protected block [%d:%d]\n", address, start, end))
+			return &ProtectedWriteError{Address: address, BlockStart: start, BlockEnd: end}
 		}
 	}
 	if m.DEBUG {
@@ -69,22 +115,49 @@ func (m *Memory) Write(address uint16, b byte) {
 			// fmt.Printf("WRITE 0b%08b / 0x%02x -> (0x%04x)\n", b, b, address)
 		}
 	}
+	if m.writeHook != nil {
+		m.writeHook(address)
+	}
 	m.bytes[address] = b
+	return nil
 }
 
 func (m *Memory) LoadRomFiles(filenames []string, offset uint16, protectRom bool) (uint16, error) {
+	return m.LoadPatchedRomFiles(filenames, nil, offset, protectRom)
+}
+
+// LoadPatchedRomFiles concatenates the ROM files into one image, applies the
+// patches to it in order and writes the result to memory at offset
+func (m *Memory) LoadPatchedRomFiles(filenames []string, patches []RomPatch, offset uint16, protectRom bool) (uint16, error) {
 	startOffset := offset
+	var image []byte
 	for _, romPath := range filenames {
 		fmt.Printf("loading %s\n", romPath)
 		data, err := os.ReadFile(romPath)
 		if err != nil {
 			return 0, fmt.Errorf("loadRom: failed reading file: %v", err)
 		}
+		image = append(image, data...)
+		m.roms = append(m.roms, newRomInfo(romPath, data))
+	}
+
+	for _, patch := range patches {
+		fmt.Printf("applying patch %s\n", patch.Path)
+		patched, err := ApplyPatchFile(image, patch)
+		if err != nil {
+			return 0, fmt.Errorf("loadRom: %v", err)
+		}
+		image = patched
+	}
+	if len(patches) > 0 {
+		m.roms = append(m.roms, newRomInfo("(patched image)", image))
+	}
 
-		for _, b := range data {
-			m.Write(offset, b)
-			offset++
+	for _, b := range image {
+		if err := m.Write(offset, b); err != nil {
+			return 0, fmt.Errorf("loadRom: %v", err)
 		}
+		offset++
 	}
 	if protectRom {
 		m.Protect(startOffset, offset-1)
@@ -0,0 +1,103 @@
+package intel8080
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// CrashBundle is everything needed to investigate a failure after the fact:
+// the machine state at the moment of failure, recent history and ROM checksums.
+// It is stored as indented JSON, with State holding a binary Dump (base64).
+type CrashBundle struct {
+	Version int          `json:"version"`
+	Time    time.Time    `json:"time"`
+	Error   string       `json:"error"`
+	Cycles  uint64       `json:"cycles"`
+	Roms    []RomInfo    `json:"roms"`
+	State   []byte       `json:"state"`
+	Trace   []TraceEntry `json:"trace"`
+	IO      []IOEvent    `json:"io"`
+	Inputs  []InputEvent `json:"inputs"`
+}
+
+const crashBundleVersion = 1
+
+// CrashBundle captures the current machine state and history along with the error that caused the crash
+func (cpu *CPU) CrashBundle(cause error) *CrashBundle {
+	state, _ := cpu.Dump().MarshalBinary()
+	return &CrashBundle{
+		Version: crashBundleVersion,
+		Time:    time.Now(),
+		Error:   fmt.Sprint(cause),
+		Cycles:  cpu.Cycles,
+		Roms:    cpu.memory.LoadedRoms(),
+		State:   state,
+		Trace:   cpu.Trace(),
+		IO:      cpu.IOHistory(),
+		Inputs:  cpu.InputHistory(),
+	}
+}
+
+func WriteCrashBundle(filename string, b *CrashBundle) error {
+	data, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return fmt.Errorf("writeCrashBundle: %v", err)
+	}
+	if err := os.WriteFile(filename, data, 0o600); err != nil {
+		return fmt.Errorf("writeCrashBundle: %v", err)
+	}
+	return nil
+}
+
+func ReadCrashBundle(filename string) (*CrashBundle, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("readCrashBundle: failed reading file: %v", err)
+	}
+	b := CrashBundle{}
+	if err := json.Unmarshal(data, &b); err != nil {
+		return nil, fmt.Errorf("readCrashBundle: %s: %v", filename, err)
+	}
+	if b.Version != crashBundleVersion {
+		return nil, fmt.Errorf("readCrashBundle: %s: unsupported version %d", filename, b.Version)
+	}
+	return &b, nil
+}
+
+// Machine rebuilds a CPU from the bundle, positioned at the moment of failure.
+// The bundled history is loaded so Trace, IOHistory and InputHistory report it.
+func (b *CrashBundle) Machine() (*CPU, error) {
+	d := Dump{}
+	if err := d.UnmarshalBinary(b.State); err != nil {
+		return nil, fmt.Errorf("crash bundle: %v", err)
+	}
+	if len(d.Memory) == 0 || len(d.Memory) > 0x10000 {
+		return nil, fmt.Errorf("crash bundle: bad memory size %d", len(d.Memory))
+	}
+
+	memory := NewMemory(uint16(len(d.Memory) - 1))
+	memory.roms = b.Roms
+	cpu := NewCPU(NewIOBus(), memory)
+	if err := cpu.Restore(&d); err != nil {
+		return nil, err
+	}
+	cpu.Cycles = b.Cycles
+
+	size := DefaultHistorySize
+	if len(b.Trace) > size {
+		size = len(b.Trace)
+	}
+	if len(b.IO) > size {
+		size = len(b.IO)
+	}
+	if len(b.Inputs) > size {
+		size = len(b.Inputs)
+	}
+	cpu.EnableHistory(size)
+	cpu.history.trace = append(cpu.history.trace, b.Trace...)
+	cpu.history.io = append(cpu.history.io, b.IO...)
+	cpu.history.inputs = append(cpu.history.inputs, b.Inputs...)
+	return cpu, nil
+}
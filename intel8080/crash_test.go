@@ -0,0 +1,84 @@
+package intel8080
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCrashBundleRoundTrip(t *testing.T) {
+	ioBus := NewIOBus()
+	memory := NewMemory(0x3FFF)
+	tCpu := NewCPU(ioBus, memory)
+	tCpu.EnableHistory(4)
+
+	// MVI A,0x42 / OUT 2 / INR A x4 / NOP
+	program := []byte{0x3E, 0x42, 0xD3, 0x02, 0x3C, 0x3C, 0x3C, 0x3C, 0x00}
+	for i, b := range program {
+		memory.Write(uint16(i), b)
+	}
+	ioBus.HandleInput(1, 4, true)
+	for i := 0; i < 6; i++ {
+		if _, err := tCpu.Step(); err != nil {
+			t.Fatalf("Step failed: %v\n", err)
+		}
+	}
+
+	trace := tCpu.Trace()
+	if len(trace) != 4 || trace[0].PC != 0x0004 || trace[3].PC != 0x0007 {
+		t.Fatalf("unexpected trace: %+v\n", trace)
+	}
+	if io := tCpu.IOHistory(); len(io) != 1 || !io[0].Out || io[0].Value != 0x42 {
+		t.Fatalf("unexpected IO history: %+v\n", io)
+	}
+
+	filename := filepath.Join(t.TempDir(), "crash.json")
+	if err := WriteCrashBundle(filename, tCpu.CrashBundle(errors.New("boom"))); err != nil {
+		t.Fatalf("WriteCrashBundle failed: %v\n", err)
+	}
+	bundle, err := ReadCrashBundle(filename)
+	if err != nil {
+		t.Fatalf("ReadCrashBundle failed: %v\n", err)
+	}
+	if bundle.Error != "boom" {
+		t.Errorf("expected error %q, got %q\n", "boom", bundle.Error)
+	}
+
+	replayed, err := bundle.Machine()
+	if err != nil {
+		t.Fatalf("Machine failed: %v\n", err)
+	}
+	if !reflect.DeepEqual(replayed.Dump(), tCpu.Dump()) {
+		t.Fatalf("replayed state mismatch:\n%s\n%s\n", replayed.Dump(), tCpu.Dump())
+	}
+	if !reflect.DeepEqual(replayed.Trace(), trace) || len(replayed.InputHistory()) != 1 {
+		t.Fatalf("replayed history mismatch\n")
+	}
+}
+
+func TestRunMonitor(t *testing.T) {
+	memory := NewMemory(0x3FFF)
+	tCpu := NewCPU(NewIOBus(), memory)
+	// LXI H,0x2400 / MVI M,0x99 / JMP 0x0000
+	program := []byte{0x21, 0x00, 0x24, 0x36, 0x99, 0xC3, 0x00, 0x00}
+	for i, b := range program {
+		memory.Write(uint16(i), b)
+	}
+
+	out := bytes.Buffer{}
+	in := strings.NewReader("d 0 3\ns 2\nm 2400 4\nbogus\nq\nr\n")
+	if err := RunMonitor(tCpu, in, &out); err != nil {
+		t.Fatalf("RunMonitor failed: %v\n", err)
+	}
+	for _, want := range []string{"0000  lxi h,#$2400", "0003  mvi M,#$99", "0005  jmp $0000", "2400: 99", "unknown command"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("monitor output missing %q:\n%s\n", want, out.String())
+		}
+	}
+	if tCpu.PC != 0x0005 {
+		t.Errorf("expected PC 0x0005 after 2 steps, got 0x%04x\n", tCpu.PC)
+	}
+}
@@ -0,0 +1,372 @@
+package intel8080
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Dump is a snapshot of the whole machine: CPU registers, IO bus state and memory.
+//
+// Binary layout (all multi-byte values little-endian):
+//
+//	0x00  8  magic "I8080DMP"
+//	0x08  1  format version (1)
+//	0x09  7  registers A, B, C, D, E, H, L
+//	0x10  2  PC
+//	0x12  2  SP
+//	0x14  1  PSW, in PUSH PSW layout (S Z 0 AC 0 P 1 CY)
+//	0x15  1  interrupt state: bit 0 = enabled, bit 1 = enable deferred by EI,
+//	         bit 2 = halted
+//	0x16  2  length n of the IO block
+//	0x18  n  IO block: shift high, shift low, shift offset, input 1, input 2,
+//	         port 3 latch, port 5 latch, input 0
+//	      4  length m of memory
+//	      m  memory
+//
+// Readers ignore IO block bytes they don't know about, so the IO block may grow
+// without breaking older dumps.
+type Dump struct {
+	A, B, C, D, E, H, L uint8
+	PC, SP              uint16
+	PSW                 uint8
+
+	InterruptsEnabled     bool
+	DeferInterruptsEnable bool
+	Halted                bool
+
+	IO     IOState
+	Memory []byte
+}
+
+// IOState is the IO bus state captured in a Dump
+type IOState struct {
+	ShiftH, ShiftL, ShiftOffset byte
+	Input1, Input2              byte
+	Port3, Port5                byte
+	Input0                      byte
+}
+
+var dumpMagic = []byte("I8080DMP")
+
+const dumpVersion = 1
+
+// Dump captures the current machine state
+func (cpu *CPU) Dump() *Dump {
+	return &Dump{
+		A: cpu.A, B: cpu.B, C: cpu.C, D: cpu.D, E: cpu.E, H: cpu.H, L: cpu.L,
+		PC:  cpu.PC,
+		SP:  cpu.SP,
+		PSW: cpu.getProgramStatus(),
+
+		InterruptsEnabled:     cpu.InterruptsEnabled,
+		DeferInterruptsEnable: cpu.deferInterruptsEnable,
+		Halted:                cpu.Halted,
+
+		IO:     cpu.ioBus.getState(),
+		Memory: cpu.memory.GetMemoryCopy(),
+	}
+}
+
+// Restore loads a previously captured machine state
+func (cpu *CPU) Restore(d *Dump) error {
+	if len(d.Memory) != len(cpu.memory.bytes) {
+		return fmt.Errorf("restore: memory size mismatch (dump has %d bytes, machine has %d)", len(d.Memory), len(cpu.memory.bytes))
+	}
+	cpu.A, cpu.B, cpu.C, cpu.D, cpu.E, cpu.H, cpu.L = d.A, d.B, d.C, d.D, d.E, d.H, d.L
+	cpu.PC = d.PC
+	cpu.SP = d.SP
+	cpu.setProgramStatus(d.PSW)
+	cpu.InterruptsEnabled = d.InterruptsEnabled
+	cpu.deferInterruptsEnable = d.DeferInterruptsEnable
+	cpu.Halted = d.Halted
+	cpu.ioBus.setState(d.IO)
+	copy(cpu.memory.bytes, d.Memory)
+	return nil
+}
+
+func (d *Dump) MarshalBinary() ([]byte, error) {
+	buf := bytes.Buffer{}
+	buf.Write(dumpMagic)
+	buf.WriteByte(dumpVersion)
+	buf.Write([]byte{d.A, d.B, d.C, d.D, d.E, d.H, d.L})
+	_ = binary.Write(&buf, binary.LittleEndian, d.PC)
+	_ = binary.Write(&buf, binary.LittleEndian, d.SP)
+	buf.WriteByte(d.PSW)
+
+	var interrupts byte
+	if d.InterruptsEnabled {
+		interrupts |= 1 << 0
+	}
+	if d.DeferInterruptsEnable {
+		interrupts |= 1 << 1
+	}
+	if d.Halted {
+		interrupts |= 1 << 2
+	}
+	buf.WriteByte(interrupts)
+
+	ioBlock := d.IO.marshal()
+	_ = binary.Write(&buf, binary.LittleEndian, uint16(len(ioBlock)))
+	buf.Write(ioBlock)
+
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(len(d.Memory)))
+	buf.Write(d.Memory)
+	return buf.Bytes(), nil
+}
+
+func (d *Dump) UnmarshalBinary(data []byte) error {
+	r := bytes.NewReader(data)
+	header := make([]byte, len(dumpMagic)+1)
+	if _, err := io.ReadFull(r, header); err != nil || !bytes.Equal(header[:len(dumpMagic)], dumpMagic) {
+		return fmt.Errorf("dump: bad header")
+	}
+	if header[len(dumpMagic)] != dumpVersion {
+		return fmt.Errorf("dump: unsupported version %d", header[len(dumpMagic)])
+	}
+
+	var fixed struct {
+		Regs       [7]uint8
+		PC, SP     uint16
+		PSW        uint8
+		Interrupts uint8
+		IOLen      uint16
+	}
+	if err := binary.Read(r, binary.LittleEndian, &fixed); err != nil {
+		return fmt.Errorf("dump: truncated registers: %v", err)
+	}
+	ioBlock := make([]byte, fixed.IOLen)
+	if _, err := io.ReadFull(r, ioBlock); err != nil {
+		return fmt.Errorf("dump: truncated IO block: %v", err)
+	}
+	var memLen uint32
+	if err := binary.Read(r, binary.LittleEndian, &memLen); err != nil {
+		return fmt.Errorf("dump: truncated memory length: %v", err)
+	}
+	if int(memLen) != r.Len() {
+		return fmt.Errorf("dump: memory length mismatch (header says %d, found %d)", memLen, r.Len())
+	}
+	memory := make([]byte, memLen)
+	_, _ = io.ReadFull(r, memory)
+
+	d.A, d.B, d.C, d.D, d.E, d.H, d.L = fixed.Regs[0], fixed.Regs[1], fixed.Regs[2], fixed.Regs[3], fixed.Regs[4], fixed.Regs[5], fixed.Regs[6]
+	d.PC = fixed.PC
+	d.SP = fixed.SP
+	d.PSW = fixed.PSW
+	d.InterruptsEnabled = fixed.Interrupts&(1<<0) != 0
+	d.DeferInterruptsEnable = fixed.Interrupts&(1<<1) != 0
+	d.Halted = fixed.Interrupts&(1<<2) != 0
+	d.IO.unmarshal(ioBlock)
+	d.Memory = memory
+	return nil
+}
+
+func (s IOState) marshal() []byte {
+	return []byte{s.ShiftH, s.ShiftL, s.ShiftOffset, s.Input1, s.Input2, s.Port3, s.Port5, s.Input0}
+}
+
+func (s *IOState) unmarshal(b []byte) {
+	fields := []*byte{&s.ShiftH, &s.ShiftL, &s.ShiftOffset, &s.Input1, &s.Input2, &s.Port3, &s.Port5, &s.Input0}
+	for i := 0; i < len(fields) && i < len(b); i++ {
+		*fields[i] = b[i]
+	}
+}
+
+func WriteDumpFile(filename string, d *Dump) error {
+	data, _ := d.MarshalBinary()
+	if err := os.WriteFile(filename, data, 0o600); err != nil {
+		return fmt.Errorf("writeDump: %v", err)
+	}
+	return nil
+}
+
+func ReadDumpFile(filename string) (*Dump, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("readDump: failed reading file: %v", err)
+	}
+	d := Dump{}
+	if err := d.UnmarshalBinary(data); err != nil {
+		return nil, fmt.Errorf("readDump: %s: %v", filename, err)
+	}
+	return &d, nil
+}
+
+func (d *Dump) String() string {
+	return fmt.Sprintf("PC: %04x, SP: %04x, Flags: 0x%08b, Regs: [%02x %02x %02x %02x %02x %02x %02x], IE: %v, Shift: %02x%02x >> %d, Inputs: [%08b %08b]",
+		d.PC, d.SP, d.PSW,
+		d.A, d.B, d.C, d.D, d.E, d.H, d.L,
+		d.InterruptsEnabled,
+		d.IO.ShiftH, d.IO.ShiftL, d.IO.ShiftOffset, d.IO.Input1, d.IO.Input2)
+}
+
+// WriteHexdump writes mem[start:end] in the classic 16 bytes per line hexdump layout
+func WriteHexdump(w io.Writer, mem []byte, start, end int) error {
+	if start < 0 || end > len(mem) || start > end {
+		return fmt.Errorf("hexdump: range %04x-%04x out of bounds", start, end)
+	}
+	for line := start &^ 0xF; line < end; line += 16 {
+		sb := strings.Builder{}
+		fmt.Fprintf(&sb, "%04x:", line)
+		ascii := make([]byte, 16)
+		for i := 0; i < 16; i++ {
+			addr := line + i
+			if addr < start || addr >= end {
+				sb.WriteString("   ")
+				ascii[i] = ' '
+				continue
+			}
+			b := mem[addr]
+			fmt.Fprintf(&sb, " %02x", b)
+			if b >= 0x20 && b < 0x7F {
+				ascii[i] = b
+			} else {
+				ascii[i] = '.'
+			}
+		}
+		fmt.Fprintf(&sb, "  |%s|\n", ascii)
+		if _, err := io.WriteString(w, sb.String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteIntelHex writes mem[start:end] as Intel HEX data records followed by an EOF record
+func WriteIntelHex(w io.Writer, mem []byte, start, end int) error {
+	if start < 0 || end > len(mem) || start > end || end > 0x10000 {
+		return fmt.Errorf("intelhex: range %04x-%04x out of bounds", start, end)
+	}
+	for addr := start; addr < end; addr += 16 {
+		n := end - addr
+		if n > 16 {
+			n = 16
+		}
+		record := []byte{byte(n), byte(addr >> 8), byte(addr), 0x00}
+		record = append(record, mem[addr:addr+n]...)
+		if err := writeHexRecord(w, record); err != nil {
+			return err
+		}
+	}
+	return writeHexRecord(w, []byte{0x00, 0x00, 0x00, 0x01})
+}
+
+func writeHexRecord(w io.Writer, record []byte) error {
+	var sum byte
+	for _, b := range record {
+		sum += b
+	}
+	_, err := fmt.Fprintf(w, ":%X%02X\n", record, -sum)
+	return err
+}
+
+// Region names a part of the Space Invaders address space
+type Region string
+
+const (
+	RegionROM    Region = "ROM"
+	RegionRAM    Region = "RAM"
+	RegionStack  Region = "stack"
+	RegionVRAM   Region = "VRAM"
+	RegionMirror Region = "mirror"
+)
+
+const (
+	ramStart  = 0x2000
+	vramStart = 0x2400
+	vramEnd   = 0x4000
+)
+
+// regionOf classifies an address. The stack is the part of RAM at or above
+// stackLow, which is the lowest SP seen in the dumps being compared.
+func regionOf(address int, stackLow uint16) Region {
+	switch {
+	case address < ramStart:
+		return RegionROM
+	case address < vramStart:
+		if stackLow >= ramStart && stackLow <= vramStart && address >= int(stackLow) {
+			return RegionStack
+		}
+		return RegionRAM
+	case address < vramEnd:
+		return RegionVRAM
+	default:
+		return RegionMirror
+	}
+}
+
+// DiffRun is a contiguous run of changed bytes within one region
+type DiffRun struct {
+	Region Region
+	Start  int
+	Old    []byte
+	New    []byte
+}
+
+// DiffDumps compares the memory of two dumps and returns the changed runs, in
+// address order. Runs never span a region boundary.
+func DiffDumps(a, b *Dump) []DiffRun {
+	size := len(a.Memory)
+	if len(b.Memory) < size {
+		size = len(b.Memory)
+	}
+	stackLow := a.SP
+	if b.SP < stackLow {
+		stackLow = b.SP
+	}
+
+	var runs []DiffRun
+	var current *DiffRun
+	for addr := 0; addr < size; addr++ {
+		if a.Memory[addr] == b.Memory[addr] {
+			current = nil
+			continue
+		}
+		region := regionOf(addr, stackLow)
+		if current == nil || current.Region != region {
+			runs = append(runs, DiffRun{Region: region, Start: addr})
+			current = &runs[len(runs)-1]
+		}
+		current.Old = append(current.Old, a.Memory[addr])
+		current.New = append(current.New, b.Memory[addr])
+	}
+	return runs
+}
+
+// WriteDiff writes a human readable comparison of two dumps: changed registers
+// first, then changed memory grouped by region
+func WriteDiff(w io.Writer, a, b *Dump) error {
+	if a.String() != b.String() {
+		fmt.Fprintf(w, "- %s\n+ %s\n", a, b)
+	}
+	if len(a.Memory) != len(b.Memory) {
+		fmt.Fprintf(w, "memory size differs: %d vs %d bytes\n", len(a.Memory), len(b.Memory))
+	}
+
+	runs := DiffDumps(a, b)
+	byRegion := map[Region][]DiffRun{}
+	for _, run := range runs {
+		byRegion[run.Region] = append(byRegion[run.Region], run)
+	}
+	for _, region := range []Region{RegionROM, RegionRAM, RegionStack, RegionVRAM, RegionMirror} {
+		regionRuns := byRegion[region]
+		if len(regionRuns) == 0 {
+			continue
+		}
+		changed := 0
+		for _, run := range regionRuns {
+			changed += len(run.Old)
+		}
+		fmt.Fprintf(w, "%s: %d bytes changed in %d runs\n", region, changed, len(regionRuns))
+		for _, run := range regionRuns {
+			_, err := fmt.Fprintf(w, "  %04x-%04x: % x -> % x\n", run.Start, run.Start+len(run.Old)-1, run.Old, run.New)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package intel8080
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGetMemoryCopy(t *testing.T) {
+	memory := NewMemory(0xFF)
+	memory.Write(0x10, 0xAB)
+	got := memory.GetMemoryCopy()
+	if got[0x10] != 0xAB {
+		t.Fatalf("expected: 0xab, got: 0x%02x\n", got[0x10])
+	}
+	got[0x10] = 0
+	if memory.Read(0x10) != 0xAB {
+		t.Fatalf("GetMemoryCopy returned a slice sharing memory\n")
+	}
+}
+
+func TestDumpRoundTrip(t *testing.T) {
+	ioBus := NewIOBus()
+	memory := NewMemory(0x3FFF)
+	tCpu := NewCPU(ioBus, memory)
+	tCpu.A, tCpu.B, tCpu.L = 0x11, 0x22, 0x77
+	tCpu.PC, tCpu.SP = 0x1234, 0x23F0
+	tCpu.setProgramStatus(0b11010111)
+	tCpu.InterruptsEnabled = true
+	tCpu.Halted = true
+	ioBus.Write(0x04, 0xAA)
+	ioBus.Write(0x02, 0x03)
+	ioBus.HandleInput(1, 2, true)
+	memory.Write(0x2400, 0x55)
+
+	data, err := tCpu.Dump().MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary failed: %v\n", err)
+	}
+	got := Dump{}
+	if err := got.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary failed: %v\n", err)
+	}
+	if !reflect.DeepEqual(&got, tCpu.Dump()) {
+		t.Fatalf("round trip mismatch:\n%s\n%s\n", &got, tCpu.Dump())
+	}
+
+	restored := NewCPU(NewIOBus(), NewMemory(0x3FFF))
+	if err := restored.Restore(&got); err != nil {
+		t.Fatalf("Restore failed: %v\n", err)
+	}
+	if !reflect.DeepEqual(restored.Dump(), tCpu.Dump()) {
+		t.Fatalf("restored state mismatch\n")
+	}
+
+	if err := got.UnmarshalBinary(data[:20]); err == nil {
+		t.Errorf("expected error on truncated dump\n")
+	}
+}
+
+func TestDiffDumps(t *testing.T) {
+	a := &Dump{SP: 0x23F0, Memory: make([]byte, 0x4000)}
+	b := &Dump{SP: 0x23EE, Memory: make([]byte, 0x4000)}
+	b.Memory[0x2000] = 1
+	b.Memory[0x2001] = 2
+	b.Memory[0x23EE] = 3
+	b.Memory[0x23FF] = 4
+	b.Memory[0x2400] = 5
+
+	want := []DiffRun{
+		{RegionRAM, 0x2000, []byte{0, 0}, []byte{1, 2}},
+		{RegionStack, 0x23EE, []byte{0}, []byte{3}},
+		{RegionStack, 0x23FF, []byte{0}, []byte{4}},
+		{RegionVRAM, 0x2400, []byte{0}, []byte{5}},
+	}
+	got := DiffDumps(a, b)
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected: %+v\ngot: %+v\n", want, got)
+	}
+}
+
+func TestWriteIntelHex(t *testing.T) {
+	mem := []byte{0x00, 0x01, 0x02, 0x03}
+	buf := bytes.Buffer{}
+	if err := WriteIntelHex(&buf, mem, 1, 4); err != nil {
+		t.Fatalf("WriteIntelHex failed: %v\n", err)
+	}
+	want := ":03000100010203F6\n:00000001FF\n"
+	if buf.String() != want {
+		t.Fatalf("expected: %q, got: %q\n", want, buf.String())
+	}
+}
+
+func TestWriteHexdump(t *testing.T) {
+	mem := []byte("Hello, 8080!\x00\x01")
+	buf := bytes.Buffer{}
+	if err := WriteHexdump(&buf, mem, 0, len(mem)); err != nil {
+		t.Fatalf("WriteHexdump failed: %v\n", err)
+	}
+	if !strings.HasPrefix(buf.String(), "0000: 48 65 6c 6c") || !strings.Contains(buf.String(), "|Hello, 8080!..") {
+		t.Fatalf("unexpected hexdump: %q\n", buf.String())
+	}
+}
@@ -0,0 +1,77 @@
+package intel8080
+
+import "fmt"
+
+// InvalidOpcodeError is returned by Step when the opcode at PC has no
+// implementation, or decodes to an impossible register
+type InvalidOpcodeError struct {
+	PC     uint16
+	Opcode uint8
+}
+
+func (e *InvalidOpcodeError) Error() string {
+	return fmt.Sprintf("invalid opcode 0x%02x (%s) at %04x", e.Opcode, instructionNames[e.Opcode], e.PC)
+}
+
+// ProtectedWriteError is returned when a write hits a block marked read-only with Memory.Protect
+type ProtectedWriteError struct {
+	PC         uint16
+	Opcode     uint8
+	Address    uint16
+	BlockStart uint16
+	BlockEnd   uint16
+}
+
+func (e *ProtectedWriteError) Error() string {
+	return fmt.Sprintf("write to read-only address %04x in protected block [%04x:%04x] (PC %04x, opcode 0x%02x)",
+		e.Address, e.BlockStart, e.BlockEnd, e.PC, e.Opcode)
+}
+
+// UnmappedAccessError is returned when a read or write falls outside of memory
+type UnmappedAccessError struct {
+	PC      uint16
+	Opcode  uint8
+	Address uint16
+	Write   bool
+}
+
+func (e *UnmappedAccessError) Error() string {
+	access := "read from"
+	if e.Write {
+		access = "write to"
+	}
+	return fmt.Sprintf("%s unmapped address %04x (PC %04x, opcode 0x%02x)", access, e.Address, e.PC, e.Opcode)
+}
+
+// HaltedError is returned by Step when the CPU executed HLT with interrupts
+// disabled, so nothing can ever resume it
+type HaltedError struct {
+	PC uint16
+}
+
+func (e *HaltedError) Error() string {
+	return fmt.Sprintf("halted at %04x with interrupts disabled", e.PC)
+}
+
+// BreakpointError is returned by Step, before executing, when PC reaches a breakpoint.
+// Calling Step again executes the instruction and continues.
+type BreakpointError struct {
+	PC uint16
+}
+
+func (e *BreakpointError) Error() string {
+	return fmt.Sprintf("breakpoint at %04x", e.PC)
+}
+
+// withLocation fills in the PC and opcode of the instruction that raised a memory fault
+func withLocation(err error, info *stepInfo) error {
+	switch e := err.(type) {
+	case *InvalidOpcodeError:
+		e.PC, e.Opcode = info.PC, info.opcode
+	case *ProtectedWriteError:
+		e.PC, e.Opcode = info.PC, info.opcode
+	case *UnmappedAccessError:
+		e.PC, e.Opcode = info.PC, info.opcode
+	}
+	return err
+}
@@ -0,0 +1,158 @@
+package intel8080
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStepErrors(t *testing.T) {
+	t.Run("invalid opcode", func(t *testing.T) {
+		memory := NewMemory(0xFF)
+		tCpu := NewCPU(NewIOBus(), memory)
+		tCpu.PC = 0x10
+		memory.Write(0x10, 0x08)
+
+		_, err := tCpu.Step()
+		var invalid *InvalidOpcodeError
+		if !errors.As(err, &invalid) || invalid.PC != 0x10 || invalid.Opcode != 0x08 {
+			t.Fatalf("expected InvalidOpcodeError at 0010, got: %v\n", err)
+		}
+	})
+
+	t.Run("protected write", func(t *testing.T) {
+		memory := NewMemory(0xFF)
+		tCpu := NewCPU(NewIOBus(), memory)
+		// LXI H,0x0000 / MOV M,A
+		for i, b := range []byte{0x21, 0x00, 0x00, 0x77} {
+			memory.Write(uint16(i), b)
+		}
+		memory.Protect(0x00, 0x0F)
+		tCpu.A = 0x42
+
+		stepN(t, tCpu, 1)
+		_, err := tCpu.Step()
+		var protected *ProtectedWriteError
+		if !errors.As(err, &protected) || protected.PC != 0x03 || protected.Opcode != 0x77 || protected.Address != 0x00 {
+			t.Fatalf("expected ProtectedWriteError at 0003, got: %v\n", err)
+		}
+		if tCpu.PC != 0x03 || memory.Read(0x00) != 0x21 {
+			t.Fatalf("faulting write should leave PC and memory untouched\n")
+		}
+	})
+
+	t.Run("unmapped access", func(t *testing.T) {
+		memory := NewMemory(0xFF)
+		tCpu := NewCPU(NewIOBus(), memory)
+		// LDA 0x1000
+		for i, b := range []byte{0x3A, 0x00, 0x10} {
+			memory.Write(uint16(i), b)
+		}
+
+		_, err := tCpu.Step()
+		var unmapped *UnmappedAccessError
+		if !errors.As(err, &unmapped) || unmapped.PC != 0x00 || unmapped.Address != 0x1000 || unmapped.Write {
+			t.Fatalf("expected UnmappedAccessError for 1000, got: %v\n", err)
+		}
+	})
+
+	t.Run("halted", func(t *testing.T) {
+		memory := NewMemory(0xFF)
+		tCpu := NewCPU(NewIOBus(), memory)
+		memory.Write(0x00, 0x76) // HLT
+
+		stepN(t, tCpu, 1)
+		_, err := tCpu.Step()
+		var halted *HaltedError
+		if !errors.As(err, &halted) || halted.PC != 0x00 {
+			t.Fatalf("expected HaltedError at 0000, got: %v\n", err)
+		}
+	})
+
+	t.Run("halt wakes on interrupt", func(t *testing.T) {
+		memory := NewMemory(0xFF)
+		tCpu := NewCPU(NewIOBus(), memory)
+		tCpu.SP = 0x80
+		memory.Write(0x00, 0xFB) // EI
+		memory.Write(0x01, 0x76) // HLT
+
+		stepN(t, tCpu, 3)
+		if !tCpu.Halted || tCpu.PC != 0x02 {
+			t.Fatalf("expected CPU halted with PC 0002, got halted=%v PC=%04x\n", tCpu.Halted, tCpu.PC)
+		}
+		tCpu.Interrupt(1)
+		if tCpu.Halted || tCpu.PC != 0x08 || memory.Read(0x7E) != 0x02 {
+			t.Fatalf("expected interrupt to resume at 0008 returning to 0002\n")
+		}
+	})
+
+	t.Run("breakpoint", func(t *testing.T) {
+		memory := NewMemory(0xFF)
+		tCpu := NewCPU(NewIOBus(), memory)
+		tCpu.SetBreakpoint(0x02)
+
+		_, err := tCpu.Run(100)
+		var bp *BreakpointError
+		if !errors.As(err, &bp) || bp.PC != 0x02 || tCpu.PC != 0x02 {
+			t.Fatalf("expected BreakpointError at 0002, got: %v\n", err)
+		}
+		stepN(t, tCpu, 1)
+		if tCpu.PC != 0x03 {
+			t.Fatalf("expected Step to resume past the breakpoint, PC=%04x\n", tCpu.PC)
+		}
+	})
+}
+
+func TestRst(t *testing.T) {
+	memory := NewMemory(0xFF)
+	tCpu := NewCPU(NewIOBus(), memory)
+	tCpu.PC = 0x40
+	tCpu.SP = 0x80
+	memory.Write(0x40, 0xDF) // RST 3
+
+	cycles, err := tCpu.Step()
+	if err != nil {
+		t.Fatalf("Step failed: %v\n", err)
+	}
+	if tCpu.PC != 0x18 || tCpu.SP != 0x7E || memory.Read(0x7E) != 0x41 || memory.Read(0x7F) != 0x00 || cycles != 11 {
+		t.Fatalf("unexpected state after RST 3: PC=%04x SP=%04x cycles=%d\n", tCpu.PC, tCpu.SP, cycles)
+	}
+}
+
+func TestGetOpcodeRegPtrMemoryIndicator(t *testing.T) {
+	tCpu := NewCPU(NewIOBus(), NewMemory(0xFF))
+	_ = tCpu.getOpcodeRegPtr(0b110)
+	var invalid *InvalidOpcodeError
+	if !errors.As(tCpu.fault, &invalid) {
+		t.Fatalf("expected an InvalidOpcodeError fault, got: %v\n", tCpu.fault)
+	}
+}
+
+func TestIndirectThroughBadPair(t *testing.T) {
+	memory := NewMemory(0xFF)
+	tCpu := NewCPU(NewIOBus(), memory)
+	// Only BC and DE can be used: route LHLD and SHLD to LDAX and STAX
+	tCpu.lutOpcodeFunc[0x2A] = tCpu.ldax
+	tCpu.lutOpcodeFunc[0x22] = tCpu.stax
+	for _, opcode := range []uint8{0x2A, 0x22} {
+		tCpu.PC = 0x10
+		memory.Write(0x10, opcode)
+		_, err := tCpu.Step()
+		var invalid *InvalidOpcodeError
+		if !errors.As(err, &invalid) || invalid.PC != 0x10 || invalid.Opcode != opcode {
+			t.Fatalf("expected InvalidOpcodeError for %02x at 0010, got: %v\n", opcode, err)
+		}
+	}
+}
+
+func TestRunTestRomStopsOnFault(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "halt.com")
+	if err := os.WriteFile(path, []byte{0x76}, 0644); err != nil { // HLT
+		t.Fatalf("WriteFile failed: %v\n", err)
+	}
+	var halted *HaltedError
+	if err := RunTestRom(path); !errors.As(err, &halted) {
+		t.Fatalf("expected a HaltedError, got %v\n", err)
+	}
+}
@@ -1,7 +1,5 @@
 package intel8080
 
-import "fmt"
-
 func (cpu *CPU) setFlagSZP(result uint8) {
 	cpu.Zero = result == 0
 	cpu.Sign = result>>7 > 0
@@ -25,12 +23,31 @@ func (cpu *CPU) getOpcodeRegPtr(regIndicator uint8) *uint8 {
 	case 0b101:
 		return &cpu.L
 	default:
-		panic(fmt.Sprintf("Bad register pair indicator 0b%03b\n", regIndicator))
+		// 0b110 means memory (M) and callers must handle it before getting here
+		if cpu.fault == nil {
+			cpu.fault = &InvalidOpcodeError{}
+		}
+		return &cpu.badRegister
+	}
+}
+
+// read is used by instructions to access memory, recording a fault for unmapped reads
+func (cpu *CPU) read(address uint16) uint8 {
+	if !cpu.memory.IsMapped(address) && cpu.fault == nil {
+		cpu.fault = &UnmappedAccessError{Address: address}
+	}
+	return cpu.memory.Read(address)
+}
+
+// write is used by instructions to access memory, recording the first fault raised
+func (cpu *CPU) write(address uint16, b uint8) {
+	if err := cpu.memory.Write(address, b); err != nil && cpu.fault == nil {
+		cpu.fault = err
 	}
 }
 
 func (cpu *CPU) getOpcodeArgs(PC uint16) (uint8, uint8) {
-	return cpu.memory.Read(PC + 1), cpu.memory.Read(PC + 2)
+	return cpu.read(PC + 1), cpu.read(PC + 2)
 }
 
 func (cpu *CPU) setProgramStatus(psw uint8) {
@@ -0,0 +1,137 @@
+package intel8080
+
+// TraceEntry is the machine state just before an instruction executed
+type TraceEntry struct {
+	Cycle  uint64
+	PC     uint16
+	SP     uint16
+	Opcode uint8
+	Args   [2]uint8
+	Regs   [7]uint8 // A, B, C, D, E, H, L
+	PSW    uint8
+}
+
+// IOEvent is a single IN or OUT executed by the CPU
+type IOEvent struct {
+	Cycle uint64
+	PC    uint16
+	Out   bool
+	Port  uint8
+	Value uint8
+}
+
+// InputEvent is a single HandleInput call
+type InputEvent struct {
+	Cycle   uint64
+	Port    uint8
+	Bit     uint8
+	Pressed bool
+}
+
+// DefaultHistorySize is the number of entries kept per history ring by default
+const DefaultHistorySize = 256
+
+// history keeps the most recent instructions, IO accesses and inputs in ring buffers
+type history struct {
+	trace      []TraceEntry
+	traceNext  int
+	io         []IOEvent
+	ioNext     int
+	inputs     []InputEvent
+	inputsNext int
+	size       int
+
+	// cycle is the CPU cycle count at the start of the current instruction,
+	// used to timestamp inputs arriving from the host
+	cycle uint64
+}
+
+// EnableHistory keeps the last n instructions, IO accesses and inputs for crash
+// reports. Passing 0 disables it.
+func (cpu *CPU) EnableHistory(n int) {
+	if n <= 0 {
+		cpu.history = nil
+		cpu.ioBus.history = nil
+		return
+	}
+	cpu.history = &history{size: n}
+	cpu.ioBus.history = cpu.history
+}
+
+func (h *history) addTrace(e TraceEntry) {
+	if len(h.trace) < h.size {
+		h.trace = append(h.trace, e)
+		return
+	}
+	h.trace[h.traceNext] = e
+	h.traceNext = (h.traceNext + 1) % h.size
+}
+
+func (h *history) addIO(e IOEvent) {
+	if len(h.io) < h.size {
+		h.io = append(h.io, e)
+		return
+	}
+	h.io[h.ioNext] = e
+	h.ioNext = (h.ioNext + 1) % h.size
+}
+
+func (h *history) addInput(e InputEvent) {
+	e.Cycle = h.cycle
+	if len(h.inputs) < h.size {
+		h.inputs = append(h.inputs, e)
+		return
+	}
+	h.inputs[h.inputsNext] = e
+	h.inputsNext = (h.inputsNext + 1) % h.size
+}
+
+// Trace returns the recorded instructions, oldest first
+func (cpu *CPU) Trace() []TraceEntry {
+	if cpu.history == nil {
+		return nil
+	}
+	h := cpu.history
+	return append(append([]TraceEntry{}, h.trace[h.traceNext:]...), h.trace[:h.traceNext]...)
+}
+
+// IOHistory returns the recorded IN/OUT instructions, oldest first
+func (cpu *CPU) IOHistory() []IOEvent {
+	if cpu.history == nil {
+		return nil
+	}
+	h := cpu.history
+	return append(append([]IOEvent{}, h.io[h.ioNext:]...), h.io[:h.ioNext]...)
+}
+
+// InputHistory returns the recorded inputs, oldest first
+func (cpu *CPU) InputHistory() []InputEvent {
+	if cpu.history == nil {
+		return nil
+	}
+	h := cpu.history
+	return append(append([]InputEvent{}, h.inputs[h.inputsNext:]...), h.inputs[:h.inputsNext]...)
+}
+
+func (cpu *CPU) recordTrace(PC uint16, opcode uint8) {
+	cpu.history.cycle = cpu.Cycles
+	cpu.history.addTrace(TraceEntry{
+		Cycle:  cpu.Cycles,
+		PC:     PC,
+		SP:     cpu.SP,
+		Opcode: opcode,
+		Args:   [2]uint8{cpu.memory.Read(PC + 1), cpu.memory.Read(PC + 2)},
+		Regs:   [7]uint8{cpu.A, cpu.B, cpu.C, cpu.D, cpu.E, cpu.H, cpu.L},
+		PSW:    cpu.getProgramStatus(),
+	})
+}
+
+func (cpu *CPU) recordIO(PC uint16, out bool, port uint8, value uint8) {
+	cpu.history.addIO(IOEvent{
+		Cycle: cpu.Cycles,
+		PC:    PC,
+		Out:   out,
+		Port:  port,
+		Value: value,
+	})
+}
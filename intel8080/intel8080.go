@@ -10,6 +10,15 @@ type CPU struct {
 	memory *Memory
 	ioBus  *IOBus
 
+	// Total cycles executed since the CPU was created (Reset keeps counting)
+	Cycles uint64
+
+	// Recent instructions / IO for crash reports (nil unless EnableHistory was called)
+	history *history
+
+	// Optional checks for suspicious program behaviour (nil unless AttachSanitizer was called)
+	sanitizer *Sanitizer
+
 	// Registers
 	A, B, C, D, E, H, L uint8
 	PC, SP              uint16
@@ -18,6 +27,17 @@ type CPU struct {
 	Sign, Zero, Parity, Carry, AuxCarry      bool
 	deferInterruptsEnable, InterruptsEnabled bool
 
+	// Set by HLT until the next interrupt
+	Halted bool
+
+	// First fault raised while executing the current instruction, returned by Step
+	fault error
+	// Target for writes decoded to an impossible register, so they fault instead of panicking
+	badRegister uint8
+
+	breakpoints      map[uint16]bool
+	resumeBreakpoint bool
+
 	// Opcode look-up table
 	lutOpcodeFunc map[uint8]func(info *stepInfo) uint
 }
@@ -145,6 +165,11 @@ func (cpu *CPU) GetVram() []byte {
 	return cpu.memory.GetSlice(start, start+size)
 }
 
+// Reset does what the 8080's RESET pin does: PC goes to 0, interrupts are
+// disabled and a halt ends. The other registers and memory are kept.
 func (cpu *CPU) Reset() {
-	// TODO
+	cpu.PC = 0
+	cpu.InterruptsEnabled = false
+	cpu.deferInterruptsEnable = false
+	cpu.Halted = false
 }
@@ -0,0 +1,132 @@
+package intel8080
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Manifest describes a game: which ROM files to load, where, and which
+// patches to apply on top of them. Paths are relative to the manifest file.
+//
+//	{
+//	  "name": "invaders",
+//	  "roms": ["invaders.h", "invaders.g", "invaders.f", "invaders.e"],
+//	  "offset": 0,
+//	  "patches": [{"file": "fix.ips", "base_crc32": "1a2b3c4d", "result_crc32": "5e6f7a8b"}],
+//	  "overlay": "midway",
+//	  "backdrop": "moon.png",
+//	  "orientation": "270",
+//	  "cabinet": "upright",
+//	  "samples": "samples/invaders.zip",
+//	  "dip_switches": {"lives": "5", "bonus": "1000"}
+//	}
+type Manifest struct {
+	Name    string          `json:"name"`
+	Roms    []string        `json:"roms"`
+	Offset  uint16          `json:"offset"`
+	Patches []ManifestPatch `json:"patches"`
+
+	// Overlay is a built-in overlay name or a PNG file, Backdrop an optional PNG file
+	Overlay  string `json:"overlay,omitempty"`
+	Backdrop string `json:"backdrop,omitempty"`
+
+	// Orientation is the screen rotation ("0", "90", "180" or "270", with an
+	// optional "m" to mirror); Cabinet is "upright" or "cocktail"
+	Orientation string `json:"orientation,omitempty"`
+	Cabinet     string `json:"cabinet,omitempty"`
+
+	// Samples is a MAME sample set, as a directory or zip file
+	Samples string `json:"samples,omitempty"`
+
+	// DIPSwitches are the game's DIP switch settings by name
+	DIPSwitches map[string]string `json:"dip_switches,omitempty"`
+
+	dir string
+}
+
+type ManifestPatch struct {
+	File        string `json:"file"`
+	BaseCRC32   string `json:"base_crc32"`
+	ResultCRC32 string `json:"result_crc32"`
+}
+
+// DefaultManifest is used when no manifest is given on the command line
+func DefaultManifest() *Manifest {
+	return &Manifest{
+		Name: "invaders",
+		Roms: []string{"invaders.h", "invaders.g", "invaders.f", "invaders.e"},
+		dir:  "roms",
+	}
+}
+
+func LoadManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("loadManifest: failed reading file: %v", err)
+	}
+	m := Manifest{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("loadManifest: %s: %v", path, err)
+	}
+	if len(m.Roms) == 0 {
+		return nil, fmt.Errorf("loadManifest: %s: no roms listed", path)
+	}
+	m.dir = filepath.Dir(path)
+	return &m, nil
+}
+
+// RomPaths returns the ROM file paths resolved against the manifest directory
+func (m *Manifest) RomPaths() []string {
+	paths := make([]string, len(m.Roms))
+	for i, rom := range m.Roms {
+		paths[i] = m.resolve(rom)
+	}
+	return paths
+}
+
+// RomPatches returns the manifest patches with paths resolved and checksums parsed
+func (m *Manifest) RomPatches() ([]RomPatch, error) {
+	patches := make([]RomPatch, 0, len(m.Patches))
+	for _, p := range m.Patches {
+		baseCRC, err := parseCRC32(p.BaseCRC32)
+		if err != nil {
+			return nil, fmt.Errorf("manifest patch %s: %v", p.File, err)
+		}
+		resultCRC, err := parseCRC32(p.ResultCRC32)
+		if err != nil {
+			return nil, fmt.Errorf("manifest patch %s: %v", p.File, err)
+		}
+		patches = append(patches, RomPatch{
+			Path:        m.resolve(p.File),
+			BaseCRC32:   baseCRC,
+			ResultCRC32: resultCRC,
+		})
+	}
+	return patches, nil
+}
+
+// Asset resolves a file named by the manifest against its directory. Names
+// without an extension (built-in presets) and "" are returned unchanged.
+func (m *Manifest) Asset(name string) string {
+	if name == "" || filepath.Ext(name) == "" {
+		return name
+	}
+	return m.resolve(name)
+}
+
+// SamplesPath returns the path of the sample set, if any
+func (m *Manifest) SamplesPath() string {
+	if m.Samples == "" {
+		return ""
+	}
+	return m.resolve(m.Samples)
+}
+
+func (m *Manifest) resolve(path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(m.dir, path)
+}
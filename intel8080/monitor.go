@@ -0,0 +1,178 @@
+package intel8080
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const monitorHelp = `commands:
+  r              show registers
+  s [n]          step n instructions (default 1)
+  d [addr] [n]   disassemble n instructions from addr (default PC, 10)
+  m addr [len]   hexdump len bytes of memory from addr (default 64)
+  t [n]          show the last n traced instructions (default 20)
+  io [n]         show the last n IN/OUT accesses (default 20)
+  in [n]         show the last n inputs (default 20)
+  roms           show loaded ROMs and checksums
+  q              quit
+`
+
+// Disassemble returns the instruction at address as text, along with its length in bytes
+func (cpu *CPU) Disassemble(address uint16) (string, uint16) {
+	opcode := cpu.memory.Read(address)
+	bytes := uint16(instructionBytes[opcode])
+	name := instructionNames[opcode]
+	switch bytes {
+	case 2:
+		db := cpu.memory.Read(address + 1)
+		if strings.HasSuffix(name, " p") {
+			name = fmt.Sprintf("%s$%02x", strings.TrimSuffix(name, "p"), db)
+		} else {
+			name = strings.Replace(name, "#", fmt.Sprintf("#$%02x", db), 1)
+		}
+	case 3:
+		word := uint16(cpu.memory.Read(address+2))<<8 | uint16(cpu.memory.Read(address+1))
+		if strings.Contains(name, "#") {
+			name = strings.Replace(name, "#", fmt.Sprintf("#$%04x", word), 1)
+		} else {
+			name = strings.Replace(name, "$", fmt.Sprintf("$%04x", word), 1)
+		}
+	}
+	return name, bytes
+}
+
+// RunMonitor is a minimal line based debugger reading commands from in until EOF or "q"
+func RunMonitor(cpu *CPU, in io.Reader, out io.Writer) error {
+	scanner := bufio.NewScanner(in)
+	fmt.Fprintf(out, "%s\n> ", cpu.GetInstructionInfo())
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			fmt.Fprint(out, "> ")
+			continue
+		}
+		args := make([]int, 0, len(fields)-1)
+		badArg := false
+		for _, f := range fields[1:] {
+			v, err := strconv.ParseInt(strings.TrimPrefix(f, "$"), 16, 32)
+			if err != nil {
+				fmt.Fprintf(out, "bad argument %q (numbers are hex)\n", f)
+				badArg = true
+				break
+			}
+			args = append(args, int(v))
+		}
+		if !badArg {
+			if quit := cpu.monitorCommand(fields[0], args, out); quit {
+				return nil
+			}
+		}
+		fmt.Fprint(out, "> ")
+	}
+	return scanner.Err()
+}
+
+func (cpu *CPU) monitorCommand(cmd string, args []int, out io.Writer) bool {
+	arg := func(i int, def int) int {
+		if i < len(args) {
+			return args[i]
+		}
+		return def
+	}
+
+	switch cmd {
+	case "q", "quit":
+		return true
+	case "r", "regs":
+		fmt.Fprintln(out, cpu.GetInstructionInfo())
+	case "s", "step":
+		for i := 0; i < arg(0, 1); i++ {
+			if _, err := cpu.Step(); err != nil {
+				fmt.Fprintf(out, "step: %v\n", err)
+				break
+			}
+		}
+		fmt.Fprintln(out, cpu.GetInstructionInfo())
+	case "d", "dis":
+		address := uint16(arg(0, int(cpu.PC)))
+		for i := 0; i < arg(1, 10); i++ {
+			text, size := cpu.Disassemble(address)
+			fmt.Fprintf(out, "%04x  %s\n", address, text)
+			address += size
+		}
+	case "m", "mem":
+		if len(args) == 0 {
+			fmt.Fprintln(out, "usage: m addr [len]")
+			break
+		}
+		start := arg(0, 0)
+		end := start + arg(1, 64)
+		if end > len(cpu.memory.bytes) {
+			end = len(cpu.memory.bytes)
+		}
+		if err := WriteHexdump(out, cpu.memory.bytes, start, end); err != nil {
+			fmt.Fprintf(out, "%v\n", err)
+		}
+	case "t", "trace":
+		trace := cpu.Trace()
+		for _, e := range lastN(len(trace), arg(0, 20), out) {
+			e := trace[e]
+			fmt.Fprintf(out, "%10d  %04x  %-14s SP: %04x, Flags: 0x%08b, Regs: [%02x %02x %02x %02x %02x %02x %02x]\n",
+				e.Cycle, e.PC, traceText(e), e.SP, e.PSW,
+				e.Regs[0], e.Regs[1], e.Regs[2], e.Regs[3], e.Regs[4], e.Regs[5], e.Regs[6])
+		}
+	case "io":
+		events := cpu.IOHistory()
+		for _, i := range lastN(len(events), arg(0, 20), out) {
+			e := events[i]
+			dir := "IN "
+			if e.Out {
+				dir = "OUT"
+			}
+			fmt.Fprintf(out, "%10d  %04x  %s port %d = 0b%08b\n", e.Cycle, e.PC, dir, e.Port, e.Value)
+		}
+	case "in", "inputs":
+		inputs := cpu.InputHistory()
+		for _, i := range lastN(len(inputs), arg(0, 20), out) {
+			e := inputs[i]
+			fmt.Fprintf(out, "%10d  port %d bit %d pressed=%v\n", e.Cycle, e.Port, e.Bit, e.Pressed)
+		}
+	case "roms":
+		for _, rom := range cpu.memory.LoadedRoms() {
+			fmt.Fprintf(out, "%-24s %6d bytes  crc32 %s  sha1 %s\n", rom.Path, rom.Size, rom.CRC32, rom.SHA1)
+		}
+	case "h", "help", "?":
+		fmt.Fprint(out, monitorHelp)
+	default:
+		fmt.Fprintf(out, "unknown command %q, try h\n", cmd)
+	}
+	return false
+}
+
+// lastN returns the indexes of the last n of count entries, noting when there are none
+func lastN(count int, n int, out io.Writer) []int {
+	if count == 0 {
+		fmt.Fprintln(out, "(empty - was history enabled?)")
+		return nil
+	}
+	start := count - n
+	if start < 0 {
+		start = 0
+	}
+	indexes := make([]int, 0, count-start)
+	for i := start; i < count; i++ {
+		indexes = append(indexes, i)
+	}
+	return indexes
+}
+
+// traceText disassembles a trace entry from the bytes captured with it
+func traceText(e TraceEntry) string {
+	m := Memory{bytes: []byte{e.Opcode, e.Args[0], e.Args[1]}}
+	c := CPU{memory: &m}
+	text, _ := c.Disassemble(0)
+	return text
+}
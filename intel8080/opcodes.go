@@ -1,9 +1,5 @@
 package intel8080
 
-import (
-	"log"
-)
-
 // NOP       00000000          -       No operation
 func (cpu *CPU) nop(_ *stepInfo) uint {
 	return 4
@@ -11,7 +7,9 @@ func (cpu *CPU) nop(_ *stepInfo) uint {
 
 // HLT       01110110          -       Halt processor
 func (cpu *CPU) hlt(_ *stepInfo) uint {
-	// Note: Doesn't increase cpu.PC, which starts an infinite loop
+	// PC is left on the next instruction, which is where an interrupt returns to
+	cpu.PC++
+	cpu.Halted = true
 	return 7
 }
 
@@ -22,13 +20,13 @@ func (cpu *CPU) mov(info *stepInfo) uint {
 		// move register into memory
 		regPtr := cpu.getOpcodeRegPtr(sss)
 		address := (uint16(cpu.H) << 8) | uint16(cpu.L)
-		cpu.memory.Write(address, *regPtr)
+		cpu.write(address, *regPtr)
 		return 7
 	} else if sss == 0b110 {
 		// move memory into register
 		regPtr := cpu.getOpcodeRegPtr(ddd)
 		address := (uint16(cpu.H) << 8) | uint16(cpu.L)
-		*regPtr = cpu.memory.Read(address)
+		*regPtr = cpu.read(address)
 		return 7
 	} else {
 		// move register to register
@@ -45,9 +43,9 @@ func (cpu *CPU) inr(info *stepInfo) uint {
 	if ddd == 0b110 {
 		// increment memory
 		address := (uint16(cpu.H) << 8) | uint16(cpu.L)
-		value := cpu.memory.Read(address)
+		value := cpu.read(address)
 		value += 1
-		cpu.memory.Write(address, value)
+		cpu.write(address, value)
 
 		cpu.AuxCarry = (value & 0b1111) == 0
 		cpu.setFlagSZP(value)
@@ -69,9 +67,9 @@ func (cpu *CPU) dcr(info *stepInfo) uint {
 	if ddd == 0b110 {
 		// decrement value in memory
 		address := (uint16(cpu.H) << 8) | uint16(cpu.L)
-		value := cpu.memory.Read(address)
+		value := cpu.read(address)
 		value -= 1
-		cpu.memory.Write(address, value)
+		cpu.write(address, value)
 
 		cpu.AuxCarry = value&0b00001111 == 0
 		cpu.setFlagSZP(value)
@@ -112,7 +110,7 @@ func (cpu *CPU) lxi(info *stepInfo) uint {
 func (cpu *CPU) lda(info *stepInfo) uint {
 	lb, hb := cpu.getOpcodeArgs(info.PC)
 	address := (uint16(hb) << 8) | uint16(lb)
-	cpu.A = cpu.memory.Read(address)
+	cpu.A = cpu.read(address)
 	return 13
 }
 
@@ -120,7 +118,7 @@ func (cpu *CPU) lda(info *stepInfo) uint {
 func (cpu *CPU) sta(info *stepInfo) uint {
 	lb, hb := cpu.getOpcodeArgs(info.PC)
 	address := (uint16(hb) << 8) | uint16(lb)
-	cpu.memory.Write(address, cpu.A)
+	cpu.write(address, cpu.A)
 	return 13
 }
 
@@ -128,8 +126,8 @@ func (cpu *CPU) sta(info *stepInfo) uint {
 func (cpu *CPU) lhld(info *stepInfo) uint {
 	lb, hb := cpu.getOpcodeArgs(info.PC)
 	address := (uint16(hb) << 8) | uint16(lb)
-	cpu.L = cpu.memory.Read(address)
-	cpu.H = cpu.memory.Read(address + 1)
+	cpu.L = cpu.read(address)
+	cpu.H = cpu.read(address + 1)
 	return 16
 }
 
@@ -137,8 +135,8 @@ func (cpu *CPU) lhld(info *stepInfo) uint {
 func (cpu *CPU) shld(info *stepInfo) uint {
 	lb, hb := cpu.getOpcodeArgs(info.PC)
 	address := (uint16(hb) << 8) | uint16(lb)
-	cpu.memory.Write(address, cpu.L)
-	cpu.memory.Write(address+1, cpu.H)
+	cpu.write(address, cpu.L)
+	cpu.write(address+1, cpu.H)
 	return 16
 }
 
@@ -152,9 +150,11 @@ func (cpu *CPU) ldax(info *stepInfo) uint {
 	case 0b01:
 		address = (uint16(cpu.D) << 8) | uint16(cpu.E)
 	default:
-		log.Fatalf("Invalid opcode: %v", info)
+		// Only BC and DE can be used
+		cpu.fault = &InvalidOpcodeError{}
+		return 7
 	}
-	cpu.A = cpu.memory.Read(address)
+	cpu.A = cpu.read(address)
 	return 7
 }
 
@@ -168,9 +168,11 @@ func (cpu *CPU) stax(info *stepInfo) uint {
 	case 0b01:
 		address = (uint16(cpu.D) << 8) | uint16(cpu.E)
 	default:
-		log.Fatalf("Invalid opcode: %v", info)
+		// Only BC and DE can be used
+		cpu.fault = &InvalidOpcodeError{}
+		return 7
 	}
-	cpu.memory.Write(address, cpu.A)
+	cpu.write(address, cpu.A)
 	return 7
 }
 
@@ -194,7 +196,7 @@ func (cpu *CPU) add(info *stepInfo) uint {
 	var value uint8
 	if sss == 0b110 {
 		address := (uint16(cpu.H) << 8) | uint16(cpu.L)
-		value = cpu.memory.Read(address)
+		value = cpu.read(address)
 		cycles = 7
 	} else {
 		regPtr := cpu.getOpcodeRegPtr(sss)
@@ -219,7 +221,7 @@ func (cpu *CPU) adc(info *stepInfo) uint {
 	var value uint8
 	if sss == 0b110 {
 		address := (uint16(cpu.H) << 8) | uint16(cpu.L)
-		value = cpu.memory.Read(address)
+		value = cpu.read(address)
 		cycles = 7
 	} else {
 		regPtr := cpu.getOpcodeRegPtr(sss)
@@ -282,10 +284,8 @@ func (cpu *CPU) dad(info *stepInfo) uint {
 		addend = (uint32(cpu.D) << 8) | uint32(cpu.E)
 	case 0b10:
 		addend = (uint32(cpu.H) << 8) | uint32(cpu.L)
-	case 0b11:
+	default: // 0b11
 		addend = uint32(cpu.SP)
-	default:
-		panic("Bad register pair in DAD instruction")
 	}
 	resultHL = currentHL + addend
 	cpu.Carry = resultHL&0x10000 > 0
@@ -302,7 +302,7 @@ func (cpu *CPU) sub(info *stepInfo) uint {
 	var value uint8
 	if sss == 0b110 {
 		address := (uint16(cpu.H) << 8) | uint16(cpu.L)
-		value = cpu.memory.Read(address)
+		value = cpu.read(address)
 		cycles = 7
 	} else {
 		regPtr := cpu.getOpcodeRegPtr(sss)
@@ -357,7 +357,7 @@ func (cpu *CPU) sbb(info *stepInfo) uint {
 	var value uint8
 	if sss == 0b110 {
 		address := (uint16(cpu.H) << 8) | uint16(cpu.L)
-		value = cpu.memory.Read(address)
+		value = cpu.read(address)
 		cycles = 7
 	} else {
 		regPtr := cpu.getOpcodeRegPtr(sss)
@@ -385,7 +385,7 @@ func (cpu *CPU) cmp(info *stepInfo) uint {
 	var value uint8
 	if sss == 0b110 {
 		address := (uint16(cpu.H) << 8) | uint16(cpu.L)
-		value = cpu.memory.Read(address)
+		value = cpu.read(address)
 		cycles = 7
 	} else {
 		regPtr := cpu.getOpcodeRegPtr(sss)
@@ -412,15 +412,13 @@ func (cpu *CPU) push(info *stepInfo) uint {
 		hb, lb = cpu.D, cpu.E
 	case 0b10:
 		hb, lb = cpu.H, cpu.L
-	case 0b11:
+	default: // 0b11
 		hb, lb = cpu.A, cpu.getProgramStatus()
-	default:
-		panic("PUSH bad register pair. (this shouldn't ever happen)")
 	}
 	cpu.SP--
-	cpu.memory.Write(cpu.SP, hb)
+	cpu.write(cpu.SP, hb)
 	cpu.SP--
-	cpu.memory.Write(cpu.SP, lb)
+	cpu.write(cpu.SP, lb)
 
 	return 11
 }
@@ -429,9 +427,9 @@ func (cpu *CPU) push(info *stepInfo) uint {
 func (cpu *CPU) pop(info *stepInfo) uint {
 	rp := getOpcodeRP(info.opcode)
 
-	lb := cpu.memory.Read(cpu.SP)
+	lb := cpu.read(cpu.SP)
 	cpu.SP++
-	hb := cpu.memory.Read(cpu.SP)
+	hb := cpu.read(cpu.SP)
 	cpu.SP++
 
 	switch rp {
@@ -441,11 +439,9 @@ func (cpu *CPU) pop(info *stepInfo) uint {
 		cpu.D, cpu.E = hb, lb
 	case 0b10:
 		cpu.H, cpu.L = hb, lb
-	case 0b11:
+	default: // 0b11
 		cpu.A = hb
 		cpu.setProgramStatus(lb)
-	default:
-		panic("pop - invalid register pair") // this should be impossible
 	}
 	return 11
 }
@@ -459,9 +455,9 @@ func (cpu *CPU) call(info *stepInfo) uint {
 	nextPC := info.PC + 3
 	nextPClo, nextPChi := uint8(nextPC&0xFF), uint8((nextPC>>8)&0xFF)
 	cpu.SP--
-	cpu.memory.Write(cpu.SP, nextPChi)
+	cpu.write(cpu.SP, nextPChi)
 	cpu.SP--
-	cpu.memory.Write(cpu.SP, nextPClo)
+	cpu.write(cpu.SP, nextPClo)
 
 	cpu.PC = (uint16(hb) << 8) | uint16(lb)
 	return 17
@@ -662,7 +658,7 @@ func (cpu *CPU) mvi(info *stepInfo) uint {
 
 	if ddd == 0b110 {
 		address := (uint16(cpu.H) << 8) | uint16(cpu.L)
-		cpu.memory.Write(address, db)
+		cpu.write(address, db)
 		return 10
 	} else {
 		regPtr := cpu.getOpcodeRegPtr(ddd)
@@ -721,7 +717,7 @@ func (cpu *CPU) ora(info *stepInfo) uint {
 	var value uint8
 	if sss == 0b110 {
 		address := (uint16(cpu.H) << 8) | uint16(cpu.L)
-		value = cpu.memory.Read(address)
+		value = cpu.read(address)
 		cycles = 7
 	} else {
 		regPtr := cpu.getOpcodeRegPtr(sss)
@@ -754,7 +750,7 @@ func (cpu *CPU) ori(info *stepInfo) uint {
 
 // RET       11001001          -       Unconditional return from subroutine
 func (cpu *CPU) ret(_ *stepInfo) uint {
-	hb, lb := cpu.memory.Read(cpu.SP+1), cpu.memory.Read(cpu.SP)
+	hb, lb := cpu.read(cpu.SP+1), cpu.read(cpu.SP)
 	cpu.SP += 2
 	cpu.PC = (uint16(hb) << 8) | uint16(lb)
 	return 10
@@ -910,7 +906,7 @@ func (cpu *CPU) ana(info *stepInfo) uint {
 	var value uint8
 	if sss == 0b110 {
 		address := (uint16(cpu.H) << 8) | uint16(cpu.L)
-		value = cpu.memory.Read(address)
+		value = cpu.read(address)
 		cycles = 7
 	} else {
 		regPtr := cpu.getOpcodeRegPtr(sss)
@@ -934,7 +930,7 @@ func (cpu *CPU) xra(info *stepInfo) uint {
 	var value uint8
 	if sss == 0b110 {
 		address := (uint16(cpu.H) << 8) | uint16(cpu.L)
-		value = cpu.memory.Read(address)
+		value = cpu.read(address)
 		cycles = 7
 	} else {
 		regPtr := cpu.getOpcodeRegPtr(sss)
@@ -976,10 +972,10 @@ func (cpu *CPU) daa(_ *stepInfo) uint {
 
 // XTHL      11100011          -       Swap H:L with top word on stack
 func (cpu *CPU) xthl(_ *stepInfo) uint {
-	stackLo := cpu.memory.Read(cpu.SP)
-	stackHi := cpu.memory.Read(cpu.SP + 1)
-	cpu.memory.Write(cpu.SP, cpu.L)
-	cpu.memory.Write(cpu.SP+1, cpu.H)
+	stackLo := cpu.read(cpu.SP)
+	stackHi := cpu.read(cpu.SP + 1)
+	cpu.write(cpu.SP, cpu.L)
+	cpu.write(cpu.SP+1, cpu.H)
 	cpu.L = stackLo
 	cpu.H = stackHi
 	return 18
@@ -1021,14 +1017,21 @@ func (cpu *CPU) pchl(_ *stepInfo) uint {
 	return 5
 }
 
+// Interrupt executes RST interruptType if interrupts are enabled, waking the CPU if it is halted
 func (cpu *CPU) Interrupt(interruptType uint) {
 	if cpu.InterruptsEnabled {
+		cpu.Halted = false
+
 		// Push PC on to the stack
 		pcHi, pcLo := uint8(cpu.PC>>8), uint8(cpu.PC&0xFF)
 		cpu.SP--
-		cpu.memory.Write(cpu.SP, pcHi)
+		cpu.write(cpu.SP, pcHi)
 		cpu.SP--
-		cpu.memory.Write(cpu.SP, pcLo)
+		cpu.write(cpu.SP, pcLo)
+
+		if cpu.sanitizer != nil {
+			cpu.sanitizer.interrupt(cpu, cpu.PC)
+		}
 
 		// Move PC to type * 8
 		cpu.PC = 8 * uint16(interruptType)
@@ -1036,9 +1039,16 @@ func (cpu *CPU) Interrupt(interruptType uint) {
 	}
 }
 
-func (cpu *CPU) rst(_ *stepInfo) uint {
-	panic("RST not implemented")
-	return 0
+// RST n     11NNN111          -       Restart (Call n*8)
+func (cpu *CPU) rst(info *stepInfo) uint {
+	nextPC := info.PC + 1
+	cpu.SP--
+	cpu.write(cpu.SP, uint8(nextPC>>8))
+	cpu.SP--
+	cpu.write(cpu.SP, uint8(nextPC&0xFF))
+
+	cpu.PC = uint16(info.opcode & 0b00111000)
+	return 11
 }
 
 // IN p      11011011 pa       -       Read input port into A
@@ -1050,6 +1060,9 @@ func (cpu *CPU) in(info *stepInfo) uint {
 	db, _ := cpu.getOpcodeArgs(info.PC)
 	b := cpu.ioBus.Read(db)
 	cpu.A = b
+	if cpu.history != nil {
+		cpu.recordIO(info.PC, false, db, b)
+	}
 	return 10
 }
 
@@ -1061,5 +1074,8 @@ func (cpu *CPU) out(info *stepInfo) uint {
 
 	db, _ := cpu.getOpcodeArgs(info.PC)
 	cpu.ioBus.Write(db, cpu.A)
+	if cpu.history != nil {
+		cpu.recordIO(info.PC, true, db, cpu.A)
+	}
 	return 10
 }
@@ -0,0 +1,302 @@
+package intel8080
+
+import (
+	"bytes"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// RomPatch describes an IPS or BPS patch applied on top of the loaded ROM image.
+// A zero checksum means "don't check".
+type RomPatch struct {
+	Path        string
+	BaseCRC32   uint32
+	ResultCRC32 uint32
+}
+
+// maxPatchedSize bounds the image a BPS patch may ask for, far above any
+// 8080 ROM set, so a corrupt size can't force a huge allocation
+const maxPatchedSize = 1 << 24
+
+var (
+	ipsMagic = []byte("PATCH")
+	ipsEOF   = []byte("EOF")
+	bpsMagic = []byte("BPS1")
+)
+
+// ApplyPatchFile reads a patch from disk, detects its format and applies it to rom
+func ApplyPatchFile(rom []byte, patch RomPatch) ([]byte, error) {
+	data, err := os.ReadFile(patch.Path)
+	if err != nil {
+		return nil, fmt.Errorf("applyPatch: failed reading file: %v", err)
+	}
+
+	if patch.BaseCRC32 != 0 {
+		if got := crc32.ChecksumIEEE(rom); got != patch.BaseCRC32 {
+			return nil, fmt.Errorf("applyPatch: %s: base checksum mismatch (want %08x, got %08x)", patch.Path, patch.BaseCRC32, got)
+		}
+	}
+
+	var result []byte
+	switch {
+	case bytes.HasPrefix(data, ipsMagic):
+		result, err = ApplyIPS(rom, data)
+	case bytes.HasPrefix(data, bpsMagic):
+		result, err = ApplyBPS(rom, data)
+	default:
+		return nil, fmt.Errorf("applyPatch: %s: unknown patch format", patch.Path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("applyPatch: %s: %v", patch.Path, err)
+	}
+
+	if patch.ResultCRC32 != 0 {
+		if got := crc32.ChecksumIEEE(result); got != patch.ResultCRC32 {
+			return nil, fmt.Errorf("applyPatch: %s: result checksum mismatch (want %08x, got %08x)", patch.Path, patch.ResultCRC32, got)
+		}
+	}
+	return result, nil
+}
+
+// ApplyIPS applies an IPS patch to base and returns the patched copy.
+// Records may grow the image, and the optional truncation extension is honoured.
+func ApplyIPS(base []byte, patch []byte) ([]byte, error) {
+	if !bytes.HasPrefix(patch, ipsMagic) {
+		return nil, fmt.Errorf("ips: missing PATCH header")
+	}
+	out := make([]byte, len(base))
+	copy(out, base)
+
+	pos := len(ipsMagic)
+	for {
+		if pos+3 > len(patch) {
+			return nil, fmt.Errorf("ips: unexpected end of patch at 0x%x", pos)
+		}
+		if bytes.Equal(patch[pos:pos+3], ipsEOF) {
+			pos += 3
+			break
+		}
+		offset := int(patch[pos])<<16 | int(patch[pos+1])<<8 | int(patch[pos+2])
+		pos += 3
+		if pos+2 > len(patch) {
+			return nil, fmt.Errorf("ips: truncated record at 0x%x", pos)
+		}
+		size := int(patch[pos])<<8 | int(patch[pos+1])
+		pos += 2
+
+		var chunk []byte
+		if size == 0 {
+			// RLE record: 2 byte run length followed by the value to repeat
+			if pos+3 > len(patch) {
+				return nil, fmt.Errorf("ips: truncated RLE record at 0x%x", pos)
+			}
+			size = int(patch[pos])<<8 | int(patch[pos+1])
+			chunk = bytes.Repeat([]byte{patch[pos+2]}, size)
+			pos += 3
+		} else {
+			if pos+size > len(patch) {
+				return nil, fmt.Errorf("ips: truncated record data at 0x%x", pos)
+			}
+			chunk = patch[pos : pos+size]
+			pos += size
+		}
+
+		if offset+len(chunk) > len(out) {
+			grown := make([]byte, offset+len(chunk))
+			copy(grown, out)
+			out = grown
+		}
+		copy(out[offset:], chunk)
+	}
+
+	// Truncation extension: 3 byte length after the EOF marker
+	if pos+3 == len(patch) {
+		size := int(patch[pos])<<16 | int(patch[pos+1])<<8 | int(patch[pos+2])
+		if size < len(out) {
+			out = out[:size]
+		}
+	}
+	return out, nil
+}
+
+// ApplyBPS applies a BPS patch to base and returns the patched copy.
+// The source, target and patch CRC32s stored in the footer are all verified.
+func ApplyBPS(base []byte, patch []byte) ([]byte, error) {
+	if !bytes.HasPrefix(patch, bpsMagic) {
+		return nil, fmt.Errorf("bps: missing BPS1 header")
+	}
+	if len(patch) < len(bpsMagic)+12 {
+		return nil, fmt.Errorf("bps: patch too short")
+	}
+	footer := len(patch) - 12
+	sourceCRC := le32(patch[footer:])
+	targetCRC := le32(patch[footer+4:])
+	patchCRC := le32(patch[footer+8:])
+
+	if got := crc32.ChecksumIEEE(patch[:footer+8]); got != patchCRC {
+		return nil, fmt.Errorf("bps: patch checksum mismatch (want %08x, got %08x)", patchCRC, got)
+	}
+	if got := crc32.ChecksumIEEE(base); got != sourceCRC {
+		return nil, fmt.Errorf("bps: source checksum mismatch (want %08x, got %08x)", sourceCRC, got)
+	}
+
+	pos := len(bpsMagic)
+	decode := func() (uint64, error) {
+		var data uint64
+		shift := uint64(1)
+		for {
+			if pos >= footer {
+				return 0, fmt.Errorf("bps: truncated number at 0x%x", pos)
+			}
+			x := patch[pos]
+			pos++
+			data += uint64(x&0x7f) * shift
+			if x&0x80 != 0 {
+				return data, nil
+			}
+			shift <<= 7
+			data += shift
+		}
+	}
+
+	sourceSize, err := decode()
+	if err != nil {
+		return nil, err
+	}
+	targetSize, err := decode()
+	if err != nil {
+		return nil, err
+	}
+	metadataSize, err := decode()
+	if err != nil {
+		return nil, err
+	}
+	if sourceSize != uint64(len(base)) {
+		return nil, fmt.Errorf("bps: source size mismatch (want %d, got %d)", sourceSize, len(base))
+	}
+	if targetSize > maxPatchedSize {
+		return nil, fmt.Errorf("bps: target size %d too large (max %d)", targetSize, maxPatchedSize)
+	}
+	if metadataSize > uint64(footer-pos) {
+		return nil, fmt.Errorf("bps: metadata size %d past end of patch", metadataSize)
+	}
+	pos += int(metadataSize)
+
+	out := make([]byte, targetSize)
+	outPos := 0
+	sourceRel, targetRel := 0, 0
+	for pos < footer {
+		data, err := decode()
+		if err != nil {
+			return nil, err
+		}
+		command := data & 0b11
+		if data>>2 >= uint64(len(out)-outPos) {
+			return nil, fmt.Errorf("bps: write past end of target at 0x%x", outPos)
+		}
+		length := int(data>>2) + 1
+
+		switch command {
+		case 0: // SourceRead
+			if outPos+length > len(base) {
+				return nil, fmt.Errorf("bps: source read past end of source at 0x%x", outPos)
+			}
+			copy(out[outPos:], base[outPos:outPos+length])
+			outPos += length
+		case 1: // TargetRead
+			if pos+length > footer {
+				return nil, fmt.Errorf("bps: target read past end of patch at 0x%x", pos)
+			}
+			copy(out[outPos:], patch[pos:pos+length])
+			pos += length
+			outPos += length
+		case 2: // SourceCopy
+			offset, err := decode()
+			if err != nil {
+				return nil, err
+			}
+			sourceRel += bpsRelative(offset)
+			if sourceRel < 0 || sourceRel+length > len(base) {
+				return nil, fmt.Errorf("bps: source copy out of range at 0x%x", outPos)
+			}
+			copy(out[outPos:], base[sourceRel:sourceRel+length])
+			sourceRel += length
+			outPos += length
+		case 3: // TargetCopy (may overlap, so copy byte by byte)
+			offset, err := decode()
+			if err != nil {
+				return nil, err
+			}
+			targetRel += bpsRelative(offset)
+			if targetRel < 0 || targetRel >= outPos {
+				return nil, fmt.Errorf("bps: target copy out of range at 0x%x", outPos)
+			}
+			for i := 0; i < length; i++ {
+				out[outPos] = out[targetRel]
+				outPos++
+				targetRel++
+			}
+		}
+	}
+
+	if outPos != len(out) {
+		return nil, fmt.Errorf("bps: target incomplete (wrote %d of %d bytes)", outPos, len(out))
+	}
+	if got := crc32.ChecksumIEEE(out); got != targetCRC {
+		return nil, fmt.Errorf("bps: target checksum mismatch (want %08x, got %08x)", targetCRC, got)
+	}
+	return out, nil
+}
+
+func bpsRelative(offset uint64) int {
+	if offset&1 != 0 {
+		return -int(offset >> 1)
+	}
+	return int(offset >> 1)
+}
+
+func le32(b []byte) uint32 {
+	return uint32(b[0]) | uint32(b[1])<<8 | uint32(b[2])<<16 | uint32(b[3])<<24
+}
+
+// ParsePatchSpec parses a command line patch spec of the form
+// "file[:baseCRC32[:resultCRC32]]", with checksums written in hex. A Windows
+// drive letter ("C:\fix.ips") is part of the file name.
+func ParsePatchSpec(spec string) (RomPatch, error) {
+	drive, rest := "", spec
+	if len(spec) > 2 && spec[1] == ':' && (spec[2] == '\\' || spec[2] == '/') &&
+		(spec[0] >= 'a' && spec[0] <= 'z' || spec[0] >= 'A' && spec[0] <= 'Z') {
+		drive, rest = spec[:2], spec[2:]
+	}
+	parts := strings.Split(rest, ":")
+	if len(parts) > 3 || parts[0] == "" {
+		return RomPatch{}, fmt.Errorf("bad patch spec %q, expected file[:baseCRC32[:resultCRC32]]", spec)
+	}
+	patch := RomPatch{Path: drive + parts[0]}
+	var err error
+	if len(parts) > 1 {
+		if patch.BaseCRC32, err = parseCRC32(parts[1]); err != nil {
+			return RomPatch{}, fmt.Errorf("bad patch spec %q: %v", spec, err)
+		}
+	}
+	if len(parts) > 2 {
+		if patch.ResultCRC32, err = parseCRC32(parts[2]); err != nil {
+			return RomPatch{}, fmt.Errorf("bad patch spec %q: %v", spec, err)
+		}
+	}
+	return patch, nil
+}
+
+func parseCRC32(s string) (uint32, error) {
+	if s == "" {
+		return 0, nil
+	}
+	v, err := strconv.ParseUint(strings.TrimPrefix(strings.ToLower(s), "0x"), 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("bad crc32 %q", s)
+	}
+	return uint32(v), nil
+}
@@ -0,0 +1,134 @@
+package intel8080
+
+import (
+	"bytes"
+	"hash/crc32"
+	"testing"
+)
+
+func TestApplyIPS(t *testing.T) {
+	base := []byte{0x00, 0x01, 0x02, 0x03, 0x04, 0x05}
+	patch := []byte("PATCH")
+	patch = append(patch, 0x00, 0x00, 0x01, 0x00, 0x02, 0xAA, 0xBB)       // 2 bytes at 0x0001
+	patch = append(patch, 0x00, 0x00, 0x05, 0x00, 0x00, 0x00, 0x03, 0xCC) // RLE 3 x 0xCC at 0x0005
+	patch = append(patch, []byte("EOF")...)
+
+	got, err := ApplyIPS(base, patch)
+	if err != nil {
+		t.Fatalf("ApplyIPS failed: %v\n", err)
+	}
+	want := []byte{0x00, 0xAA, 0xBB, 0x03, 0x04, 0xCC, 0xCC, 0xCC}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("expected: % x, got: % x\n", want, got)
+	}
+	if base[1] != 0x01 {
+		t.Errorf("ApplyIPS modified the base image\n")
+	}
+
+	// Truncation extension
+	truncated, err := ApplyIPS(base, append(append([]byte("PATCH"), []byte("EOF")...), 0x00, 0x00, 0x04))
+	if err != nil {
+		t.Fatalf("ApplyIPS failed: %v\n", err)
+	}
+	if len(truncated) != 4 {
+		t.Errorf("expected truncation to 4 bytes, got %d\n", len(truncated))
+	}
+
+	if _, err := ApplyIPS(base, []byte("PATCH\x00\x00")); err == nil {
+		t.Errorf("expected error on truncated patch\n")
+	}
+}
+
+func TestApplyBPS(t *testing.T) {
+	base := []byte("ABCDEFGH")
+	want := []byte("ABCxyzDEFGHHHH")
+
+	var body []byte
+	body = append(body, bpsMagic...)
+	body = append(body, bpsNumber(uint64(len(base)))...)
+	body = append(body, bpsNumber(uint64(len(want)))...)
+	body = append(body, bpsNumber(0)...)
+	body = append(body, bpsNumber(2<<2|0)...) // SourceRead "ABC"
+	body = append(body, bpsNumber(2<<2|1)...) // TargetRead "xyz"
+	body = append(body, []byte("xyz")...)
+	body = append(body, bpsNumber(4<<2|2)...) // SourceCopy "DEFGH" from offset 3
+	body = append(body, bpsNumber(3<<1)...)
+	body = append(body, bpsNumber(2<<2|3)...) // TargetCopy "HHH" from offset 10
+	body = append(body, bpsNumber(10<<1)...)
+	body = appendLE32(body, crc32.ChecksumIEEE(base))
+	body = appendLE32(body, crc32.ChecksumIEEE(want))
+	patch := appendLE32(body, crc32.ChecksumIEEE(body))
+
+	got, err := ApplyBPS(base, patch)
+	if err != nil {
+		t.Fatalf("ApplyBPS failed: %v\n", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("expected: %q, got: %q\n", want, got)
+	}
+
+	if _, err := ApplyBPS([]byte("ABCDEFGX"), patch); err == nil {
+		t.Errorf("expected source checksum error\n")
+	}
+	corrupt := append([]byte{}, patch...)
+	corrupt[len(corrupt)-20] ^= 0xFF
+	if _, err := ApplyBPS(base, corrupt); err == nil {
+		t.Errorf("expected patch checksum error\n")
+	}
+
+	// Sizes beyond the patch or the limit are refused before allocating
+	for _, sizes := range [][2]uint64{{maxPatchedSize + 1, 0}, {uint64(len(want)), 1 << 40}} {
+		body := append([]byte{}, bpsMagic...)
+		body = append(body, bpsNumber(uint64(len(base)))...)
+		body = append(body, bpsNumber(sizes[0])...)
+		body = append(body, bpsNumber(sizes[1])...)
+		body = appendLE32(body, crc32.ChecksumIEEE(base))
+		body = appendLE32(body, crc32.ChecksumIEEE(want))
+		if _, err := ApplyBPS(base, appendLE32(body, crc32.ChecksumIEEE(body))); err == nil {
+			t.Errorf("expected an error for target size %d, metadata size %d\n", sizes[0], sizes[1])
+		}
+	}
+}
+
+func TestParsePatchSpec(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    RomPatch
+		wantErr bool
+	}{
+		{"fix.ips", RomPatch{Path: "fix.ips"}, false},
+		{"fix.bps:1a2b3c4d", RomPatch{Path: "fix.bps", BaseCRC32: 0x1a2b3c4d}, false},
+		{"fix.ips:0x1A2B3C4D:deadbeef", RomPatch{Path: "fix.ips", BaseCRC32: 0x1a2b3c4d, ResultCRC32: 0xdeadbeef}, false},
+		{"fix.ips::deadbeef", RomPatch{Path: "fix.ips", ResultCRC32: 0xdeadbeef}, false},
+		{"fix.ips:nothex", RomPatch{}, true},
+		{`C:\roms\fix.ips`, RomPatch{Path: `C:\roms\fix.ips`}, false},
+		{`c:/roms/fix.bps:1a2b3c4d:deadbeef`, RomPatch{Path: `c:/roms/fix.bps`, BaseCRC32: 0x1a2b3c4d, ResultCRC32: 0xdeadbeef}, false},
+		{"", RomPatch{}, true},
+	}
+	for _, tt := range tests {
+		got, err := ParsePatchSpec(tt.input)
+		if (err != nil) != tt.wantErr {
+			t.Fatalf("input: %q, unexpected error state: %v\n", tt.input, err)
+		}
+		if got != tt.want {
+			t.Fatalf("input: %q, expected: %+v, got: %+v\n", tt.input, tt.want, got)
+		}
+	}
+}
+
+func bpsNumber(data uint64) []byte {
+	var out []byte
+	for {
+		x := byte(data & 0x7f)
+		data >>= 7
+		if data == 0 {
+			return append(out, 0x80|x)
+		}
+		out = append(out, x)
+		data--
+	}
+}
+
+func appendLE32(b []byte, v uint32) []byte {
+	return append(b, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+}
@@ -4,20 +4,50 @@ import (
 	"fmt"
 )
 
+// Step executes one instruction and returns the cycles it took.
+// Faults are returned as *InvalidOpcodeError, *ProtectedWriteError,
+// *UnmappedAccessError, *HaltedError or *BreakpointError. On a fault PC is left
+// on the faulting instruction, although registers may be partially updated.
 func (cpu *CPU) Step() (uint, error) {
-	opcode := cpu.memory.Read(cpu.PC)
-	opcodeFunc := cpu.lutOpcodeFunc[opcode]
+	if cpu.fault != nil {
+		// Raised by an interrupt outside of Step
+		err := cpu.fault
+		cpu.fault = nil
+		return 0, err
+	}
+	if cpu.Halted {
+		if !cpu.InterruptsEnabled && !cpu.deferInterruptsEnable {
+			return 0, &HaltedError{PC: cpu.PC - 1}
+		}
+		// Idle until an interrupt arrives
+		cpu.Cycles += 4
+		return 4, nil
+	}
+	if cpu.breakpoints[cpu.PC] && !cpu.resumeBreakpoint {
+		cpu.resumeBreakpoint = true
+		return 0, &BreakpointError{PC: cpu.PC}
+	}
+	cpu.resumeBreakpoint = false
+
 	stepInfo := stepInfo{
 		PC:     cpu.PC,
-		opcode: opcode,
+		opcode: cpu.read(cpu.PC),
 	}
+	opcode := stepInfo.opcode
+	if cpu.fault != nil {
+		return 0, cpu.takeFault(&stepInfo)
+	}
+	opcodeFunc := cpu.lutOpcodeFunc[opcode]
 
+	if cpu.history != nil {
+		cpu.recordTrace(cpu.PC, opcode)
+	}
 	if cpu.DEBUG {
 		dbgStr := cpu.GetInstructionInfo()
 		fmt.Println(dbgStr)
 	}
 	if opcodeFunc == nil {
-		return 0, fmt.Errorf("invalid opcode: 0x%02x\n (%s)", opcode, instructionNames[opcode])
+		return 0, &InvalidOpcodeError{PC: cpu.PC, Opcode: opcode}
 	}
 
 	if cpu.deferInterruptsEnable {
@@ -26,15 +56,59 @@ func (cpu *CPU) Step() (uint, error) {
 	}
 
 	// Execute current opcode
+	prevSP := cpu.SP
+	if cpu.sanitizer != nil {
+		cpu.sanitizer.beforeStep(stepInfo.PC, opcode)
+	}
 	pcAdvanceAmt := uint16(instructionBytes[opcode])
 	cycles := opcodeFunc(&stepInfo)
 	if pcAdvanceMask[opcode] == 1 {
 		cpu.PC += pcAdvanceAmt
 	}
+	if cpu.sanitizer != nil {
+		cpu.sanitizer.afterStep(cpu, stepInfo.PC, opcode, prevSP)
+	}
+	if cpu.fault != nil {
+		cpu.PC = stepInfo.PC
+		return 0, cpu.takeFault(&stepInfo)
+	}
+	cpu.Cycles += uint64(cycles)
 
 	return cycles, nil
 }
 
+func (cpu *CPU) takeFault(info *stepInfo) error {
+	err := withLocation(cpu.fault, info)
+	cpu.fault = nil
+	return err
+}
+
+// Run executes instructions until at least the given number of cycles have
+// passed, returning the cycles actually executed. It stops early on a fault.
+func (cpu *CPU) Run(cycles uint64) (uint64, error) {
+	var executed uint64
+	for executed < cycles {
+		n, err := cpu.Step()
+		executed += uint64(n)
+		if err != nil {
+			return executed, err
+		}
+	}
+	return executed, nil
+}
+
+// SetBreakpoint makes Step return a *BreakpointError when PC reaches address
+func (cpu *CPU) SetBreakpoint(address uint16) {
+	if cpu.breakpoints == nil {
+		cpu.breakpoints = map[uint16]bool{}
+	}
+	cpu.breakpoints[address] = true
+}
+
+func (cpu *CPU) ClearBreakpoint(address uint16) {
+	delete(cpu.breakpoints, address)
+}
+
 func (cpu *CPU) GetInstructionInfo() string {
 	opcode := cpu.memory.Read(cpu.PC)
 	bytes := instructionBytes[opcode]
@@ -0,0 +1,281 @@
+package intel8080
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SanitizerCheck selects one of the sanitizer's checks
+type SanitizerCheck uint
+
+const (
+	// CheckUninitializedRead flags reads of RAM/VRAM that was never written
+	CheckUninitializedRead SanitizerCheck = 1 << iota
+	// CheckStackBounds flags the stack pointer descending out of RAM (into ROM or VRAM)
+	CheckStackBounds
+	// CheckExecuteRAM flags executing instructions from RAM or VRAM
+	CheckExecuteRAM
+	// CheckSelfModifying flags writes to addresses that have already been executed
+	CheckSelfModifying
+	// CheckInterruptBalance flags interrupt handlers that return with a different SP than they entered with
+	CheckInterruptBalance
+	// CheckReturns flags returns to addresses that were never pushed by a CALL, RST or interrupt
+	CheckReturns
+
+	CheckAll = CheckUninitializedRead | CheckStackBounds | CheckExecuteRAM | CheckSelfModifying | CheckInterruptBalance | CheckReturns
+)
+
+var sanitizerCheckNames = map[string]SanitizerCheck{
+	"uninit": CheckUninitializedRead,
+	"stack":  CheckStackBounds,
+	"exec":   CheckExecuteRAM,
+	"smc":    CheckSelfModifying,
+	"irq":    CheckInterruptBalance,
+	"ret":    CheckReturns,
+	"all":    CheckAll,
+}
+
+// ParseSanitizerChecks parses a comma separated list of check names:
+// uninit, stack, exec, smc, irq, ret or all
+func ParseSanitizerChecks(s string) (SanitizerCheck, error) {
+	var checks SanitizerCheck
+	for _, name := range strings.Split(s, ",") {
+		check, ok := sanitizerCheckNames[strings.TrimSpace(name)]
+		if !ok {
+			return 0, fmt.Errorf("unknown sanitizer check %q", name)
+		}
+		checks |= check
+	}
+	return checks, nil
+}
+
+// SanitizerConfig describes the memory map being checked against
+type SanitizerConfig struct {
+	Checks    SanitizerCheck
+	RamStart  uint16
+	VramStart uint16
+	VramEnd   uint16 // exclusive
+}
+
+// InvadersSanitizerConfig returns the Space Invaders memory map with every check enabled
+func InvadersSanitizerConfig() SanitizerConfig {
+	return SanitizerConfig{
+		Checks:    CheckAll,
+		RamStart:  ramStart,
+		VramStart: vramStart,
+		VramEnd:   vramEnd,
+	}
+}
+
+// SanitizerReport describes one suspicious event
+type SanitizerReport struct {
+	Check   SanitizerCheck
+	PC      uint16
+	Address uint16
+	Message string
+
+	// Call sites on the shadow call stack, innermost first
+	Backtrace []uint16
+}
+
+func (r SanitizerReport) String() string {
+	sb := strings.Builder{}
+	fmt.Fprintf(&sb, "sanitizer: PC %04x: %s", r.PC, r.Message)
+	for _, pc := range r.Backtrace {
+		fmt.Fprintf(&sb, "\n    called from %04x", pc)
+	}
+	return sb.String()
+}
+
+type frameKind uint8
+
+const (
+	frameCall frameKind = iota
+	frameRst
+	frameInterrupt
+)
+
+// shadowFrame mirrors one return address the program pushed on its stack
+type shadowFrame struct {
+	kind       frameKind
+	callPC     uint16
+	returnAddr uint16
+	sp         uint16 // SP right after the return address was pushed
+}
+
+// Sanitizer watches a running CPU for suspicious program behaviour.
+// Reports are deduplicated by check and PC.
+type Sanitizer struct {
+	config SanitizerConfig
+
+	// OnReport is called for every new report (optional)
+	OnReport func(SanitizerReport)
+	Reports  []SanitizerReport
+
+	written  []bool
+	executed []bool
+	shadow   []shadowFrame
+	seen     map[[2]uint16]bool
+
+	executing bool
+	pc        uint16
+}
+
+func NewSanitizer(config SanitizerConfig) *Sanitizer {
+	return &Sanitizer{
+		config:   config,
+		written:  make([]bool, 0x10000),
+		executed: make([]bool, 0x10000),
+		seen:     map[[2]uint16]bool{},
+	}
+}
+
+// AttachSanitizer starts checking the program with s. Pass nil to detach.
+func (cpu *CPU) AttachSanitizer(s *Sanitizer) {
+	cpu.sanitizer = s
+	if s == nil {
+		cpu.memory.readHook = nil
+		cpu.memory.writeHook = nil
+		return
+	}
+	cpu.memory.readHook = s.memoryRead
+	cpu.memory.writeHook = s.memoryWrite
+}
+
+func (s *Sanitizer) enabled(check SanitizerCheck) bool {
+	return s.config.Checks&check != 0
+}
+
+func (s *Sanitizer) inRAM(address uint16) bool {
+	return address >= s.config.RamStart && address < s.config.VramEnd
+}
+
+func (s *Sanitizer) report(check SanitizerCheck, address uint16, format string, args ...interface{}) {
+	key := [2]uint16{uint16(check), s.pc}
+	if s.seen[key] {
+		return
+	}
+	s.seen[key] = true
+
+	backtrace := make([]uint16, 0, len(s.shadow))
+	for i := len(s.shadow) - 1; i >= 0; i-- {
+		backtrace = append(backtrace, s.shadow[i].callPC)
+	}
+	r := SanitizerReport{
+		Check:     check,
+		PC:        s.pc,
+		Address:   address,
+		Message:   fmt.Sprintf(format, args...),
+		Backtrace: backtrace,
+	}
+	s.Reports = append(s.Reports, r)
+	if s.OnReport != nil {
+		s.OnReport(r)
+	}
+}
+
+func (s *Sanitizer) memoryRead(address uint16) {
+	if !s.executing || !s.enabled(CheckUninitializedRead) {
+		return
+	}
+	if s.inRAM(address) && !s.written[address] {
+		s.report(CheckUninitializedRead, address, "read of never-written memory at %04x", address)
+	}
+}
+
+func (s *Sanitizer) memoryWrite(address uint16) {
+	s.written[address] = true
+	if s.executing && s.enabled(CheckSelfModifying) && s.executed[address] {
+		s.report(CheckSelfModifying, address, "write to already executed code at %04x", address)
+	}
+}
+
+func (s *Sanitizer) beforeStep(pc uint16, opcode uint8) {
+	s.pc = pc
+	s.executing = true
+	if s.enabled(CheckExecuteRAM) && s.inRAM(pc) {
+		s.report(CheckExecuteRAM, pc, "executing from RAM/VRAM at %04x", pc)
+	}
+	for i := uint16(0); i < uint16(instructionBytes[opcode]); i++ {
+		s.executed[pc+i] = true
+	}
+}
+
+func (s *Sanitizer) afterStep(cpu *CPU, pc uint16, opcode uint8, prevSP uint16) {
+	s.executing = false
+	switch {
+	case isCallOpcode(opcode) && cpu.SP == prevSP-2:
+		s.shadow = append(s.shadow, shadowFrame{frameCall, pc, pc + 3, cpu.SP})
+	case isRstOpcode(opcode) && cpu.SP == prevSP-2:
+		s.shadow = append(s.shadow, shadowFrame{frameRst, pc, pc + 1, cpu.SP})
+	case isRetOpcode(opcode) && cpu.SP == prevSP+2:
+		s.matchReturn(cpu.PC, prevSP)
+	case opcode == 0x31 || opcode == 0xF9: // LXI SP / SPHL reset the stack
+		s.dropFrames(cpu.SP, false)
+	default:
+		s.dropFrames(cpu.SP, true)
+	}
+
+	if s.enabled(CheckStackBounds) && cpu.SP < prevSP && (cpu.SP < s.config.RamStart || cpu.SP >= s.config.VramStart) {
+		s.report(CheckStackBounds, cpu.SP, "stack pointer %04x descended out of RAM", cpu.SP)
+	}
+}
+
+func (s *Sanitizer) interrupt(cpu *CPU, returnAddr uint16) {
+	s.pc = returnAddr
+	s.shadow = append(s.shadow, shadowFrame{frameInterrupt, returnAddr, returnAddr, cpu.SP})
+	if s.enabled(CheckStackBounds) && (cpu.SP < s.config.RamStart || cpu.SP >= s.config.VramStart) {
+		s.report(CheckStackBounds, cpu.SP, "stack pointer %04x descended out of RAM on interrupt", cpu.SP)
+	}
+}
+
+// matchReturn pops the shadow frame for a RET that jumped to returnAddr
+func (s *Sanitizer) matchReturn(returnAddr uint16, prevSP uint16) {
+	for i := len(s.shadow) - 1; i >= 0; i-- {
+		frame := s.shadow[i]
+		if frame.returnAddr != returnAddr || frame.sp != prevSP {
+			continue
+		}
+		s.shadow = s.shadow[:i]
+		return
+	}
+
+	// Nothing matched. If we're in an interrupt handler, its stack is unbalanced.
+	if n := len(s.shadow); n > 0 && s.shadow[n-1].kind == frameInterrupt && s.enabled(CheckInterruptBalance) {
+		frame := s.shadow[n-1]
+		s.report(CheckInterruptBalance, prevSP, "interrupt handler returned with SP %04x, entered with %04x", prevSP, frame.sp)
+		if frame.returnAddr == returnAddr {
+			s.shadow = s.shadow[:n-1]
+			return
+		}
+	}
+	if s.enabled(CheckReturns) {
+		s.report(CheckReturns, returnAddr, "return to %04x which was never pushed by a call", returnAddr)
+	}
+	s.dropFrames(prevSP+2, false)
+}
+
+// dropFrames discards frames whose return address is no longer on the stack
+func (s *Sanitizer) dropFrames(sp uint16, checkInterrupts bool) {
+	for n := len(s.shadow); n > 0 && s.shadow[n-1].sp < sp; n = len(s.shadow) {
+		frame := s.shadow[n-1]
+		if checkInterrupts && frame.kind == frameInterrupt && s.enabled(CheckInterruptBalance) {
+			s.report(CheckInterruptBalance, sp, "interrupt handler popped past its return address (SP %04x, entered with %04x)", sp, frame.sp)
+		}
+		s.shadow = s.shadow[:n-1]
+	}
+}
+
+func isCallOpcode(opcode uint8) bool {
+	// CALL and Ccc are 11ccc100 / 11001101
+	return opcode == 0xCD || opcode&0b11000111 == 0b11000100
+}
+
+func isRstOpcode(opcode uint8) bool {
+	return opcode&0b11000111 == 0b11000111
+}
+
+func isRetOpcode(opcode uint8) bool {
+	// RET and Rcc are 11001001 / 11ccc000
+	return opcode == 0xC9 || opcode&0b11000111 == 0b11000000
+}
@@ -0,0 +1,121 @@
+package intel8080
+
+import (
+	"testing"
+)
+
+func newSanitizedCPU(t *testing.T, program []byte) (*CPU, *Sanitizer) {
+	memory := NewMemory(0x3FFF)
+	for i, b := range program {
+		memory.Write(uint16(i), b)
+	}
+	tCpu := NewCPU(NewIOBus(), memory)
+	s := NewSanitizer(InvadersSanitizerConfig())
+	tCpu.AttachSanitizer(s)
+	return tCpu, s
+}
+
+func stepN(t *testing.T, tCpu *CPU, n int) {
+	for i := 0; i < n; i++ {
+		if _, err := tCpu.Step(); err != nil {
+			t.Fatalf("Step failed: %v\n", err)
+		}
+	}
+}
+
+func TestSanitizer(t *testing.T) {
+	program := make([]byte, 0x31)
+	copy(program[0x00:], []byte{
+		0x31, 0x00, 0x24, // LXI SP,0x2400
+		0xCD, 0x30, 0x00, // CALL 0x0030
+		0x3A, 0x00, 0x20, // LDA 0x2000     <- uninitialized read
+		0x32, 0x03, 0x00, // STA 0x0003     <- self-modifying code
+		0x21, 0x20, 0x00, // LXI H,0x0020
+		0xE5, //             PUSH H
+		0xC9, //             RET            <- return never pushed by a call
+	})
+	copy(program[0x20:], []byte{0xC3, 0x00, 0x22}) // JMP 0x2200 <- executing from RAM
+	program[0x30] = 0xC9                           // RET
+	tCpu, s := newSanitizedCPU(t, program)
+	stepN(t, tCpu, 10)
+
+	want := []struct {
+		check SanitizerCheck
+		pc    uint16
+	}{
+		{CheckUninitializedRead, 0x0006},
+		{CheckSelfModifying, 0x0009},
+		{CheckReturns, 0x0010},
+		{CheckExecuteRAM, 0x2200},
+	}
+	if len(s.Reports) != len(want) {
+		t.Fatalf("expected %d reports, got %d: %v\n", len(want), len(s.Reports), s.Reports)
+	}
+	for i, w := range want {
+		if s.Reports[i].Check != w.check || s.Reports[i].PC != w.pc {
+			t.Errorf("report %d: expected check %d at %04x, got %s\n", i, w.check, w.pc, s.Reports[i])
+		}
+	}
+}
+
+func TestSanitizerInterruptBalance(t *testing.T) {
+	program := []byte{
+		0x31, 0x00, 0x24, // LXI SP,0x2400
+		0xFB,             // EI
+		0x00,             // NOP
+		0xC3, 0x05, 0x00, // JMP 0x0005
+		0xF5, //             PUSH PSW       <- RST 1 handler, never popped
+		0xC9, //             RET
+	}
+	tCpu, s := newSanitizedCPU(t, program)
+	stepN(t, tCpu, 3)
+	tCpu.Interrupt(1)
+	stepN(t, tCpu, 2)
+
+	if len(s.Reports) == 0 || s.Reports[0].Check != CheckInterruptBalance {
+		t.Fatalf("expected interrupt balance report, got: %v\n", s.Reports)
+	}
+	if len(s.Reports[0].Backtrace) != 1 || s.Reports[0].Backtrace[0] != 0x0005 {
+		t.Errorf("expected backtrace to the interrupted PC 0005, got: %04x\n", s.Reports[0].Backtrace)
+	}
+}
+
+func TestSanitizerStackBounds(t *testing.T) {
+	program := []byte{
+		0x31, 0x01, 0x20, // LXI SP,0x2001
+		0xC5, //             PUSH B         <- SP descends into ROM
+	}
+	tCpu, s := newSanitizedCPU(t, program)
+	stepN(t, tCpu, 2)
+	if len(s.Reports) != 1 || s.Reports[0].Check != CheckStackBounds {
+		t.Fatalf("expected stack bounds report, got: %v\n", s.Reports)
+	}
+
+	// A push that leaves SP at the start of VRAM wrote into VRAM
+	program = []byte{
+		0x31, 0x02, 0x24, // LXI SP,0x2402
+		0xC5, //             PUSH B         <- writes 0x2400-0x2401
+	}
+	tCpu, s = newSanitizedCPU(t, program)
+	stepN(t, tCpu, 2)
+	if len(s.Reports) != 1 || s.Reports[0].Check != CheckStackBounds || s.Reports[0].Address != 0x2400 {
+		t.Fatalf("expected stack bounds report at 0x2400, got: %v\n", s.Reports)
+	}
+
+	// The top of the stack at VRAM start is fine
+	tCpu, s = newSanitizedCPU(t, []byte{0x31, 0x00, 0x24, 0xC5})
+	stepN(t, tCpu, 2)
+	if len(s.Reports) != 0 {
+		t.Fatalf("expected no report for SP 0x2400 before the push, got: %v\n", s.Reports)
+	}
+}
+
+func TestParseSanitizerChecks(t *testing.T) {
+	got, err := ParseSanitizerChecks("exec, smc")
+	if err != nil || got != CheckExecuteRAM|CheckSelfModifying {
+		t.Fatalf("unexpected result: %v, %v\n", got, err)
+	}
+	if _, err := ParseSanitizerChecks("exec,bogus"); err == nil {
+		t.Fatalf("expected error for unknown check\n")
+	}
+}
@@ -0,0 +1,130 @@
+package intel8080
+
+import (
+	"container/heap"
+)
+
+// EventFunc is called when a scheduled event comes due. now is the CPU cycle
+// count when it ran, which can be past the deadline by up to one instruction.
+type EventFunc func(now uint64)
+
+// Event is a callback scheduled at an absolute CPU cycle
+type Event struct {
+	Name string
+	At   uint64
+
+	fn    EventFunc
+	seq   uint64
+	index int
+}
+
+// Scheduler runs the CPU between device deadlines. Devices register callbacks
+// at absolute cycle times (timers, video lines, serial bit clocks, watchdogs)
+// and RunUntil executes instructions up to the next deadline before firing it.
+// Events due at the same cycle fire in the order they were scheduled, so runs
+// are deterministic.
+type Scheduler struct {
+	cpu    *CPU
+	events eventQueue
+	seq    uint64
+}
+
+func NewScheduler(cpu *CPU) *Scheduler {
+	return &Scheduler{cpu: cpu}
+}
+
+// Now returns the current CPU cycle count
+func (s *Scheduler) Now() uint64 {
+	return s.cpu.Cycles
+}
+
+// Schedule calls fn once the CPU cycle count reaches at. Events scheduled in
+// the past fire before the next instruction.
+func (s *Scheduler) Schedule(at uint64, name string, fn EventFunc) *Event {
+	e := &Event{Name: name, At: at, fn: fn, seq: s.seq}
+	s.seq++
+	heap.Push(&s.events, e)
+	return e
+}
+
+// After calls fn delay cycles from now
+func (s *Scheduler) After(delay uint64, name string, fn EventFunc) *Event {
+	return s.Schedule(s.Now()+delay, name, fn)
+}
+
+// Cancel removes a pending event. Cancelling an event that already fired does nothing.
+func (s *Scheduler) Cancel(e *Event) {
+	if e.index >= 0 && e.index < len(s.events) && s.events[e.index] == e {
+		heap.Remove(&s.events, e.index)
+	}
+}
+
+// Clear removes every pending event, as when restoring a snapshot
+func (s *Scheduler) Clear() {
+	for _, e := range s.events {
+		e.index = -1
+	}
+	s.events = nil
+}
+
+// Pending returns the number of events waiting to fire
+func (s *Scheduler) Pending() int {
+	return len(s.events)
+}
+
+// RunUntil executes instructions, firing events as they come due, until the
+// cycle count reaches target. Events at or after target are left for the next
+// call, even if the last instruction overshot them. It stops early if the CPU faults.
+func (s *Scheduler) RunUntil(target uint64) error {
+	for {
+		for len(s.events) > 0 && s.events[0].At <= s.cpu.Cycles && s.events[0].At < target {
+			e := heap.Pop(&s.events).(*Event)
+			e.fn(s.cpu.Cycles)
+		}
+		if s.cpu.Cycles >= target {
+			return nil
+		}
+
+		next := target
+		if len(s.events) > 0 && s.events[0].At < next {
+			next = s.events[0].At
+		}
+		if _, err := s.cpu.Run(next - s.cpu.Cycles); err != nil {
+			return err
+		}
+	}
+}
+
+// eventQueue is a min-heap of events ordered by deadline, then scheduling order
+type eventQueue []*Event
+
+func (q eventQueue) Len() int { return len(q) }
+
+func (q eventQueue) Less(i, j int) bool {
+	if q[i].At != q[j].At {
+		return q[i].At < q[j].At
+	}
+	return q[i].seq < q[j].seq
+}
+
+func (q eventQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *eventQueue) Push(x interface{}) {
+	e := x.(*Event)
+	e.index = len(*q)
+	*q = append(*q, e)
+}
+
+func (q *eventQueue) Pop() interface{} {
+	old := *q
+	n := len(old)
+	e := old[n-1]
+	old[n-1] = nil
+	e.index = -1
+	*q = old[:n-1]
+	return e
+}
@@ -0,0 +1,58 @@
+package intel8080
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSchedulerOrdering(t *testing.T) {
+	// JMP 0x0000 (10 cycles per instruction)
+	memory := NewMemory(0xFF)
+	for i, b := range []byte{0xC3, 0x00, 0x00} {
+		memory.Write(uint16(i), b)
+	}
+	tCpu := NewCPU(NewIOBus(), memory)
+	s := NewScheduler(tCpu)
+
+	var fired []string
+	var firedAt []uint64
+	record := func(name string) EventFunc {
+		return func(now uint64) {
+			fired = append(fired, name)
+			firedAt = append(firedAt, now)
+		}
+	}
+	s.Schedule(25, "b", record("b"))
+	s.Schedule(5, "a", record("a"))
+	s.Schedule(25, "c", record("c"))
+	cancelled := s.Schedule(15, "cancelled", record("cancelled"))
+	s.Schedule(30, "reschedules", func(now uint64) {
+		fired = append(fired, "reschedules")
+		firedAt = append(firedAt, now)
+		s.After(0, "immediate", record("immediate"))
+		s.Schedule(1000, "later", record("later"))
+	})
+	s.Cancel(cancelled)
+
+	if err := s.RunUntil(100); err != nil {
+		t.Fatalf("RunUntil failed: %v\n", err)
+	}
+
+	wantFired := []string{"a", "b", "c", "reschedules", "immediate"}
+	wantAt := []uint64{10, 30, 30, 30, 30}
+	if !reflect.DeepEqual(fired, wantFired) || !reflect.DeepEqual(firedAt, wantAt) {
+		t.Fatalf("expected %v at %v, got %v at %v\n", wantFired, wantAt, fired, firedAt)
+	}
+	if tCpu.Cycles != 100 || s.Pending() != 1 {
+		t.Fatalf("expected to stop at cycle 100 with 1 pending event, got %d with %d\n", tCpu.Cycles, s.Pending())
+	}
+}
+
+func TestSchedulerStopsOnFault(t *testing.T) {
+	memory := NewMemory(0xFF)
+	memory.Write(0x02, 0x08) // invalid opcode after two NOPs
+	s := NewScheduler(NewCPU(NewIOBus(), memory))
+	if err := s.RunUntil(100); err == nil {
+		t.Fatalf("expected RunUntil to return the CPU fault\n")
+	}
+}
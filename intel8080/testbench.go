@@ -7,21 +7,19 @@ import (
 
 var cpu *CPU
 
-func RunTestRom(testRomPath string) {
+// RunTestRom runs a CP/M test ROM until it calls the BDOS warm boot, which
+// exits, or until the CPU faults or halts, which is returned
+func RunTestRom(testRomPath string) error {
 	ioBus := NewIOBus()
 	memory := NewMemory(0xFFFF)
 	count, err := memory.LoadRomFiles([]string{
 		testRomPath,
 	}, 0x100, false)
 	if err != nil {
-		fmt.Printf("Error loading ROM file: %s\n", err)
-		os.Exit(1)
+		return fmt.Errorf("Error loading ROM file: %s", err)
 	}
 
 	fmt.Printf("%d bytes loaded\n", count)
-	if err != nil {
-		fmt.Printf("LoadRomFiles failed: %v\n", err)
-	}
 
 	cpu = NewCPU(ioBus, memory)
 
@@ -66,6 +64,8 @@ func RunTestRom(testRomPath string) {
 
 	// Run the test
 	for {
-		_, _ = cpu.Step()
+		if _, err := cpu.Step(); err != nil {
+			return err
+		}
 	}
 }
@@ -0,0 +1,248 @@
+//go:build !nosdl
+// +build !nosdl
+
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/veandco/go-sdl2/sdl"
+	"intel8080/audio"
+	"intel8080/audio/sdlaudio"
+	"intel8080/display"
+	"intel8080/display/sdlrender"
+	"intel8080/input"
+	"intel8080/machine"
+	"intel8080/netplay"
+)
+
+// runInteractive plays the game in an SDL window until quit. With a netplay
+// peer, frames run through its session.
+func runInteractive(m *machine.Machine, overlay *display.Overlay, peer *netPeer) error {
+	scale, err := display.ParseScaleMode(*scaleMode)
+	if err != nil {
+		return err
+	}
+	keys, err := loadKeyMap(false)
+	if err != nil {
+		return err
+	}
+	screenWidth, screenHeight := m.ScreenSize()
+	aspect := m.ScreenAspect()
+	if *squarePixels {
+		aspect = 0
+	}
+	windowWidth := int32(2 * screenWidth)
+	if aspect > 0 {
+		windowWidth = int32(2 * float64(screenHeight) * aspect)
+	}
+	renderer, err := sdlrender.New(sdlrender.Options{
+		Title:      "Intel 8080 Emulator",
+		Width:      windowWidth,
+		Height:     int32(2 * screenHeight),
+		Scale:      scale,
+		Aspect:     aspect,
+		VSync:      *vsync,
+		Fullscreen: *fullscreen,
+		Smooth:     *smooth,
+	})
+	if err != nil {
+		return err
+	}
+	defer renderer.Close()
+	var player *sdlaudio.Player
+	if m.Sound() != nil {
+		// Sound is optional too: without an audio device the game plays silent
+		if p, err := sdlaudio.Open(audio.DefaultSampleRate); err != nil {
+			fmt.Printf("sound unavailable: %v\n", err)
+		} else {
+			player = p
+			defer player.Close()
+		}
+	}
+	fb := display.NewFramebuffer(screenWidth, screenHeight)
+	c, err := newCapture(m)
+	if err != nil {
+		return err
+	}
+	f, err := newFilters(*filterSpec)
+	if err != nil {
+		return err
+	}
+	out := fb
+	defer func() {
+		if err := c.close(m.Frame, out); err != nil {
+			fmt.Printf("I/O error: %v\n", err)
+		}
+	}()
+	cpu, memory, ioBus := m.CPU, m.Memory, m.IOBus
+
+	// Controllers are optional, so a failure only loses them
+	if err := sdl.InitSubSystem(sdl.INIT_GAMECONTROLLER); err != nil {
+		fmt.Printf("game controllers unavailable: %v\n", err)
+	}
+	pads := &gamepads{keys: keys}
+	defer pads.close()
+
+	pacer := machine.NewPacer(machine.RefreshRate)
+	pacer.SetSpeed(*speed)
+	speedBeforeFastForward := pacer.Speed()
+	paused := false
+	frameAdvance := false
+	dips := &dipMenu{}
+	// ioErr is a failure writing captures, which stops the game without a
+	// crash bundle
+	var ioErr error
+
+	// handle presses or releases a key or controller binding
+	handle := func(b input.Binding, pressed bool) {
+		if b.Action == "" {
+			if peer != nil {
+				peer.press(b.Input, pressed)
+			} else {
+				ioBus.HandleInput(b.Input.Port, b.Input.Bit, pressed)
+			}
+			return
+		}
+		if peer != nil && peer.blocked(b.Action, pressed) {
+			return
+		}
+		if b.Action == input.ActionFastForward {
+			// Fast-forward while held
+			if pressed {
+				speedBeforeFastForward = pacer.Speed()
+				pacer.SetSpeed(*fastForward)
+			} else {
+				pacer.SetSpeed(speedBeforeFastForward)
+			}
+			return
+		}
+		if !pressed {
+			return
+		}
+		switch b.Action {
+		case input.ActionQuit:
+			running = false
+		case input.ActionScreenshot:
+			c.screenshot(m.Frame, out)
+		case input.ActionGIF:
+			c.toggleGIF()
+		case input.ActionFilter:
+			f.next(c)
+		case input.ActionFullscreen:
+			_ = renderer.ToggleFullscreen()
+		case input.ActionSaveState:
+			saveState(m)
+		case input.ActionLoadState:
+			loadState(m)
+		case input.ActionDIPMenu:
+			dips.next(m)
+		case input.ActionDIPChange:
+			dips.change(m)
+		case input.ActionDebugCPU:
+			cpu.DEBUG = !cpu.DEBUG
+		case input.ActionDebugIO:
+			ioBus.DEBUG = !ioBus.DEBUG
+		case input.ActionDebugMemory:
+			memory.DEBUG = !memory.DEBUG
+		case input.ActionPause:
+			paused = !paused
+			fmt.Printf("paused: %v\n", paused)
+		case input.ActionFrameAdvance:
+			if paused {
+				frameAdvance = true
+			}
+		case input.ActionSlowMotion:
+			if pacer.Speed() == *slowMotion {
+				pacer.SetSpeed(1)
+			} else {
+				pacer.SetSpeed(*slowMotion)
+			}
+			fmt.Printf("speed: %.2fx\n", pacer.Speed())
+		case input.ActionSpeedDown:
+			if pacer.Speed() > 0.25 {
+				pacer.SetSpeed(pacer.Speed() - 0.25)
+			}
+			fmt.Printf("speed: %.2fx\n", pacer.Speed())
+		case input.ActionSpeedUp:
+			pacer.SetSpeed(pacer.Speed() + 0.25)
+			fmt.Printf("speed: %.2fx\n", pacer.Speed())
+		}
+	}
+
+	fmt.Println("Starting CPU")
+	for running {
+		if dumpOnInterrupt(cpu) {
+			running = false
+			break
+		}
+		ran := false
+		if peer != nil {
+			ran, err = peer.runFrame()
+		} else if !paused || frameAdvance {
+			frameAdvance = false
+			ran, err = true, runFrameCatchingPanics(m)
+		}
+		var fault *netplay.FrameError
+		if peer != nil && err != nil && !errors.As(err, &fault) {
+			// Lost the peer or out of sync: the machine is fine
+			fmt.Printf("%v\n", err)
+			running = false
+		} else if err != nil {
+			fmt.Printf("CPU Execution error: %v\n", err)
+			writeCrashBundle(cpu, err)
+			running = false
+		}
+		if ran {
+			if err := m.DrawScreen(fb, overlay); err != nil {
+				return err
+			}
+			out = f.Apply(fb)
+			if pacer.Show() {
+				_ = renderer.Render(out)
+			}
+			if ioErr = c.frame(m.Frame, out, m.Audio()); ioErr != nil {
+				running = false
+			}
+			if player != nil {
+				_ = player.Queue(m.Audio())
+			}
+		}
+
+		for event := sdl.PollEvent(); event != nil; event = sdl.PollEvent() {
+			if pads.handle(event, handle) {
+				continue
+			}
+			switch t := event.(type) {
+			case *sdl.KeyboardEvent:
+				if t.Repeat != 0 {
+					break
+				}
+				if b, ok := keys.Key(keyName(t.Keysym)); ok {
+					handle(b, t.Type == sdl.KEYDOWN)
+				}
+			case *sdl.QuitEvent:
+				println("Quit")
+				running = false
+			}
+		}
+		pacer.Wait()
+	}
+	if ioErr != nil {
+		return fmt.Errorf("I/O error: %v", ioErr)
+	}
+	return nil
+}
+
+// keyName names a key for the key map, with its Ctrl and Alt modifiers
+func keyName(k sdl.Keysym) string {
+	name := sdl.GetKeyName(k.Sym)
+	if k.Mod&sdl.KMOD_ALT != 0 {
+		name = "alt+" + name
+	}
+	if k.Mod&sdl.KMOD_CTRL != 0 {
+		name = "ctrl+" + name
+	}
+	return name
+}
@@ -0,0 +1,155 @@
+package machine
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// DIPSwitch is a game setting made with the DIP switches on the board. The
+// switches are read through input ports, like the controls.
+type DIPSwitch struct {
+	Name string
+	Port uint8
+	Mask byte
+	// Values maps each setting to its switch bits (within Mask)
+	Values  map[string]byte
+	Default string
+	Help    string
+}
+
+// DIPSwitches are the Invaders DIP switches. The game reads them when a game
+// starts, so changes take effect from the next credit.
+var DIPSwitches = []DIPSwitch{
+	{Name: "lives", Port: 2, Mask: 0x03, Values: map[string]byte{"3": 0x00, "4": 0x01, "5": 0x02, "6": 0x03},
+		Default: "3", Help: "ships per game"},
+	{Name: "bonus", Port: 2, Mask: 0x08, Values: map[string]byte{"1500": 0x00, "1000": 0x08},
+		Default: "1500", Help: "score for the extra ship"},
+	{Name: "coin_info", Port: 2, Mask: 0x80, Values: map[string]byte{"on": 0x00, "off": 0x80},
+		Default: "on", Help: "show the coin information on the attract screen"},
+	{Name: "dip4", Port: 0, Mask: 0x01, Values: map[string]byte{"off": 0x00, "on": 0x01},
+		Default: "off", Help: "DIP switch 4, read by the self test"},
+}
+
+// FindDIPSwitch looks up a DIP switch by name
+func FindDIPSwitch(name string) (*DIPSwitch, error) {
+	for i := range DIPSwitches {
+		if DIPSwitches[i].Name == name {
+			return &DIPSwitches[i], nil
+		}
+	}
+	names := make([]string, len(DIPSwitches))
+	for i, d := range DIPSwitches {
+		names[i] = d.Name
+	}
+	return nil, fmt.Errorf("unknown DIP switch %q (want one of %v)", name, names)
+}
+
+// Settings returns the switch's settings, in order of their switch bits
+func (d *DIPSwitch) Settings() []string {
+	settings := make([]string, 0, len(d.Values))
+	for s := range d.Values {
+		settings = append(settings, s)
+	}
+	sort.Slice(settings, func(i, j int) bool { return d.Values[settings[i]] < d.Values[settings[j]] })
+	return settings
+}
+
+// DIPSettings are DIP switch settings by switch name
+type DIPSettings map[string]string
+
+// ParseDIPSettings parses settings given as name=value pairs separated by
+// commas, such as "lives=5,bonus=1000"
+func ParseDIPSettings(s string) (DIPSettings, error) {
+	settings := DIPSettings{}
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		i := strings.IndexByte(pair, '=')
+		if i < 0 {
+			return nil, fmt.Errorf("bad DIP switch setting %q (want name=value)", pair)
+		}
+		settings[strings.TrimSpace(pair[:i])] = strings.TrimSpace(pair[i+1:])
+	}
+	return settings, settings.Validate()
+}
+
+// LoadDIPSettings reads settings from a JSON object of switch names and
+// values, such as {"lives": "5", "bonus": "1000"}
+func LoadDIPSettings(path string) (DIPSettings, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("loadDIPSettings: failed reading file: %v", err)
+	}
+	var settings DIPSettings
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return nil, fmt.Errorf("loadDIPSettings: %s: %v", path, err)
+	}
+	if err := settings.Validate(); err != nil {
+		return nil, fmt.Errorf("loadDIPSettings: %s: %v", path, err)
+	}
+	return settings, nil
+}
+
+// Validate checks every setting names a switch and one of its values
+func (s DIPSettings) Validate() error {
+	for name, value := range s {
+		d, err := FindDIPSwitch(name)
+		if err != nil {
+			return err
+		}
+		if _, ok := d.Values[value]; !ok {
+			return fmt.Errorf("bad value %q for DIP switch %s (want one of %v)", value, name, d.Settings())
+		}
+	}
+	return nil
+}
+
+// SetDIPSwitches sets the DIP switches, leaving those not in settings as
+// they are
+func (m *Machine) SetDIPSwitches(settings DIPSettings) error {
+	if err := settings.Validate(); err != nil {
+		return err
+	}
+	for name, value := range settings {
+		d, _ := FindDIPSwitch(name)
+		bits := d.Values[value]
+		for bit := uint8(0); bit < 8; bit++ {
+			if d.Mask&(1<<bit) != 0 {
+				m.IOBus.HandleInput(d.Port, bit, bits&(1<<bit) != 0)
+			}
+		}
+	}
+	return nil
+}
+
+// DIPSwitch returns the current setting of a DIP switch, or "" if its bits
+// match none
+func (m *Machine) DIPSwitch(name string) string {
+	d, err := FindDIPSwitch(name)
+	if err != nil {
+		return ""
+	}
+	bits := m.IOBus.InputBits(d.Port) & d.Mask
+	for value, b := range d.Values {
+		if b == bits {
+			return value
+		}
+	}
+	return ""
+}
+
+// gameModeAddr is where Invaders keeps 1 while a game is played, and 0 in
+// the attract mode
+const gameModeAddr = 0x20ef
+
+// InGame reports whether a game is being played, as opposed to the attract
+// mode between credits
+func (m *Machine) InGame() bool {
+	// Peek, rather than Read, so sanitizers don't see the access
+	return *m.Memory.GetOffsetPtr(gameModeAddr) != 0
+}
@@ -0,0 +1,90 @@
+package machine
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDIPSwitches(t *testing.T) {
+	m := newTestMachine(t, nil)
+	for _, d := range DIPSwitches {
+		if got := m.DIPSwitch(d.Name); got != d.Default {
+			t.Errorf("%s: expected default %q, got %q\n", d.Name, d.Default, got)
+		}
+	}
+
+	m.IOBus.HandleInput(2, 2, true) // tilt stays as it is
+	settings, err := ParseDIPSettings("lives=5, bonus=1000,coin_info=off,dip4=on")
+	if err != nil {
+		t.Fatalf("ParseDIPSettings failed: %v\n", err)
+	}
+	if err := m.SetDIPSwitches(settings); err != nil {
+		t.Fatalf("SetDIPSwitches failed: %v\n", err)
+	}
+	if got := m.IOBus.Read(2); got&0x8f != 0x8e {
+		t.Errorf("expected port 2 = 1xxx 1110, got %08b\n", got)
+	}
+	if got := m.IOBus.Read(0); got&0x01 != 0x01 {
+		t.Errorf("expected port 0 bit 0 set, got %08b\n", got)
+	}
+	for name, want := range settings {
+		if got := m.DIPSwitch(name); got != want {
+			t.Errorf("%s: expected %q, got %q\n", name, want, got)
+		}
+	}
+
+	if err := m.SetDIPSwitches(DIPSettings{"lives": "6"}); err != nil {
+		t.Fatalf("SetDIPSwitches failed: %v\n", err)
+	}
+	if got := m.IOBus.Read(2); got&0x8f != 0x8f {
+		t.Errorf("expected only the lives to change, got %08b\n", got)
+	}
+}
+
+func TestDIPSettingsErrors(t *testing.T) {
+	for _, s := range []string{"lives", "lives=7", "extra=1", "bonus=1000,dip4=maybe"} {
+		if _, err := ParseDIPSettings(s); err == nil {
+			t.Errorf("%q: expected an error\n", s)
+		}
+	}
+	m := newTestMachine(t, nil)
+	if err := m.SetDIPSwitches(DIPSettings{"lives": "2"}); err == nil {
+		t.Errorf("expected an error for 2 lives\n")
+	}
+	if got := m.IOBus.Read(2); got != 0 {
+		t.Errorf("expected a rejected setting to change nothing, got %08b\n", got)
+	}
+}
+
+func TestLoadDIPSettings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dip.json")
+	if err := os.WriteFile(path, []byte(`{"lives": "4", "coin_info": "off"}`), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v\n", err)
+	}
+	settings, err := LoadDIPSettings(path)
+	if err != nil {
+		t.Fatalf("LoadDIPSettings failed: %v\n", err)
+	}
+	if len(settings) != 2 || settings["lives"] != "4" || settings["coin_info"] != "off" {
+		t.Errorf("unexpected settings %v\n", settings)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"lives": "9"}`), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v\n", err)
+	}
+	if _, err := LoadDIPSettings(path); err == nil {
+		t.Errorf("expected an error for 9 lives\n")
+	}
+}
+
+func TestInGame(t *testing.T) {
+	m := newTestMachine(t, nil)
+	if m.InGame() {
+		t.Errorf("expected the attract mode\n")
+	}
+	_ = m.Memory.Write(gameModeAddr, 1)
+	if !m.InGame() {
+		t.Errorf("expected a game in progress\n")
+	}
+}
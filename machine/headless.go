@@ -0,0 +1,130 @@
+package machine
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"intel8080/display"
+)
+
+// ScriptEvent presses or releases an input before the given frame runs
+type ScriptEvent struct {
+	Frame   uint64
+	Input   Input
+	Pressed bool
+}
+
+// Script is an input timeline, sorted by frame
+type Script []ScriptEvent
+
+// ParseScript reads an input timeline. Each line is "frame input action",
+// where input is a name from Inputs or port.bit and action is press or
+// release. Blank lines and text after # are ignored.
+//
+//	60  coin    press
+//	64  coin    release
+//	120 p1start press
+func ParseScript(r io.Reader) (Script, error) {
+	var script Script
+	scanner := bufio.NewScanner(r)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("script line %d: expected \"frame input press|release\"", lineNo)
+		}
+		frame, err := strconv.ParseUint(fields[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("script line %d: bad frame %q", lineNo, fields[0])
+		}
+		input, err := ParseInput(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("script line %d: %v", lineNo, err)
+		}
+		var pressed bool
+		switch fields[2] {
+		case "press":
+			pressed = true
+		case "release":
+		default:
+			return nil, fmt.Errorf("script line %d: bad action %q (want press or release)", lineNo, fields[2])
+		}
+		script = append(script, ScriptEvent{Frame: frame, Input: input, Pressed: pressed})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(script, func(i, j int) bool { return script[i].Frame < script[j].Frame })
+	return script, nil
+}
+
+func LoadScript(path string) (Script, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseScript(f)
+}
+
+// HeadlessOptions configures RunHeadless
+type HeadlessOptions struct {
+	// Frames to run
+	Frames uint64
+	Script Script
+	// Capture lists the frame counts after which capture is called. The last
+	// frame is always captured when CaptureLast is set, and every frame when
+	// CaptureAll is.
+	Capture     []uint64
+	CaptureLast bool
+	CaptureAll  bool
+	Overlay     *display.Overlay
+}
+
+// RunHeadless runs the machine for opts.Frames frames without a display,
+// applying the script and handing the requested frames to capture. Frame n is
+// the picture after n frames have run; script events for frame n are applied
+// before the frame after it starts. It stops at the first CPU or capture error.
+func (m *Machine) RunHeadless(opts HeadlessOptions, capture func(frame uint64, fb *display.Framebuffer) error) error {
+	want := map[uint64]bool{}
+	for _, f := range opts.Capture {
+		want[f] = true
+	}
+	fb := display.NewFramebuffer(m.ScreenSize())
+	emit := func() error {
+		if err := m.DrawScreen(fb, opts.Overlay); err != nil {
+			return err
+		}
+		return capture(m.Frame, fb)
+	}
+
+	start := m.Frame
+	script := opts.Script
+	for m.Frame-start < opts.Frames {
+		for len(script) > 0 && script[0].Frame <= m.Frame {
+			m.IOBus.HandleInput(script[0].Input.Port, script[0].Input.Bit, script[0].Pressed)
+			script = script[1:]
+		}
+		if err := m.RunFrame(); err != nil {
+			return err
+		}
+		last := m.Frame-start == opts.Frames && opts.CaptureLast
+		if want[m.Frame] || last || opts.CaptureAll {
+			if err := emit(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package machine
+
+import (
+	"strings"
+	"testing"
+
+	"intel8080/display"
+)
+
+func TestParseScript(t *testing.T) {
+	script, err := ParseScript(strings.NewReader(`
+# insert a coin, then start
+64  coin    release
+60  coin    press   # hold for 4 frames
+120 1.2     press
+`))
+	if err != nil {
+		t.Fatalf("ParseScript failed: %v\n", err)
+	}
+	want := Script{
+		{60, Input{1, 0}, true},
+		{64, Input{1, 0}, false},
+		{120, Input{1, 2}, true},
+	}
+	if len(script) != len(want) {
+		t.Fatalf("expected %v, got %v\n", want, script)
+	}
+	for i := range want {
+		if script[i] != want[i] {
+			t.Fatalf("event %d: expected %v, got %v\n", i, want[i], script[i])
+		}
+	}
+
+	for _, bad := range []string{"60 coin", "x coin press", "60 nope press", "60 coin hold", "60 1.9 press"} {
+		if _, err := ParseScript(strings.NewReader(bad)); err == nil {
+			t.Errorf("expected error for %q\n", bad)
+		}
+	}
+}
+
+func TestRunHeadless(t *testing.T) {
+	// Copy port 1 into VRAM row 200, which the beam scans late in the frame:
+	// IN 1 / STA 0x3D00 / JMP 0x0000
+	m := newTestMachine(t, []byte{0xDB, 0x01, 0x32, 0x00, 0x3D, 0xC3, 0x00, 0x00})
+
+	var captured []uint64
+	var pixels []uint32
+	err := m.RunHeadless(HeadlessOptions{
+		Frames:      5,
+		Script:      Script{{2, Inputs["coin"], true}, {4, Inputs["coin"], false}},
+		Capture:     []uint64{1, 3},
+		CaptureLast: true,
+	}, func(frame uint64, fb *display.Framebuffer) error {
+		captured = append(captured, frame)
+		// Port 1 bit 0 lands on the bottom pixel of column 200
+		pixels = append(pixels, fb.At(200, ScreenHeight-1))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("RunHeadless failed: %v\n", err)
+	}
+	if len(captured) != 3 || captured[0] != 1 || captured[1] != 3 || captured[2] != 5 {
+		t.Fatalf("expected frames [1 3 5] captured, got %v\n", captured)
+	}
+	if pixels[0] != black || pixels[1] != white || pixels[2] != black {
+		t.Fatalf("expected the coin press to show only in frame 3, got %08x\n", pixels)
+	}
+}
@@ -0,0 +1,48 @@
+package machine
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Input is a cabinet input bit read through IN port
+type Input struct {
+	Port uint8
+	Bit  uint8
+}
+
+// Inputs names the cabinet controls, for scripts and key maps
+var Inputs = map[string]Input{
+	"coin":    {1, 0},
+	"p1start": {1, 2},
+	"p2start": {1, 1},
+	"p1fire":  {1, 4},
+	"p1left":  {1, 5},
+	"p1right": {1, 6},
+	"tilt":    {2, 2},
+	"p2fire":  {2, 4},
+	"p2left":  {2, 5},
+	"p2right": {2, 6},
+}
+
+// ParseInput looks up a named input, or parses a raw one given as port.bit
+func ParseInput(s string) (Input, error) {
+	if in, ok := Inputs[s]; ok {
+		return in, nil
+	}
+	var in Input
+	if n, err := fmt.Sscanf(s, "%d.%d", &in.Port, &in.Bit); err != nil || n != 2 || in.Bit > 7 {
+		return Input{}, fmt.Errorf("unknown input %q (want one of %v or port.bit)", s, InputNames())
+	}
+	return in, nil
+}
+
+// InputNames returns the named inputs in alphabetical order
+func InputNames() []string {
+	names := make([]string, 0, len(Inputs))
+	for name := range Inputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,208 @@
+// Package machine wires the 8080 to the rest of the Space Invaders board and
+// runs it a video frame at a time.
+package machine
+
+import (
+	"intel8080/display"
+	"intel8080/intel8080"
+)
+
+const (
+	// DefaultClockHz is the Invaders CPU clock (19.968 MHz crystal / 10)
+	DefaultClockHz = 1996800
+
+	pixelClockHz = 4992000 // 19.968 MHz crystal / 4
+	hTotal       = 320     // pixel periods per scanline
+	vTotal       = 262     // scanlines per frame
+
+	// RefreshRate is the Invaders video refresh rate (~59.54 Hz)
+	RefreshRate = float64(pixelClockHz) / (hTotal * vTotal)
+
+	// DefaultWatchdogFrames is the watchdog timeout: a counter clocked by
+	// vertical blank resets the CPU when it reaches 255 (about 4.3 seconds)
+	DefaultWatchdogFrames = 255
+)
+
+type Machine struct {
+	CPU       *intel8080.CPU
+	Memory    *intel8080.Memory
+	IOBus     *intel8080.IOBus
+	Scheduler *intel8080.Scheduler
+
+	// CPU clock in Hz. The video refresh rate is fixed, so this sets how many
+	// cycles run per frame.
+	ClockHz uint64
+
+	// Frames completed since the machine was created
+	Frame uint64
+
+	// Orientation turns the raw picture to face the player
+	Orientation display.Orientation
+
+	// WatchdogFrames is how many frames the game may run without writing to
+	// port 6 before the CPU is reset (0 disables the watchdog).
+	// WatchdogResets counts the resets.
+	WatchdogFrames uint64
+	WatchdogResets uint64
+	// Frames since the watchdog was last kicked
+	watchdog uint64
+
+	// OnFrame, if set, is called at the end of every frame. An error stops
+	// RunFrame, as a CPU fault does.
+	OnFrame func() error
+
+	// CPU cycle at which the current frame started
+	frameStart uint64
+
+	// VRAM as scanned out by the beam
+	screen []byte
+
+	// Attached sound board, the samples it has generated and the audio of
+	// the last frame
+	sound       SoundBoard
+	sampleRate  uint64
+	soundFrames uint64
+	samples     uint64
+	audio       []int16
+	// muted is set while RunFrameMuted runs a frame the board already heard
+	muted bool
+}
+
+func New(cpu *intel8080.CPU, memory *intel8080.Memory, ioBus *intel8080.IOBus) *Machine {
+	m := &Machine{
+		CPU:            cpu,
+		Memory:         memory,
+		IOBus:          ioBus,
+		Scheduler:      intel8080.NewScheduler(cpu),
+		ClockHz:        DefaultClockHz,
+		Orientation:    DefaultOrientation,
+		WatchdogFrames: DefaultWatchdogFrames,
+		frameStart:     cpu.Cycles,
+		screen:         make([]byte, vramRows*vramRowBytes),
+	}
+	ioBus.OnWatchdog = func() { m.watchdog = 0 }
+	m.scheduleLine(0, m.frameStart)
+	return m
+}
+
+// CyclesPerFrame is the number of CPU cycles in one video frame at the current clock
+func (m *Machine) CyclesPerFrame() uint64 {
+	return m.ClockHz * hTotal * vTotal / pixelClockHz
+}
+
+// RunFrame runs the scheduler to the end of the current video frame
+func (m *Machine) RunFrame() error {
+	frameEnd := m.frameStart + m.CyclesPerFrame()
+	if err := m.Scheduler.RunUntil(frameEnd); err != nil {
+		return err
+	}
+	m.frameStart = frameEnd
+	m.Frame++
+	if m.sound != nil && !m.muted {
+		m.generateAudio()
+	}
+	if m.WatchdogFrames > 0 {
+		m.watchdog++
+		if m.watchdog >= m.WatchdogFrames {
+			m.watchdog = 0
+			m.WatchdogResets++
+			m.reset()
+		}
+	}
+	if m.OnFrame != nil {
+		return m.OnFrame()
+	}
+	return nil
+}
+
+// reset is the watchdog's hardware reset at the end of a frame: the CPU, the
+// shift register and the sound latches start over, and interrupts already
+// scheduled are dropped for a fresh frame of video events
+func (m *Machine) reset() {
+	m.CPU.Reset()
+	m.IOBus.Reset()
+	m.Scheduler.Clear()
+	m.scheduleLine(0, m.frameStart)
+	if !m.muted {
+		// Sounds playing stop with their latches
+		m.SyncSound()
+	}
+}
+
+// SetSound attaches board to the sound ports. From the next frame on it
+// generates sampleRate samples per second of emulated time, which Audio
+// returns frame by frame. A nil board detaches the current one.
+func (m *Machine) SetSound(board SoundBoard, sampleRate int) {
+	m.sound, m.sampleRate = board, uint64(sampleRate)
+	m.soundFrames, m.samples, m.audio = 0, 0, nil
+	if board == nil {
+		m.IOBus.OnSoundWrite = nil
+		return
+	}
+	m.IOBus.OnSoundWrite = func(port, value byte) {
+		if !m.muted {
+			board.Write(m.samplePos(), port, value)
+		}
+	}
+}
+
+// RunFrameMuted runs a frame again after Restore, as a rollback does. The
+// sound board has played the frame once already, so it hears none of its
+// writes and no audio is generated; the sound clock stays where it was.
+// Call SyncSound after the last one.
+func (m *Machine) RunFrameMuted() error {
+	m.muted = true
+	defer func() { m.muted = false }()
+	return m.RunFrame()
+}
+
+// SyncSound writes the sound latches to the board, so sounds started or
+// stopped in frames that were run again muted follow the machine's state
+func (m *Machine) SyncSound() {
+	if m.sound == nil {
+		return
+	}
+	port3, port5 := m.IOBus.SoundLatches()
+	at := m.samplePos()
+	m.sound.Write(at, 3, port3)
+	m.sound.Write(at, 5, port5)
+}
+
+// Sound returns the attached sound board, or nil
+func (m *Machine) Sound() SoundBoard {
+	return m.sound
+}
+
+// Audio returns the samples generated for the last frame, or nil without a
+// sound board. They are only valid until the next frame.
+func (m *Machine) Audio() []int16 {
+	return m.audio
+}
+
+// samplesBefore is the number of samples in the first frames frames at rate
+func samplesBefore(frames, rate uint64) uint64 {
+	return frames * rate * hTotal * vTotal / pixelClockHz
+}
+
+// samplePos is the output sample the beam is at now
+func (m *Machine) samplePos() uint64 {
+	n := samplesBefore(m.soundFrames+1, m.sampleRate) - m.samples
+	return m.samples + (m.CPU.Cycles-m.frameStart)*n/m.CyclesPerFrame()
+}
+
+func (m *Machine) generateAudio() {
+	m.soundFrames++
+	due := samplesBefore(m.soundFrames, m.sampleRate)
+	n := int(due - m.samples)
+	m.samples = due
+	if cap(m.audio) < n {
+		m.audio = make([]int16, n)
+	}
+	m.audio = m.audio[:n]
+	m.sound.Generate(m.audio)
+}
+
+// GetVram returns the live video RAM
+func (m *Machine) GetVram() []byte {
+	return m.CPU.GetVram()
+}
@@ -0,0 +1,126 @@
+package machine
+
+import (
+	"testing"
+
+	"intel8080/intel8080"
+)
+
+// newTestMachine loads program at 0x0000 of a 16K Invaders memory map
+func newTestMachine(t *testing.T, program []byte) *Machine {
+	memory := intel8080.NewMemory(0x4000)
+	for i, b := range program {
+		if err := memory.Write(uint16(i), b); err != nil {
+			t.Fatalf("loading program failed: %v\n", err)
+		}
+	}
+	ioBus := intel8080.NewIOBus()
+	return New(intel8080.NewCPU(ioBus, memory), memory, ioBus)
+}
+
+func TestRunFrame(t *testing.T) {
+	// JMP 0x0000 (10 cycles per instruction)
+	m := newTestMachine(t, []byte{0xC3, 0x00, 0x00})
+
+	for i := 0; i < 3; i++ {
+		if err := m.RunFrame(); err != nil {
+			t.Fatalf("RunFrame failed: %v\n", err)
+		}
+	}
+	if m.Frame != 3 {
+		t.Errorf("expected 3 frames, got %d\n", m.Frame)
+	}
+	want := 3 * m.CyclesPerFrame()
+	if m.CPU.Cycles < want || m.CPU.Cycles >= want+10 {
+		t.Errorf("expected %d cycles (+ < 1 instruction), got %d\n", want, m.CPU.Cycles)
+	}
+
+	m.ClockHz = DefaultClockHz * 2
+	if m.CyclesPerFrame() != 2*33536 {
+		t.Errorf("expected cycles per frame to follow the clock, got %d\n", m.CyclesPerFrame())
+	}
+}
+
+func TestWatchdog(t *testing.T) {
+	// Counts its starts at 0x2000, then spins without kicking the watchdog
+	m := newTestMachine(t, []byte{
+		0x3A, 0x00, 0x20, // LDA 0x2000
+		0x3C,             // INR A
+		0x32, 0x00, 0x20, // STA 0x2000
+		0xC3, 0x07, 0x00, // JMP 0x0007
+	})
+	for i := 0; i < DefaultWatchdogFrames; i++ {
+		if err := m.RunFrame(); err != nil {
+			t.Fatalf("RunFrame failed: %v\n", err)
+		}
+	}
+	if m.WatchdogResets != 1 {
+		t.Fatalf("expected a watchdog reset after %d frames, got %d\n", DefaultWatchdogFrames, m.WatchdogResets)
+	}
+	if err := m.RunFrame(); err != nil {
+		t.Fatalf("RunFrame failed: %v\n", err)
+	}
+	if starts := m.Memory.Read(0x2000); starts != 2 {
+		t.Fatalf("expected the program to restart, started %d times\n", starts)
+	}
+
+	// The reset reaches the whole board, not just the CPU
+	m = newTestMachine(t, []byte{
+		0x3E, 0xFF, // MVI A,0xFF
+		0xD3, 0x04, // OUT 4
+		0xD3, 0x04, // OUT 4
+		0xD3, 0x03, // OUT 3
+		0xD3, 0x05, // OUT 5
+		0x3E, 0x03, // MVI A,3
+		0xD3, 0x02, // OUT 2
+		0xC3, 0x0E, 0x00, // JMP 0x000E
+	})
+	board := &latchBoard{}
+	m.SetSound(board, 48000)
+	for i := 0; i < DefaultWatchdogFrames; i++ {
+		if err := m.RunFrame(); err != nil {
+			t.Fatalf("RunFrame failed: %v\n", err)
+		}
+	}
+	io := m.Snapshot().CPU.IO
+	if m.WatchdogResets != 1 || m.CPU.PC != 0 {
+		t.Fatalf("expected the CPU reset, got PC %04x after %d resets\n", m.CPU.PC, m.WatchdogResets)
+	}
+	if io.ShiftH != 0 || io.ShiftL != 0 || io.ShiftOffset != 0 || io.Port3 != 0 || io.Port5 != 0 {
+		t.Fatalf("expected the shift register and sound latches cleared, got %+v\n", io)
+	}
+	if board.latches != [2]byte{} {
+		t.Fatalf("expected the sound board to hear the latches clear, got %v\n", board.latches)
+	}
+	if m.Scheduler.Pending() != 1 {
+		t.Fatalf("expected only the next frame's first line scheduled, got %d events\n", m.Scheduler.Pending())
+	}
+
+	// OUT 6 / JMP 0x0000 keeps it happy
+	m = newTestMachine(t, []byte{0xD3, 0x06, 0xC3, 0x00, 0x00})
+	for i := 0; i < 2*DefaultWatchdogFrames; i++ {
+		_ = m.RunFrame()
+	}
+	if m.WatchdogResets != 0 {
+		t.Fatalf("expected no resets while the watchdog is kicked, got %d\n", m.WatchdogResets)
+	}
+}
+
+// latchBoard is a silent sound board that keeps the last values written
+type latchBoard struct {
+	latches [2]byte
+}
+
+func (b *latchBoard) Generate(buf []int16) {
+	for i := range buf {
+		buf[i] = 0
+	}
+}
+
+func (b *latchBoard) Write(at uint64, port, value byte) {
+	if port == 3 {
+		b.latches[0] = value
+	} else {
+		b.latches[1] = value
+	}
+}
@@ -0,0 +1,227 @@
+package machine
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"intel8080/intel8080"
+)
+
+// Movie is a recorded run: the state it starts from, every input change with
+// the frame it was latched before, and the state hash after each frame, so a
+// replay notices the moment it stops matching. It is stored as JSON, with
+// Start holding a binary State (base64).
+type Movie struct {
+	Version int    `json:"version"`
+	RomSHA1 string `json:"rom_sha1"`
+	// Roms are the ROM files the movie was recorded with, for reference
+	Roms   []intel8080.RomInfo `json:"roms,omitempty"`
+	Start  []byte              `json:"start"`
+	Events []MovieEvent        `json:"events"`
+	// Hashes holds the state hash after each frame
+	Hashes []uint64 `json:"hashes"`
+	// Rerecords counts the times the movie was rewound and changed while
+	// it was made (see package tas)
+	Rerecords uint64 `json:"rerecords,omitempty"`
+}
+
+// MovieEvent is an input change, applied after Frame frames of the movie
+// have run (0 is before the first)
+type MovieEvent struct {
+	Frame   uint64 `json:"frame"`
+	Port    uint8  `json:"port"`
+	Bit     uint8  `json:"bit"`
+	Pressed bool   `json:"pressed"`
+}
+
+// MovieVersion is the version of the movie format written
+const MovieVersion = 1
+
+// Frames returns the length of the movie in frames
+func (mv *Movie) Frames() uint64 {
+	return uint64(len(mv.Hashes))
+}
+
+// StartState decodes the state the movie starts from
+func (mv *Movie) StartState() (*State, error) {
+	s := &State{}
+	if err := s.UnmarshalBinary(mv.Start); err != nil {
+		return nil, fmt.Errorf("movie: %v", err)
+	}
+	return s, nil
+}
+
+// Script returns the movie's input changes as a script, with frames counted
+// from the start of the movie
+func (mv *Movie) Script() Script {
+	script := make(Script, len(mv.Events))
+	for i, e := range mv.Events {
+		script[i] = ScriptEvent{Frame: e.Frame, Input: Input{Port: e.Port, Bit: e.Bit}, Pressed: e.Pressed}
+	}
+	return script
+}
+
+func WriteMovie(filename string, mv *Movie) error {
+	data, err := json.Marshal(mv)
+	if err != nil {
+		return fmt.Errorf("writeMovie: %v", err)
+	}
+	if err := os.WriteFile(filename, data, 0o644); err != nil {
+		return fmt.Errorf("writeMovie: %v", err)
+	}
+	return nil
+}
+
+func ReadMovie(filename string) (*Movie, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("readMovie: failed reading file: %v", err)
+	}
+	mv := Movie{}
+	if err := json.Unmarshal(data, &mv); err != nil {
+		return nil, fmt.Errorf("readMovie: %s: %v", filename, err)
+	}
+	if mv.Version != MovieVersion {
+		return nil, fmt.Errorf("readMovie: %s: unsupported version %d", filename, mv.Version)
+	}
+	return &mv, nil
+}
+
+// MovieRecorder records a movie from the machine's inputs
+type MovieRecorder struct {
+	m     *Machine
+	movie *Movie
+	start uint64
+
+	onInput func(port, bit uint8, pressed bool)
+	onFrame func() error
+}
+
+// RecordMovie starts recording a movie from the current state. Call it
+// between frames.
+func (m *Machine) RecordMovie() (*MovieRecorder, error) {
+	start, err := m.Snapshot().MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	r := &MovieRecorder{
+		m: m,
+		movie: &Movie{
+			Version: MovieVersion,
+			RomSHA1: m.RomHash(),
+			Roms:    m.Memory.LoadedRoms(),
+			Start:   start,
+		},
+		start:   m.Frame,
+		onInput: m.IOBus.OnInput,
+		onFrame: m.OnFrame,
+	}
+	m.IOBus.OnInput = func(port, bit uint8, pressed bool) {
+		if r.onInput != nil {
+			r.onInput(port, bit, pressed)
+		}
+		r.movie.Events = append(r.movie.Events, MovieEvent{Frame: m.Frame - r.start, Port: port, Bit: bit, Pressed: pressed})
+	}
+	m.OnFrame = func() error {
+		if r.onFrame != nil {
+			if err := r.onFrame(); err != nil {
+				return err
+			}
+		}
+		r.movie.Hashes = append(r.movie.Hashes, m.Snapshot().Hash())
+		return nil
+	}
+	return r, nil
+}
+
+// Stop ends the recording and returns the movie. Input changes after the
+// last frame are dropped, as they never took effect.
+func (r *MovieRecorder) Stop() *Movie {
+	r.m.IOBus.OnInput, r.m.OnFrame = r.onInput, r.onFrame
+	events := r.movie.Events
+	for len(events) > 0 && events[len(events)-1].Frame >= r.movie.Frames() {
+		events = events[:len(events)-1]
+	}
+	r.movie.Events = events
+	return r.movie
+}
+
+// DesyncError reports a replay whose state stopped matching the movie
+type DesyncError struct {
+	Frame     uint64
+	Want, Got uint64
+}
+
+func (e *DesyncError) Error() string {
+	return fmt.Sprintf("movie desync after frame %d: state hash %016x, expected %016x", e.Frame, e.Got, e.Want)
+}
+
+// MoviePlayer replays a movie, checking the state after every frame
+type MoviePlayer struct {
+	m      *Machine
+	movie  *Movie
+	script Script
+	start  uint64
+
+	onFrame func() error
+}
+
+// PlayMovie restores the movie's starting state and replays its inputs as
+// frames run. RunFrame returns a *DesyncError as soon as the state differs
+// from the recording. The loaded ROM must match the movie's.
+func (m *Machine) PlayMovie(mv *Movie) (*MoviePlayer, error) {
+	if hash := m.RomHash(); hash != mv.RomSHA1 {
+		return nil, fmt.Errorf("movie was recorded with ROM %s, loaded ROM is %s", mv.RomSHA1, hash)
+	}
+	start, err := mv.StartState()
+	if err != nil {
+		return nil, err
+	}
+	if err := m.Restore(start); err != nil {
+		return nil, err
+	}
+	p := &MoviePlayer{m: m, movie: mv, script: mv.Script(), start: m.Frame, onFrame: m.OnFrame}
+	m.OnFrame = func() error {
+		if p.onFrame != nil {
+			if err := p.onFrame(); err != nil {
+				return err
+			}
+		}
+		return p.frame()
+	}
+	p.apply()
+	return p, nil
+}
+
+// frame checks the frame just run and applies the inputs for the next
+func (p *MoviePlayer) frame() error {
+	n := p.m.Frame - p.start
+	if n > p.movie.Frames() {
+		return nil
+	}
+	if got, want := p.m.Snapshot().Hash(), p.movie.Hashes[n-1]; got != want {
+		return &DesyncError{Frame: n, Want: want, Got: got}
+	}
+	p.apply()
+	return nil
+}
+
+func (p *MoviePlayer) apply() {
+	n := p.m.Frame - p.start
+	for len(p.script) > 0 && p.script[0].Frame <= n {
+		e := p.script[0]
+		p.m.IOBus.HandleInput(e.Input.Port, e.Input.Bit, e.Pressed)
+		p.script = p.script[1:]
+	}
+}
+
+// Done reports whether every frame of the movie has run
+func (p *MoviePlayer) Done() bool {
+	return p.m.Frame-p.start >= p.movie.Frames()
+}
+
+// Stop detaches the player; the machine keeps running from where it is
+func (p *MoviePlayer) Stop() {
+	p.m.OnFrame = p.onFrame
+}
@@ -0,0 +1,171 @@
+package machine
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// inputProgram adds port 1 to a running total at 0x2000 and shows it in VRAM,
+// so the state depends on the frame every input change lands on
+var inputProgram = []byte{
+	0xDB, 0x01, // IN 1
+	0x47,             // MOV B,A
+	0x3A, 0x00, 0x20, // LDA 0x2000
+	0x80,             // ADD B
+	0x32, 0x00, 0x20, // STA 0x2000
+	0x32, 0x00, 0x24, // STA 0x2400
+	0xC3, 0x00, 0x00, // JMP 0x0000
+}
+
+// recordInputMovie runs inputProgram for 30 frames, pressing fire and coin
+func recordInputMovie(t *testing.T) (*Movie, *State) {
+	m := newTestMachine(t, inputProgram)
+	if err := m.RunFrame(); err != nil {
+		t.Fatalf("RunFrame failed: %v\n", err)
+	}
+	r, err := m.RecordMovie()
+	if err != nil {
+		t.Fatalf("RecordMovie failed: %v\n", err)
+	}
+	for i := 0; i < 30; i++ {
+		switch i {
+		case 0, 20:
+			m.IOBus.HandleInput(1, 4, true)
+		case 5, 25:
+			m.IOBus.HandleInput(1, 4, false)
+		case 10:
+			m.IOBus.HandleInput(1, 0, true)
+			m.IOBus.HandleInput(1, 0, false)
+		}
+		if err := m.RunFrame(); err != nil {
+			t.Fatalf("RunFrame failed: %v\n", err)
+		}
+	}
+	// Never takes effect
+	m.IOBus.HandleInput(1, 2, true)
+	return r.Stop(), m.Snapshot()
+}
+
+func TestMovieRecordAndPlay(t *testing.T) {
+	movie, end := recordInputMovie(t)
+	if movie.Frames() != 30 {
+		t.Fatalf("expected 30 frames, got %d\n", movie.Frames())
+	}
+	want := []MovieEvent{{0, 1, 4, true}, {5, 1, 4, false}, {10, 1, 0, true}, {10, 1, 0, false}, {20, 1, 4, true}, {25, 1, 4, false}}
+	if !reflect.DeepEqual(movie.Events, want) {
+		t.Fatalf("expected events %v, got %v\n", want, movie.Events)
+	}
+
+	// Replay on a machine in a different state
+	m := newTestMachine(t, inputProgram)
+	for i := 0; i < 7; i++ {
+		_ = m.RunFrame()
+	}
+	p, err := m.PlayMovie(movie)
+	if err != nil {
+		t.Fatalf("PlayMovie failed: %v\n", err)
+	}
+	for !p.Done() {
+		if err := m.RunFrame(); err != nil {
+			t.Fatalf("RunFrame failed: %v\n", err)
+		}
+	}
+	p.Stop()
+	end.CPU.IO.Input1 = 0 // the press after the last frame
+	if got := m.Snapshot(); got.Hash() != end.Hash() {
+		t.Fatalf("replay ended in a different state:\n%s\n%s\n", got.CPU, end.CPU)
+	}
+}
+
+func TestMovieDesync(t *testing.T) {
+	movie, _ := recordInputMovie(t)
+	// Fire one frame late
+	movie.Events[0].Frame = 1
+	m := newTestMachine(t, inputProgram)
+	if _, err := m.PlayMovie(movie); err != nil {
+		t.Fatalf("PlayMovie failed: %v\n", err)
+	}
+	var err error
+	for i := 0; i < 30 && err == nil; i++ {
+		err = m.RunFrame()
+	}
+	var desync *DesyncError
+	if !errors.As(err, &desync) || desync.Frame != 1 {
+		t.Fatalf("expected a desync after frame 1, got %v\n", err)
+	}
+
+	other := newTestMachine(t, []byte{0xC3, 0x00, 0x00})
+	if _, err := other.PlayMovie(movie); err == nil {
+		t.Fatalf("expected an error for a different ROM\n")
+	}
+}
+
+func TestStateRoundTrip(t *testing.T) {
+	m := newTestMachine(t, inputProgram)
+	m.IOBus.HandleInput(1, 4, true)
+	for i := 0; i < 3; i++ {
+		_ = m.RunFrame()
+	}
+	s := m.Snapshot()
+	data, err := s.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary failed: %v\n", err)
+	}
+	got := &State{}
+	if err := got.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary failed: %v\n", err)
+	}
+	if !reflect.DeepEqual(got, s) {
+		t.Fatalf("round trip mismatch\n")
+	}
+	if err := got.UnmarshalBinary(data[:40]); err == nil {
+		t.Errorf("expected error on truncated state\n")
+	}
+	path := filepath.Join(t.TempDir(), "test.state")
+	if err := WriteStateFile(path, s); err != nil {
+		t.Fatalf("WriteStateFile failed: %v\n", err)
+	}
+	if got, err := ReadStateFile(path); err != nil || !reflect.DeepEqual(got, s) {
+		t.Fatalf("state file round trip failed: %v\n", err)
+	}
+
+	// Running on from a restored state repeats the run
+	_ = m.RunFrame()
+	want := m.Snapshot().Hash()
+	if err := m.Restore(s); err != nil {
+		t.Fatalf("Restore failed: %v\n", err)
+	}
+	_ = m.RunFrame()
+	if m.Frame != 4 || m.Snapshot().Hash() != want {
+		t.Fatalf("expected the same state after restoring, frame %d\n", m.Frame)
+	}
+}
+
+// TestMovieGolden replays testdata/input.movie, so changes to emulation
+// timing show up as a desync. Run with -update to re-record it after an
+// intended change.
+func TestMovieGolden(t *testing.T) {
+	golden := filepath.Join("testdata", "input.movie")
+	if *update {
+		movie, _ := recordInputMovie(t)
+		if err := WriteMovie(golden, movie); err != nil {
+			t.Fatalf("WriteMovie failed: %v\n", err)
+		}
+	}
+	movie, err := ReadMovie(golden)
+	if err != nil {
+		t.Fatalf("ReadMovie failed: %v\n", err)
+	}
+	m := newTestMachine(t, inputProgram)
+	p, err := m.PlayMovie(movie)
+	if err != nil {
+		t.Fatalf("PlayMovie failed: %v\n", err)
+	}
+	for !p.Done() {
+		if err := m.RunFrame(); err != nil {
+			t.Fatalf("RunFrame failed: %v (run with -update if intended)\n", err)
+		}
+	}
+}
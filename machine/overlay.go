@@ -0,0 +1,88 @@
+package machine
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"intel8080/display"
+)
+
+const (
+	overlayRed   = 0xffff2020
+	overlayGreen = 0xff20ff20
+)
+
+// OverlayPresets are built-in cellophane layouts for the Invaders screen in
+// its usual orientation (224x256); LoadOverlay turns them to the machine's.
+// Other overlays, such as the Taito variants, are loaded from PNG.
+var OverlayPresets = map[string]func(o *display.Overlay){
+	// No overlay: white phosphor
+	"none": func(o *display.Overlay) {},
+	// Red band over the UFO and green band over the player, as this emulator
+	// has always drawn them
+	"bands": func(o *display.Overlay) {
+		o.AddBox(0, 32, o.Width, 64, 0xffff0000)
+		o.AddBox(0, 192, o.Width, 208, 0xff00ff00)
+	},
+	// The Midway upright: red over the UFO, green from the shields down,
+	// leaving the credit count at the bottom right white
+	"midway": func(o *display.Overlay) {
+		o.AddBox(0, 32, o.Width, 64, overlayRed)
+		o.AddBox(0, 184, o.Width, 240, overlayGreen)
+		o.AddBox(24, 240, 136, o.Height, overlayGreen)
+	},
+}
+
+// DefaultOverlay is used when no overlay is configured
+const DefaultOverlay = "bands"
+
+// OverlayNames returns the preset names in alphabetical order
+func OverlayNames() []string {
+	names := make([]string, 0, len(OverlayPresets))
+	for name := range OverlayPresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LoadOverlay builds an overlay for the screen turned to orientation (see
+// Machine.Orientation). tint is a preset name or a PNG file of the turned
+// screen; backdrop is an optional PNG file.
+func LoadOverlay(tint, backdrop string, orientation display.Orientation) (*display.Overlay, error) {
+	o := display.NewOverlay(orientation.Size(vramRowBytes*8, vramRows))
+	if tint == "" {
+		tint = DefaultOverlay
+	}
+	if preset, ok := OverlayPresets[tint]; ok {
+		upright := display.NewOverlay(ScreenWidth, ScreenHeight)
+		preset(upright)
+		turnOverlay(upright, o, orientation)
+	} else if strings.HasSuffix(strings.ToLower(tint), ".png") {
+		if err := o.LoadTint(tint); err != nil {
+			return nil, fmt.Errorf("overlay: %v", err)
+		}
+	} else {
+		return nil, fmt.Errorf("unknown overlay %q (want a .png file or one of %v)", tint, OverlayNames())
+	}
+	if backdrop != "" {
+		if err := o.LoadBackdrop(backdrop); err != nil {
+			return nil, fmt.Errorf("backdrop: %v", err)
+		}
+	}
+	return o, nil
+}
+
+// turnOverlay copies upright, drawn in DefaultOrientation, to o in
+// orientation, going through the raw picture's pixels
+func turnOverlay(upright, o *display.Overlay, orientation display.Orientation) {
+	w, h := vramRowBytes*8, vramRows
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			ux, uy := DefaultOrientation.Map(x, y, w, h)
+			ox, oy := orientation.Map(x, y, w, h)
+			o.Tint[oy*o.Width+ox] = upright.Tint[uy*upright.Width+ux]
+		}
+	}
+}
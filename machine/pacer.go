@@ -0,0 +1,88 @@
+package machine
+
+import (
+	"time"
+)
+
+// maxLag is how far behind the pacer may fall (e.g. after the host stalls)
+// before it gives up catching up and starts pacing from the current time
+const maxLag = 4
+
+// Pacer holds the emulation to a target frame rate against the wall clock.
+// Deadlines are measured from a fixed base time rather than from the end of
+// the previous frame, so sleep overshoot doesn't accumulate into drift.
+type Pacer struct {
+	frameDuration time.Duration
+	speed         float64
+
+	base   time.Time
+	frames uint64
+	// shown is when Show last let a frame through
+	shown time.Time
+
+	now   func() time.Time
+	sleep func(time.Duration)
+}
+
+func NewPacer(frameRate float64) *Pacer {
+	p := &Pacer{
+		frameDuration: time.Duration(float64(time.Second) / frameRate),
+		speed:         1,
+		now:           time.Now,
+		sleep:         time.Sleep,
+	}
+	p.Reset()
+	return p
+}
+
+// Reset starts pacing from the current time
+func (p *Pacer) Reset() {
+	p.base = p.now()
+	p.frames = 0
+}
+
+// SetSpeed sets the emulation speed as a multiple of real time: 2 is double
+// speed, 0.5 is slow motion and 0 runs uncapped
+func (p *Pacer) SetSpeed(speed float64) {
+	if speed < 0 {
+		speed = 0
+	}
+	p.speed = speed
+	p.Reset()
+}
+
+func (p *Pacer) Speed() float64 {
+	return p.speed
+}
+
+// Wait blocks until the deadline for the frame just emulated
+func (p *Pacer) Wait() {
+	p.frames++
+	if p.speed == 0 {
+		return
+	}
+	period := time.Duration(float64(p.frameDuration) / p.speed)
+	deadline := p.base.Add(time.Duration(p.frames) * period)
+
+	now := p.now()
+	if lag := now.Sub(deadline); lag > maxLag*period {
+		p.Reset()
+		return
+	}
+	if wait := deadline.Sub(now); wait > 0 {
+		p.sleep(wait)
+	}
+}
+
+// Show reports whether the frame just emulated should be presented. At
+// normal speed every frame is; otherwise at most one per refresh of the wall
+// clock, since a display synced to vertical blank would hold fast-forward to
+// its refresh rate.
+func (p *Pacer) Show() bool {
+	now := p.now()
+	if p.speed != 1 && now.Sub(p.shown) < p.frameDuration {
+		return false
+	}
+	p.shown = now
+	return true
+}
@@ -0,0 +1,99 @@
+package machine
+
+import (
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	t     time.Time
+	slept []time.Duration
+}
+
+func (c *fakeClock) now() time.Time { return c.t }
+
+func (c *fakeClock) sleep(d time.Duration) {
+	c.slept = append(c.slept, d)
+	c.t = c.t.Add(d)
+}
+
+func newFakePacer(frameRate float64) (*Pacer, *fakeClock) {
+	clock := &fakeClock{t: time.Unix(0, 0)}
+	p := NewPacer(frameRate)
+	p.now = clock.now
+	p.sleep = clock.sleep
+	p.Reset()
+	return p, clock
+}
+
+func TestPacerCorrectsDrift(t *testing.T) {
+	p, clock := newFakePacer(50) // 20ms frames
+
+	// Frame emulation takes 5ms, then sleep overshoots by 3ms
+	clock.t = clock.t.Add(5 * time.Millisecond)
+	p.Wait()
+	clock.t = clock.t.Add(3 * time.Millisecond)
+
+	clock.t = clock.t.Add(5 * time.Millisecond)
+	p.Wait()
+
+	want := []time.Duration{15 * time.Millisecond, 12 * time.Millisecond}
+	if len(clock.slept) != 2 || clock.slept[0] != want[0] || clock.slept[1] != want[1] {
+		t.Fatalf("expected sleeps %v, got %v\n", want, clock.slept)
+	}
+}
+
+func TestPacerSpeed(t *testing.T) {
+	p, clock := newFakePacer(50)
+
+	p.SetSpeed(2)
+	p.Wait()
+	if len(clock.slept) != 1 || clock.slept[0] != 10*time.Millisecond {
+		t.Fatalf("expected 10ms sleep at 2x, got %v\n", clock.slept)
+	}
+
+	p.SetSpeed(0)
+	p.Wait()
+	if len(clock.slept) != 1 {
+		t.Fatalf("expected no sleep when uncapped, got %v\n", clock.slept)
+	}
+}
+
+func TestPacerResyncsAfterStall(t *testing.T) {
+	p, clock := newFakePacer(50)
+
+	clock.t = clock.t.Add(time.Second)
+	p.Wait()
+	p.Wait()
+	if len(clock.slept) != 1 || clock.slept[0] != 20*time.Millisecond {
+		t.Fatalf("expected pacing to restart after a stall, got %v\n", clock.slept)
+	}
+}
+
+func TestPacerShowWhileFastForwarding(t *testing.T) {
+	p, clock := newFakePacer(50) // 20ms refresh
+	for i := 0; i < 3; i++ {
+		if !p.Show() {
+			t.Fatalf("expected every frame shown at normal speed\n")
+		}
+	}
+
+	// Uncapped, 1ms frames, and presenting waits for the next refresh
+	p.SetSpeed(0)
+	end := clock.t.Add(time.Second)
+	frames, shown := 0, 0
+	for clock.t.Before(end) {
+		clock.t = clock.t.Add(time.Millisecond)
+		if p.Show() {
+			shown++
+			if late := clock.t.Sub(time.Unix(0, 0)) % (20 * time.Millisecond); late > 0 {
+				clock.t = clock.t.Add(20*time.Millisecond - late)
+			}
+		}
+		p.Wait()
+		frames++
+	}
+	if frames < 10*50 || shown > 50 {
+		t.Fatalf("expected fast-forward well past the refresh rate, ran %d frames showing %d\n", frames, shown)
+	}
+}
@@ -0,0 +1,78 @@
+package machine
+
+import (
+	"io"
+
+	"intel8080/audio"
+	"intel8080/display"
+)
+
+// AVRecorder writes every emulated frame to a Y4M stream and the matching
+// audio to a WAV file. The number of samples per frame follows from the
+// emulated refresh rate, not the wall clock, so the tracks stay in sync
+// however fast the emulator runs.
+type AVRecorder struct {
+	video      *display.Y4MWriter
+	wav        *audio.WAVWriter
+	sampleRate uint64
+	frames     uint64
+	samples    uint64
+	buf        []int16
+}
+
+// NewAVRecorder records to video and, if wav is not nil, audio. Either may be
+// nil to record only one track. aspect is the width / height of the picture
+// on screen (see Machine.ScreenAspect).
+func NewAVRecorder(video io.Writer, wav *audio.WAVWriter, sampleRate int, aspect float64) *AVRecorder {
+	r := &AVRecorder{wav: wav, sampleRate: uint64(sampleRate)}
+	if video != nil {
+		// The monitor is 4:3 on its side or not, whatever size filters made the frames
+		darNum, darDen := 4, 3
+		if aspect < 1 {
+			darNum, darDen = 3, 4
+		}
+		r.video = display.NewY4MWriter(video, pixelClockHz, hTotal*vTotal, darNum, darDen)
+	}
+	return r
+}
+
+// AddFrame records one emulated frame and its audio (see Machine.Audio). Nil
+// samples record the frame's share of silence.
+func (r *AVRecorder) AddFrame(fb *display.Framebuffer, samples []int16) error {
+	if r.video != nil {
+		if err := r.video.WriteFrame(fb); err != nil {
+			return err
+		}
+	}
+	r.frames++
+	// Samples due by the end of this frame, from absolute time so rounding
+	// never accumulates
+	due := samplesBefore(r.frames, r.sampleRate)
+	n := int(due - r.samples)
+	r.samples = due
+	if r.wav == nil {
+		return nil
+	}
+	if samples != nil {
+		return r.wav.Write(samples)
+	}
+	if cap(r.buf) < n {
+		r.buf = make([]int16, n)
+	}
+	buf := r.buf[:n]
+	audio.Silence{}.Generate(buf)
+	return r.wav.Write(buf)
+}
+
+// Frames returns the number of frames recorded
+func (r *AVRecorder) Frames() uint64 {
+	return r.frames
+}
+
+// Flush writes out buffered video. The WAV writer is closed by its owner.
+func (r *AVRecorder) Flush() error {
+	if r.video != nil {
+		return r.video.Flush()
+	}
+	return nil
+}
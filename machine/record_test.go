@@ -0,0 +1,67 @@
+package machine
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"intel8080/audio"
+	"intel8080/display"
+)
+
+// rampSource counts up, so gaps or repeats between frames would show
+type rampSource struct{ next int16 }
+
+func (s *rampSource) Generate(buf []int16) {
+	for i := range buf {
+		buf[i] = s.next
+		s.next++
+	}
+}
+
+func TestAVRecorder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "out.wav")
+	wav, err := audio.CreateWAV(path, audio.DefaultSampleRate, 1)
+	if err != nil {
+		t.Fatalf("CreateWAV failed: %v\n", err)
+	}
+	var video bytes.Buffer
+	r := NewAVRecorder(&video, wav.WAVWriter, audio.DefaultSampleRate, 3.0/4)
+	ramp := &rampSource{}
+
+	fb := display.NewFramebuffer(ScreenWidth, ScreenHeight)
+	for i := uint64(0); i < 100; i++ {
+		samples := make([]int16, samplesBefore(i+1, audio.DefaultSampleRate)-samplesBefore(i, audio.DefaultSampleRate))
+		ramp.Generate(samples)
+		if err := r.AddFrame(fb, samples); err != nil {
+			t.Fatalf("AddFrame failed: %v\n", err)
+		}
+	}
+	_ = r.Flush()
+	_ = wav.Close()
+
+	// 100 frames at 4992000/83840 Hz is 80615.38 samples at 48 kHz
+	if wav.Samples() != 80615 {
+		t.Fatalf("expected 80615 samples, got %d\n", wav.Samples())
+	}
+	f, _ := os.Open(path)
+	defer f.Close()
+	s, err := audio.ReadWAV(f)
+	if err != nil {
+		t.Fatalf("ReadWAV failed: %v\n", err)
+	}
+	for i, v := range s.Samples {
+		if v != int16(i) {
+			t.Fatalf("sample %d: expected %d, got %d\n", i, int16(i), v)
+		}
+	}
+
+	header := "YUV4MPEG2 W224 H256 F7800:131 Ip A6:7 C444 XCOLORRANGE=FULL\n"
+	if want := len(header) + 100*(len("FRAME\n")+3*ScreenWidth*ScreenHeight); video.Len() != want {
+		t.Fatalf("expected %d bytes of video, got %d\n", want, video.Len())
+	}
+	if !bytes.HasPrefix(video.Bytes(), []byte(header)) {
+		t.Fatalf("unexpected header %q\n", video.Bytes()[:len(header)])
+	}
+}
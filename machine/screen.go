@@ -0,0 +1,67 @@
+package machine
+
+import "intel8080/display"
+
+// The monitor is mounted rotated 90 degrees counter-clockwise, so the picture
+// the player sees is 224 pixels wide and 256 tall
+const (
+	ScreenWidth  = vramRows
+	ScreenHeight = vramRowBytes * 8
+)
+
+// DefaultOrientation turns the raw picture (scanlines running left to right,
+// 256x224) the way the Invaders monitor is mounted
+var DefaultOrientation = display.Orientation{Rotate: 270}
+
+const (
+	black = 0xff000000
+	white = 0xffffffff
+)
+
+// ScreenSize returns the size of the picture in the machine's orientation
+func (m *Machine) ScreenSize() (int, int) {
+	return m.Orientation.Size(vramRowBytes*8, vramRows)
+}
+
+// ScreenAspect returns the width / height of the picture on the 4:3 monitor
+// in the machine's orientation. The pixels are not square.
+func (m *Machine) ScreenAspect() float64 {
+	if w, h := m.ScreenSize(); w < h {
+		return 3.0 / 4.0
+	}
+	return 4.0 / 3.0
+}
+
+// DrawScreen converts the last frame into fb (ScreenSize), turned to the
+// machine's orientation and coloured with overlay. On a cocktail cabinet the
+// picture is turned around for player 2 while the overlay, being on the glass,
+// stays put. overlay may be nil for white on black.
+func (m *Machine) DrawScreen(fb *display.Framebuffer, overlay *display.Overlay) error {
+	o := m.Orientation
+	if m.IOBus.FlipScreen() {
+		o = o.Flipped()
+	}
+	DrawVram(fb, m.Screen(), o)
+	if overlay != nil {
+		return overlay.Apply(fb)
+	}
+	return nil
+}
+
+// DrawVram converts a raw VRAM image into fb, white on black. Raw pixel x of
+// VRAM row y (each row a scanline of 256 pixels) is placed according to o.
+func DrawVram(fb *display.Framebuffer, vram []byte, o display.Orientation) {
+	const w, h = vramRowBytes * 8, vramRows
+	for i, b := range vram[:vramRows*vramRowBytes] {
+		y := i / vramRowBytes
+		for bit := 0; bit < 8; bit++ {
+			x := (i%vramRowBytes)*8 + bit
+			var color uint32 = black
+			if b&(1<<bit) != 0 {
+				color = white
+			}
+			fx, fy := o.Map(x, y, w, h)
+			fb.Set(fx, fy, color)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package machine
+
+import (
+	"testing"
+
+	"intel8080/display"
+)
+
+func TestDrawVram(t *testing.T) {
+	vram := make([]byte, vramRows*vramRowBytes)
+	vram[0] = 0x01                  // row 0, pixel 0: bottom left
+	vram[10*vramRowBytes+31] = 0x80 // row 10, pixel 255: top
+	vram[100*vramRowBytes+6] = 0x01 // row 100, pixel 48: inside the green band
+
+	overlay, err := LoadOverlay("bands", "", DefaultOrientation)
+	if err != nil {
+		t.Fatalf("LoadOverlay failed: %v\n", err)
+	}
+	fb := display.NewFramebuffer(ScreenWidth, ScreenHeight)
+	DrawVram(fb, vram, DefaultOrientation)
+	if err := overlay.Apply(fb); err != nil {
+		t.Fatalf("Apply failed: %v\n", err)
+	}
+
+	lit := map[[2]int]uint32{
+		{0, 255}:   white,
+		{10, 0}:    white,
+		{100, 207}: 0xff00ff00,
+	}
+	for y := 0; y < ScreenHeight; y++ {
+		for x := 0; x < ScreenWidth; x++ {
+			want, ok := lit[[2]int{x, y}]
+			if !ok {
+				want = black
+			}
+			if got := fb.At(x, y); got != want {
+				t.Fatalf("pixel (%d,%d): expected %08x, got %08x\n", x, y, want, got)
+			}
+		}
+	}
+}
+
+func TestOverlayFollowsOrientation(t *testing.T) {
+	vram := make([]byte, vramRows*vramRowBytes)
+	vram[10*vramRowBytes+31] = 0x80  // row 10, pixel 255: top, untinted
+	vram[100*vramRowBytes+6] = 0x01  // row 100, pixel 48: inside the green band
+	vram[150*vramRowBytes+27] = 0x01 // row 150, pixel 216: inside the red band
+	for _, spec := range []string{"0", "90", "180", "270m"} {
+		orientation, _ := display.ParseOrientation(spec)
+		overlay, err := LoadOverlay("bands", "", orientation)
+		if err != nil {
+			t.Fatalf("LoadOverlay failed: %v\n", err)
+		}
+		fb := display.NewFramebuffer(orientation.Size(ScreenHeight, ScreenWidth))
+		DrawVram(fb, vram, orientation)
+		if err := overlay.Apply(fb); err != nil {
+			t.Fatalf("%s: Apply failed: %v\n", spec, err)
+		}
+		colours := map[uint32]int{}
+		for _, c := range fb.Pix {
+			colours[c]++
+		}
+		if colours[white] != 1 || colours[0xff00ff00] != 1 || colours[0xffff0000] != 1 {
+			t.Fatalf("%s: expected one white, one green and one red pixel, got %v\n", spec, colours)
+		}
+	}
+}
+
+func TestLoadOverlay(t *testing.T) {
+	for _, name := range OverlayNames() {
+		if _, err := LoadOverlay(name, "", DefaultOrientation); err != nil {
+			t.Errorf("preset %s: %v\n", name, err)
+		}
+	}
+	if _, err := LoadOverlay("taito", "", DefaultOrientation); err == nil {
+		t.Errorf("expected error for unknown preset\n")
+	}
+	if _, err := LoadOverlay("missing.png", "", DefaultOrientation); err == nil {
+		t.Errorf("expected error for missing overlay file\n")
+	}
+	if _, err := LoadOverlay("none", "missing.png", DefaultOrientation); err == nil {
+		t.Errorf("expected error for missing backdrop file\n")
+	}
+}
+
+func TestDrawScreenCocktailFlip(t *testing.T) {
+	m := newTestMachine(t, nil)
+	m.IOBus.Cocktail = true
+	// Row 0, pixel 0: bottom left for player 1, top right for player 2
+	m.Screen()[0] = 0x01
+	fb := display.NewFramebuffer(m.ScreenSize())
+
+	if err := m.DrawScreen(fb, nil); err != nil {
+		t.Fatalf("DrawScreen failed: %v\n", err)
+	}
+	if fb.At(0, ScreenHeight-1) != white {
+		t.Fatalf("player 1: expected pixel at the bottom left\n")
+	}
+	m.IOBus.Write(5, 0x20)
+	if err := m.DrawScreen(fb, nil); err != nil {
+		t.Fatalf("DrawScreen failed: %v\n", err)
+	}
+	if fb.At(ScreenWidth-1, 0) != white || fb.At(0, ScreenHeight-1) != black {
+		t.Fatalf("player 2: expected pixel at the top right\n")
+	}
+}
@@ -0,0 +1,217 @@
+package machine
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"intel8080/audio"
+)
+
+// SoundEvent is one of the sounds of the Invaders sound board. The values
+// are the numbers of the MAME samples (0.wav to 8.wav).
+type SoundEvent int
+
+const (
+	// SoundUFO repeats while port 3 bit 0 is set
+	SoundUFO SoundEvent = iota
+	SoundShot
+	SoundPlayerDeath
+	SoundInvaderDeath
+	// SoundFleet1 to SoundFleet4 are the four notes of the marching fleet
+	SoundFleet1
+	SoundFleet2
+	SoundFleet3
+	SoundFleet4
+	SoundUFOHit
+
+	NumSoundEvents
+)
+
+var soundEventNames = [NumSoundEvents]string{
+	"ufo", "shot", "player-death", "invader-death", "fleet1", "fleet2", "fleet3", "fleet4", "ufo-hit",
+}
+
+func (e SoundEvent) String() string {
+	if e >= 0 && e < NumSoundEvents {
+		return soundEventNames[e]
+	}
+	return fmt.Sprintf("SoundEvent(%d)", int(e))
+}
+
+// soundBits is the port and bit that triggers each event
+var soundBits = [NumSoundEvents]struct{ port, bit byte }{
+	{3, 0}, {3, 1}, {3, 2}, {3, 3},
+	{5, 0}, {5, 1}, {5, 2}, {5, 3}, {5, 4},
+}
+
+// soundAmpEnable is port 3 bit 5, which switches the amplifier on. The game
+// keeps it off during the attract mode.
+const soundAmpEnable = 0x20
+
+// SoundChange is a sound event switched on or off
+type SoundChange struct {
+	Event SoundEvent
+	On    bool
+}
+
+// DecodeSound returns the events switched by writing value to port (3 or 5),
+// whose latch held old
+func DecodeSound(port, old, value byte) []SoundChange {
+	var changes []SoundChange
+	for e, in := range soundBits {
+		if in.port != port {
+			continue
+		}
+		mask := byte(1) << in.bit
+		if (old^value)&mask != 0 {
+			changes = append(changes, SoundChange{SoundEvent(e), value&mask != 0})
+		}
+	}
+	return changes
+}
+
+// SoundBoard turns writes to the sound ports into audio
+type SoundBoard interface {
+	audio.Source
+	// Write latches value on port 3 or 5. at is the position of the write in
+	// the output, in samples since the board was attached; writes arrive
+	// before the samples around them are generated.
+	Write(at uint64, port, value byte)
+}
+
+// soundQueue holds port writes until generation reaches them, so sounds
+// start at the right sample rather than at a frame boundary
+type soundQueue struct {
+	pos    uint64
+	writes []portWrite
+}
+
+type portWrite struct {
+	at          uint64
+	port, value byte
+}
+
+func (q *soundQueue) Write(at uint64, port, value byte) {
+	q.writes = append(q.writes, portWrite{at, port, value})
+}
+
+// generate fills buf with render, applying each queued write when its
+// sample is reached
+func (q *soundQueue) generate(buf []int16, apply func(port, value byte), render func(buf []int16)) {
+	for len(buf) > 0 {
+		for len(q.writes) > 0 && q.writes[0].at <= q.pos {
+			apply(q.writes[0].port, q.writes[0].value)
+			q.writes = q.writes[1:]
+		}
+		n := uint64(len(buf))
+		if len(q.writes) > 0 && q.writes[0].at-q.pos < n {
+			n = q.writes[0].at - q.pos
+		}
+		render(buf[:n])
+		buf = buf[n:]
+		q.pos += n
+	}
+}
+
+// Samples are the recordings of each sound event. Missing ones are nil and
+// stay silent.
+type Samples [NumSoundEvents]*audio.Sound
+
+// LoadSamples reads a MAME sample set (0.wav to 8.wav) from a directory or a
+// zip file
+func LoadSamples(path string) (*Samples, error) {
+	var open func(name string) (io.ReadCloser, error)
+	if strings.EqualFold(filepath.Ext(path), ".zip") {
+		z, err := zip.OpenReader(path)
+		if err != nil {
+			return nil, err
+		}
+		defer z.Close()
+		open = func(name string) (io.ReadCloser, error) {
+			for _, f := range z.File {
+				if strings.EqualFold(f.Name, name) {
+					return f.Open()
+				}
+			}
+			return nil, os.ErrNotExist
+		}
+	} else {
+		open = func(name string) (io.ReadCloser, error) {
+			return os.Open(filepath.Join(path, name))
+		}
+	}
+
+	var s Samples
+	found := false
+	for e := range s {
+		name := fmt.Sprintf("%d.wav", e)
+		r, err := open(name)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		sound, err := audio.ReadWAV(r)
+		r.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+		s[e] = sound.Mono()
+		found = true
+	}
+	if !found {
+		return nil, fmt.Errorf("no samples (0.wav to 8.wav) in %s", path)
+	}
+	return &s, nil
+}
+
+// sampleChannels assigns mixer channels as the MAME driver does: the fleet
+// notes share one, so each step cuts off the last
+var sampleChannels = [NumSoundEvents]int{0, 1, 2, 3, 4, 4, 4, 4, 5}
+
+// SampleBoard plays recorded samples for the sound events
+type SampleBoard struct {
+	soundQueue
+	samples *Samples
+	mixer   *audio.Mixer
+	latches [2]byte
+}
+
+func NewSampleBoard(samples *Samples, sampleRate int) *SampleBoard {
+	b := &SampleBoard{samples: samples, mixer: audio.NewMixer(sampleRate, 6)}
+	// The amplifier is off until the game enables it
+	b.mixer.Gain = 0
+	return b
+}
+
+func (b *SampleBoard) Generate(buf []int16) {
+	b.generate(buf, b.apply, b.mixer.Generate)
+}
+
+func (b *SampleBoard) apply(port, value byte) {
+	latch := &b.latches[0]
+	if port == 5 {
+		latch = &b.latches[1]
+	}
+	for _, c := range DecodeSound(port, *latch, value) {
+		ch := sampleChannels[c.Event]
+		switch {
+		case c.On:
+			b.mixer.Play(ch, b.samples[c.Event], c.Event == SoundUFO)
+		case c.Event == SoundUFO || c.Event == SoundPlayerDeath:
+			b.mixer.Stop(ch)
+		}
+	}
+	if port == 3 {
+		b.mixer.Gain = 0
+		if value&soundAmpEnable != 0 {
+			b.mixer.Gain = 1
+		}
+	}
+	*latch = value
+}
@@ -0,0 +1,121 @@
+package machine
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"intel8080/audio"
+)
+
+func TestDecodeSound(t *testing.T) {
+	got := DecodeSound(3, 0x01, 0x22)
+	want := []SoundChange{{SoundUFO, false}, {SoundShot, true}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("port 3: expected %v, got %v\n", want, got)
+	}
+	got = DecodeSound(5, 0x01, 0x12)
+	want = []SoundChange{{SoundFleet1, false}, {SoundFleet2, true}, {SoundUFOHit, true}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("port 5: expected %v, got %v\n", want, got)
+	}
+	// The flip-screen bit is not a sound
+	if got := DecodeSound(5, 0, 0x20); len(got) != 0 {
+		t.Fatalf("expected no sound for the flip bit, got %v\n", got)
+	}
+}
+
+// constantSound plays value for n samples at 48 kHz
+func constantSound(value int16, n int) *audio.Sound {
+	s := &audio.Sound{SampleRate: audio.DefaultSampleRate, Channels: 1, Samples: make([]int16, n)}
+	for i := range s.Samples {
+		s.Samples[i] = value
+	}
+	return s
+}
+
+func TestSampleBoard(t *testing.T) {
+	var samples Samples
+	samples[SoundUFO] = constantSound(100, 10)
+	samples[SoundShot] = constantSound(1000, 4)
+	b := NewSampleBoard(&samples, audio.DefaultSampleRate)
+
+	b.Write(2, 3, 0x01) // UFO, but the amplifier is off
+	b.Write(4, 3, 0x23) // amplifier on, shot
+	b.Write(10, 3, 0x20)
+	buf := make([]int16, 12)
+	b.Generate(buf)
+	want := []int16{0, 0, 0, 0, 1100, 1100, 1100, 1100, 100, 100, 0, 0}
+	if !reflect.DeepEqual(buf, want) {
+		t.Fatalf("expected %v, got %v\n", want, buf)
+	}
+}
+
+func TestMachineSoundTiming(t *testing.T) {
+	m := newTestMachine(t, []byte{
+		0x06, 200, // MVI B,200
+		0x05,             // DCR B
+		0xC2, 0x02, 0x00, // JNZ 0x0002
+		0x3E, 0x22, // MVI A,0x22
+		0xD3, 0x03, // OUT 3
+		0xC3, 0x0A, 0x00, // JMP 0x000A
+	})
+	var samples Samples
+	samples[SoundShot] = constantSound(1000, 2000)
+	m.SetSound(NewSampleBoard(&samples, audio.DefaultSampleRate), audio.DefaultSampleRate)
+
+	if err := m.RunFrame(); err != nil {
+		t.Fatalf("RunFrame failed: %v\n", err)
+	}
+	got := m.Audio()
+	// 806.15 samples per frame at 48 kHz
+	if len(got) != 806 {
+		t.Fatalf("expected 806 samples, got %d\n", len(got))
+	}
+	// The OUT runs about 3020 cycles into the 33536 cycle frame
+	if got[71] != 0 || got[72] != 1000 {
+		t.Fatalf("expected the shot to start at sample 72, got %v\n", got[68:76])
+	}
+}
+
+func TestLoadSamples(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"1.wav", "8.wav"} {
+		w, err := audio.CreateWAV(filepath.Join(dir, name), 11025, 1)
+		if err != nil {
+			t.Fatalf("CreateWAV failed: %v\n", err)
+		}
+		_ = w.Write([]int16{1, 2, 3})
+		_ = w.Close()
+	}
+	s, err := LoadSamples(dir)
+	if err != nil {
+		t.Fatalf("LoadSamples failed: %v\n", err)
+	}
+	if s[SoundShot] == nil || s[SoundUFOHit] == nil || s[SoundUFO] != nil {
+		t.Fatalf("unexpected samples loaded: %v\n", s)
+	}
+
+	// The same set zipped, as MAME distributes it
+	zipPath := filepath.Join(dir, "invaders.zip")
+	f, _ := os.Create(zipPath)
+	z := zip.NewWriter(f)
+	data, _ := os.ReadFile(filepath.Join(dir, "1.wav"))
+	zw, _ := z.Create("1.wav")
+	_, _ = zw.Write(data)
+	_ = z.Close()
+	_ = f.Close()
+	s, err = LoadSamples(zipPath)
+	if err != nil {
+		t.Fatalf("LoadSamples(zip) failed: %v\n", err)
+	}
+	if s[SoundShot] == nil || !reflect.DeepEqual(s[SoundShot].Samples, []int16{1, 2, 3}) {
+		t.Fatalf("unexpected zipped shot sample\n")
+	}
+
+	if _, err := LoadSamples(t.TempDir()); err == nil {
+		t.Fatalf("expected an error for an empty directory\n")
+	}
+}
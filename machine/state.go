@@ -0,0 +1,179 @@
+package machine
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+
+	"intel8080/intel8080"
+)
+
+// State is a snapshot of the machine between two frames: the CPU dump plus
+// everything else RunFrame depends on. Restoring it and applying the same
+// inputs repeats a run exactly. Sound boards only listen, so they aren't
+// part of it: frames run again after Restore go through RunFrameMuted.
+//
+// Binary layout (all multi-byte values little-endian):
+//
+//	0x00  8  magic "INVSTATE"
+//	0x08  1  format version (1)
+//	0x09  8  CPU cycles
+//	0x11  8  frame
+//	0x19  8  cycle at which the next frame starts
+//	0x21  8  frames since the watchdog was kicked
+//	0x29  8  watchdog resets
+//	0x31  8  coin counter
+//	0x39  4  length n of the screen
+//	0x3d  n  screen, as scanned out by the beam
+//	      ...  CPU dump (see intel8080.Dump)
+type State struct {
+	CPU            *intel8080.Dump
+	Cycles         uint64
+	Frame          uint64
+	FrameStart     uint64
+	Watchdog       uint64
+	WatchdogResets uint64
+	CoinCounter    uint64
+	Screen         []byte
+}
+
+var stateMagic = []byte("INVSTATE")
+
+const stateVersion = 1
+
+// Snapshot captures the machine state. Take it between frames: events
+// scheduled by other devices are not part of it.
+func (m *Machine) Snapshot() *State {
+	return &State{
+		CPU:            m.CPU.Dump(),
+		Cycles:         m.CPU.Cycles,
+		Frame:          m.Frame,
+		FrameStart:     m.frameStart,
+		Watchdog:       m.watchdog,
+		WatchdogResets: m.WatchdogResets,
+		CoinCounter:    m.IOBus.CoinCounter,
+		Screen:         append([]byte(nil), m.screen...),
+	}
+}
+
+// Restore returns the machine to a snapshot
+func (m *Machine) Restore(s *State) error {
+	if len(s.Screen) != len(m.screen) {
+		return fmt.Errorf("restore: screen size mismatch (state has %d bytes, machine has %d)", len(s.Screen), len(m.screen))
+	}
+	if err := m.CPU.Restore(s.CPU); err != nil {
+		return err
+	}
+	m.CPU.Cycles = s.Cycles
+	m.Frame = s.Frame
+	m.frameStart = s.FrameStart
+	m.watchdog = s.Watchdog
+	m.WatchdogResets = s.WatchdogResets
+	m.IOBus.CoinCounter = s.CoinCounter
+	copy(m.screen, s.Screen)
+	m.Scheduler.Clear()
+	m.scheduleLine(0, m.frameStart)
+	return nil
+}
+
+// Hash is a checksum of the state, to compare runs cheaply
+func (s *State) Hash() uint64 {
+	data, _ := s.MarshalBinary()
+	h := fnv.New64a()
+	_, _ = h.Write(data)
+	return h.Sum64()
+}
+
+func (s *State) MarshalBinary() ([]byte, error) {
+	buf := bytes.Buffer{}
+	buf.Write(stateMagic)
+	buf.WriteByte(stateVersion)
+	for _, v := range []uint64{s.Cycles, s.Frame, s.FrameStart, s.Watchdog, s.WatchdogResets, s.CoinCounter} {
+		_ = binary.Write(&buf, binary.LittleEndian, v)
+	}
+	_ = binary.Write(&buf, binary.LittleEndian, uint32(len(s.Screen)))
+	buf.Write(s.Screen)
+	dump, err := s.CPU.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	buf.Write(dump)
+	return buf.Bytes(), nil
+}
+
+func (s *State) UnmarshalBinary(data []byte) error {
+	r := bytes.NewReader(data)
+	header := make([]byte, len(stateMagic)+1)
+	if _, err := io.ReadFull(r, header); err != nil || !bytes.Equal(header[:len(stateMagic)], stateMagic) {
+		return fmt.Errorf("state: bad header")
+	}
+	if header[len(stateMagic)] != stateVersion {
+		return fmt.Errorf("state: unsupported version %d", header[len(stateMagic)])
+	}
+	var fixed struct {
+		Cycles, Frame, FrameStart, Watchdog, WatchdogResets, CoinCounter uint64
+		ScreenLen                                                        uint32
+	}
+	if err := binary.Read(r, binary.LittleEndian, &fixed); err != nil {
+		return fmt.Errorf("state: truncated: %v", err)
+	}
+	if int(fixed.ScreenLen) > r.Len() {
+		return fmt.Errorf("state: truncated screen")
+	}
+	screen := make([]byte, fixed.ScreenLen)
+	_, _ = io.ReadFull(r, screen)
+	dump := &intel8080.Dump{}
+	rest := make([]byte, r.Len())
+	_, _ = io.ReadFull(r, rest)
+	if err := dump.UnmarshalBinary(rest); err != nil {
+		return fmt.Errorf("state: %v", err)
+	}
+	*s = State{
+		CPU:            dump,
+		Cycles:         fixed.Cycles,
+		Frame:          fixed.Frame,
+		FrameStart:     fixed.FrameStart,
+		Watchdog:       fixed.Watchdog,
+		WatchdogResets: fixed.WatchdogResets,
+		CoinCounter:    fixed.CoinCounter,
+		Screen:         screen,
+	}
+	return nil
+}
+
+func WriteStateFile(filename string, s *State) error {
+	data, err := s.MarshalBinary()
+	if err != nil {
+		return fmt.Errorf("writeState: %v", err)
+	}
+	if err := os.WriteFile(filename, data, 0o600); err != nil {
+		return fmt.Errorf("writeState: %v", err)
+	}
+	return nil
+}
+
+func ReadStateFile(filename string) (*State, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("readState: failed reading file: %v", err)
+	}
+	s := State{}
+	if err := s.UnmarshalBinary(data); err != nil {
+		return nil, fmt.Errorf("readState: %s: %v", filename, err)
+	}
+	return &s, nil
+}
+
+// romSize is the size of the Invaders program ROMs, at 0x0000-0x1fff
+const romSize = 0x2000
+
+// RomHash is the SHA-1 of the program ROM as loaded, patches included
+func (m *Machine) RomHash() string {
+	sum := sha1.Sum(m.Memory.GetSlice(0, romSize))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,161 @@
+package machine
+
+import (
+	"intel8080/audio"
+)
+
+// fleetHz are the four notes of the marching fleet, descending
+var fleetHz = [4]float64{62, 56, 51, 46}
+
+// SynthBoard models the Invaders sound board instead of playing samples: the
+// UFO on its SN76477 and the other sounds on their discrete circuits. Most of
+// those are one-shots, a capacitor charged by the trigger and discharging
+// through a resistor, which sets the volume and, where the circuit sweeps,
+// the pitch. The result is deterministic, so it can be tested sample by
+// sample.
+type SynthBoard struct {
+	soundQueue
+	latches [2]byte
+	amp     bool
+
+	ufo *audio.SN76477
+	// One noise source feeds the shot and both explosions
+	noise *audio.Noise
+
+	shot                 oneShot
+	shotOsc              audio.Oscillator
+	playerDeath          oneShot
+	playerDeathFilter    audio.LowPass
+	invaderDeath         oneShot
+	invaderDeathFilter   audio.LowPass
+	fleet                oneShot
+	fleetOsc             audio.Oscillator
+	fleetFilter          audio.LowPass
+	fleetNote            int
+	ufoHit               oneShot
+	ufoHitOsc, ufoHitLFO audio.Oscillator
+
+	// dc follows the mix's average, which the amplifier's coupling
+	// capacitor blocks
+	dc audio.LowPass
+}
+
+// oneShot is a capacitor charged at once by its trigger and discharging with
+// time constant tau seconds
+type oneShot struct {
+	audio.Envelope
+	tau float64
+}
+
+func newOneShot(sampleRate int, tau float64) oneShot {
+	return oneShot{Envelope: audio.NewEnvelope(sampleRate), tau: tau}
+}
+
+func (o *oneShot) trigger() {
+	o.Level = 1
+	o.Charge(0, o.tau)
+}
+
+func NewSynthBoard(sampleRate int) *SynthBoard {
+	ufo := audio.NewSN76477(sampleRate)
+	// Component values from the Invaders schematic: a 5.3 Hz SLF sweeping
+	// the VCO, which tops out at 780 Hz, straight to the mixer
+	ufo.SLFRes, ufo.SLFCap = 120e3, 1e-6
+	ufo.VCORes, ufo.VCOCap = 8.2e3, 0.1e-6
+	ufo.VCOFromSLF = true
+	ufo.Mixer = audio.MixVCO
+	ufo.Amplitude = 0.3
+
+	return &SynthBoard{
+		ufo:                ufo,
+		noise:              audio.NewNoise(12000, sampleRate),
+		shot:               newOneShot(sampleRate, 0.12),
+		shotOsc:            audio.NewOscillator(sampleRate),
+		playerDeath:        newOneShot(sampleRate, 0.8),
+		playerDeathFilter:  audio.NewLowPass(500, sampleRate),
+		invaderDeath:       newOneShot(sampleRate, 0.12),
+		invaderDeathFilter: audio.NewLowPass(2500, sampleRate),
+		fleet:              newOneShot(sampleRate, 0.06),
+		fleetOsc:           audio.NewOscillator(sampleRate),
+		fleetFilter:        audio.NewLowPass(250, sampleRate),
+		ufoHit:             newOneShot(sampleRate, 0.35),
+		ufoHitOsc:          audio.NewOscillator(sampleRate),
+		ufoHitLFO:          audio.NewOscillator(sampleRate),
+		dc:                 audio.NewLowPass(10, sampleRate),
+	}
+}
+
+func (b *SynthBoard) Generate(buf []int16) {
+	b.generate(buf, b.apply, b.render)
+}
+
+func (b *SynthBoard) apply(port, value byte) {
+	latch := &b.latches[0]
+	if port == 5 {
+		latch = &b.latches[1]
+	}
+	for _, c := range DecodeSound(port, *latch, value) {
+		switch {
+		case c.Event == SoundUFO:
+			b.ufo.SetEnable(c.On)
+		case c.Event == SoundPlayerDeath && !c.On:
+			// Clearing the bit discharges the capacitor early
+			b.playerDeath.Charge(0, 0.03)
+		case !c.On:
+		case c.Event == SoundShot:
+			b.shot.trigger()
+		case c.Event == SoundPlayerDeath:
+			b.playerDeath.trigger()
+		case c.Event == SoundInvaderDeath:
+			b.invaderDeath.trigger()
+		case c.Event >= SoundFleet1 && c.Event <= SoundFleet4:
+			b.fleetNote = int(c.Event - SoundFleet1)
+			b.fleet.trigger()
+		case c.Event == SoundUFOHit:
+			b.ufoHit.trigger()
+		}
+	}
+	if port == 3 {
+		b.amp = value&soundAmpEnable != 0
+	}
+	*latch = value
+}
+
+func (b *SynthBoard) render(buf []int16) {
+	for i := range buf {
+		noise := b.noise.Next()
+		mix := b.ufo.Next()
+
+		// The shot's pitch falls with its capacitor, over a hiss of noise
+		shot := b.shot.Next()
+		tone := audio.Square(b.shotOsc.Next(300+1500*shot), 0.5)
+		mix += 0.3 * shot * (0.7*tone + 0.3*noise)
+
+		mix += 0.9 * b.playerDeath.Next() * b.playerDeathFilter.Next(noise)
+		mix += 0.6 * b.invaderDeath.Next() * b.invaderDeathFilter.Next(noise)
+
+		fleet := audio.Square(b.fleetOsc.Next(fleetHz[b.fleetNote]), 0.5)
+		mix += 0.5 * b.fleet.Next() * b.fleetFilter.Next(fleet)
+
+		// The UFO hit warbles at 12 Hz
+		warble := audio.Triangle(b.ufoHitLFO.Next(12))
+		mix += 0.25 * b.ufoHit.Next() * audio.Square(b.ufoHitOsc.Next(500+500*warble), 0.5)
+
+		mix -= b.dc.Next(mix)
+		if !b.amp {
+			buf[i] = 0
+			continue
+		}
+		buf[i] = clampSample(mix * 32767)
+	}
+}
+
+func clampSample(v float64) int16 {
+	if v > 32767 {
+		return 32767
+	}
+	if v < -32768 {
+		return -32768
+	}
+	return int16(v)
+}
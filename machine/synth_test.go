@@ -0,0 +1,99 @@
+package machine
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"intel8080/audio"
+)
+
+var update = flag.Bool("update", false, "rewrite golden sounds")
+
+// synthRate keeps the golden file small
+const synthRate = 11025
+
+// renderEvents plays each sound event in turn, a fifth of a second apart,
+// with the amplifier on
+func renderEvents() []int16 {
+	b := NewSynthBoard(synthRate)
+	const step = synthRate / 5
+	b.Write(0, 3, soundAmpEnable)
+	for e := SoundEvent(0); e < NumSoundEvents; e++ {
+		in := soundBits[e]
+		on := byte(1) << in.bit
+		base := byte(0)
+		if in.port == 3 {
+			base = soundAmpEnable
+		}
+		at := uint64(e) * step
+		b.Write(at, in.port, base|on)
+		b.Write(at+step/2, in.port, base)
+	}
+	buf := make([]int16, int(NumSoundEvents)*step)
+	b.Generate(buf)
+	return buf
+}
+
+// TestSynthGolden compares the synthesised sounds with testdata/synth.wav.
+// Run with -update to rewrite it after an intended change. Small differences
+// are allowed, as floating point rounding differs between architectures.
+func TestSynthGolden(t *testing.T) {
+	got := renderEvents()
+	golden := filepath.Join("testdata", "synth.wav")
+	if *update {
+		w, err := audio.CreateWAV(golden, synthRate, 1)
+		if err != nil {
+			t.Fatalf("CreateWAV failed: %v\n", err)
+		}
+		_ = w.Write(got)
+		if err := w.Close(); err != nil {
+			t.Fatalf("Close failed: %v\n", err)
+		}
+	}
+	f, err := os.Open(golden)
+	if err != nil {
+		t.Fatalf("Open failed: %v\n", err)
+	}
+	defer f.Close()
+	want, err := audio.ReadWAV(f)
+	if err != nil {
+		t.Fatalf("ReadWAV failed: %v\n", err)
+	}
+	if len(want.Samples) != len(got) {
+		t.Fatalf("expected %d samples, got %d (run with -update if intended)\n", len(want.Samples), len(got))
+	}
+	for i := range got {
+		if d := int(got[i]) - int(want.Samples[i]); d > 16 || d < -16 {
+			t.Fatalf("sample %d: expected %d, got %d (run with -update if intended)\n", i, want.Samples[i], got[i])
+		}
+	}
+}
+
+func TestSynthEvents(t *testing.T) {
+	got := renderEvents()
+	const step = synthRate / 5
+	for e := SoundEvent(0); e < NumSoundEvents; e++ {
+		var peak int16
+		for _, v := range got[int(e)*step : int(e)*step+step/2] {
+			if v > peak {
+				peak = v
+			}
+		}
+		if peak < 1000 {
+			t.Errorf("%v: expected a sound, peak %d\n", e, peak)
+		}
+	}
+
+	// Without the amplifier there is nothing to hear
+	b := NewSynthBoard(synthRate)
+	b.Write(0, 3, 0x02)
+	buf := make([]int16, 100)
+	b.Generate(buf)
+	for i, v := range buf {
+		if v != 0 {
+			t.Fatalf("sample %d: expected silence with the amplifier off, got %d\n", i, v)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package machine
+
+const (
+	// VRAM is 224 rows of 32 bytes. Each row is one scanline of 256 pixels
+	// (the monitor is mounted rotated, so scanlines run bottom to top).
+	vramRows     = 224
+	vramRowBytes = 32
+
+	// The board raises RST 1 (0xCF) when the beam reaches the middle of the
+	// screen and RST 2 (0xD7) at the start of vertical blank
+	midScreenLine = 96
+	vblankLine    = 224
+)
+
+// scheduleLine schedules the video event for line of the frame starting at frameStart.
+// Each visible VRAM row is scanned out as the beam reaches it, RST 1 is raised
+// at line 96 and RST 2 at the start of vertical blank (line 224). Interrupts are
+// taken at the first instruction boundary after their cycle.
+func (m *Machine) scheduleLine(line uint64, frameStart uint64) {
+	m.Scheduler.Schedule(frameStart+m.lineCycle(line), "video line", func(_ uint64) {
+		switch {
+		case line < vramRows:
+			if line == midScreenLine {
+				m.CPU.Interrupt(1)
+			}
+			m.scanOut(line)
+		case line == vblankLine:
+			m.CPU.Interrupt(2)
+		}
+
+		if line+1 < vTotal {
+			m.scheduleLine(line+1, frameStart)
+		} else {
+			m.scheduleLine(0, frameStart+m.CyclesPerFrame())
+		}
+	})
+}
+
+// lineCycle returns the cycle, relative to the start of the frame, at which
+// the beam reaches line
+func (m *Machine) lineCycle(line uint64) uint64 {
+	return line * m.CyclesPerFrame() / vTotal
+}
+
+// Scanline returns the line the beam is currently on (224-261 are vertical blank)
+func (m *Machine) Scanline() uint64 {
+	frameCycles := m.CyclesPerFrame()
+	if frameCycles == 0 {
+		return 0
+	}
+	elapsed := m.CPU.Cycles - m.frameStart
+	if elapsed >= frameCycles {
+		return vTotal - 1
+	}
+	return elapsed * vTotal / frameCycles
+}
+
+// scanOut copies one VRAM row into the screen buffer as the beam draws it
+func (m *Machine) scanOut(line uint64) {
+	start := line * vramRowBytes
+	copy(m.screen[start:start+vramRowBytes], m.GetVram()[start:start+vramRowBytes])
+}
+
+// Screen returns the image drawn by the beam during the last frame. Unlike
+// GetVram, it only reflects VRAM writes made before the beam passed each row,
+// so tearing looks as it does on the cabinet.
+func (m *Machine) Screen() []byte {
+	return m.screen
+}
@@ -0,0 +1,51 @@
+package machine
+
+import (
+	"testing"
+)
+
+func TestBeamTiming(t *testing.T) {
+	program := make([]byte, 0x30)
+	copy(program[0x00:], []byte{0x31, 0x00, 0x24, 0xFB, 0xC3, 0x04, 0x00}) // LXI SP,0x2400 / EI / JMP 0x0004
+	copy(program[0x08:], []byte{0xC3, 0x20, 0x00})                         // RST 1: JMP 0x0020
+	copy(program[0x10:], []byte{0xFB, 0xC9})                               // RST 2: EI / RET
+	copy(program[0x20:], []byte{
+		0x3E, 0xAA, //       MVI A,0xAA
+		0x32, 0x00, 0x3D, // STA 0x3D00 (row 200, drawn after line 96)
+		0xAF,             // XRA A
+		0x32, 0x00, 0x24, // STA 0x2400 (row 0, already drawn)
+		0xFB, 0xC9, //       EI / RET
+	})
+	m := newTestMachine(t, program)
+	m.CPU.EnableHistory(10000)
+	m.Memory.Write(0x2400, 0xFF)
+
+	if err := m.RunFrame(); err != nil {
+		t.Fatalf("RunFrame failed: %v\n", err)
+	}
+
+	var rst1, rst2 uint64
+	for _, e := range m.CPU.Trace() {
+		switch e.PC {
+		case 0x08:
+			rst1 = e.Cycle
+		case 0x10:
+			rst2 = e.Cycle
+		}
+	}
+	// Each instruction in the idle loop is 10 cycles, so interrupts land within 10 cycles of their line
+	if want := uint64(96 * 128); rst1 < want || rst1 >= want+10 {
+		t.Errorf("expected RST 1 at line 96 (cycle %d), got cycle %d\n", want, rst1)
+	}
+	if want := uint64(224 * 128); rst2 < want || rst2 >= want+10 {
+		t.Errorf("expected RST 2 at line 224 (cycle %d), got cycle %d\n", want, rst2)
+	}
+
+	screen := m.Screen()
+	if screen[0] != 0xFF || m.GetVram()[0] != 0x00 {
+		t.Errorf("row 0 was drawn before RST 1 cleared it: expected screen 0xff / vram 0x00, got 0x%02x / 0x%02x\n", screen[0], m.GetVram()[0])
+	}
+	if screen[200*32] != 0xAA {
+		t.Errorf("row 200 was drawn after RST 1 set it: expected 0xaa, got 0x%02x\n", screen[200*32])
+	}
+}
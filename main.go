@@ -1,40 +1,162 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"log"
+	"math"
 	"os"
 	"os/signal"
+	"strings"
 	"time"
 
-	"github.com/veandco/go-sdl2/sdl"
+	"intel8080/audio"
 	"intel8080/display"
 	"intel8080/intel8080"
+	"intel8080/machine"
+	"intel8080/netplay"
 )
 
 var testRomPath = flag.String("test", "", "Run a test ROM")
+var manifestPath = flag.String("manifest", "", "Load ROMs and patches from a game manifest (JSON)")
+var patchSpecs = flag.String("patch", "", "Comma separated IPS/BPS patches to apply, each as file[:baseCRC32[:resultCRC32]]")
+var dumpDiff = flag.String("dump-diff", "", "Compare two machine dumps given as old.dump,new.dump and exit")
+var dumpExport = flag.String("dump-export", "", "Export memory from a machine dump and exit (see -range and -format)")
+var dumpRange = flag.String("range", "0000-3fff", "Address range for -dump-export, as start-end in hex (inclusive)")
+var dumpFormat = flag.String("format", "hex", "Format for -dump-export: hex (hexdump) or ihex (Intel HEX)")
+var historySize = flag.Int("history", intel8080.DefaultHistorySize, "Instructions / IO accesses / inputs kept for crash bundles (0 disables)")
+var sanitize = flag.String("sanitize", "", "Enable runtime sanitizer checks: all, or a comma separated list of uninit,stack,exec,smc,irq,ret")
+var clockHz = flag.Uint64("clock", machine.DefaultClockHz, "CPU clock in Hz (the video refresh rate stays at ~59.54 Hz)")
+var watchdog = flag.Bool("watchdog", true, "Reset the CPU when the game stops writing to port 6 for 255 frames, as the board does")
+var speed = flag.Float64("speed", 1, "Emulation speed as a multiple of real time (0 runs uncapped)")
+var fastForward = flag.Float64("ff", 0, "Speed while fast-forward (Tab) is held (0 runs uncapped)")
+var slowMotion = flag.Float64("slowmo", 0.25, "Speed when slow motion (F7) is toggled on")
+var scaleMode = flag.String("scale", "fit", "Window scaling: fit, integer or stretch")
+var squarePixels = flag.Bool("square-pixels", false, "Draw square pixels instead of the cabinet's 3:4 picture")
+var vsync = flag.Bool("vsync", true, "Wait for the monitor's vertical blank when presenting frames")
+var fullscreen = flag.Bool("fullscreen", false, "Start in fullscreen (toggle with F11 or Alt+Enter)")
+var smooth = flag.Bool("smooth", false, "Use linear filtering when scaling")
+var replayCrash = flag.String("replay-crash", "", "Load a crash bundle into the monitor at the moment of failure")
+
+var displayMode = flag.String("display", "sdl", "Display backend: sdl (window) or terminal (text, for SSH)")
+var termMode = flag.String("term-mode", "braille", "Characters used by -display=terminal: braille, halfblock or ascii")
+var termMono = flag.Bool("term-mono", false, "Disable ANSI colours in -display=terminal")
+var termHold = flag.Uint64("term-hold", 10, "Frames an input stays pressed after a key press in -display=terminal")
+var headless = flag.Bool("headless", false, "Run without a display (see -frames, -script, -png and -png-at)")
+var goldenPath = flag.String("golden", "", "In -headless mode, fail unless the last frame (after -filter) matches this PNG")
+var frames = flag.Uint64("frames", 600, "Frames to run in -headless mode")
+var scriptPath = flag.String("script", "", "Input timeline for -headless mode: lines of \"frame input press|release\"")
+var pngPath = flag.String("png", "frame-%06d.png", "PNG file written for -png-at frames; %d is replaced by the frame number")
+var pngAt = flag.String("png-at", "end", "Comma separated frames to write as PNG (end = last frame); interactive runs only write them when set")
+var captureDir = flag.String("capture-dir", ".", "Directory for screenshots (F12) and GIF recordings (F10)")
+var gifPath = flag.String("gif", "", "Record an animated GIF of the whole run to this file")
+var orientationFlag = flag.String("orientation", "", "Screen rotation clockwise from the raw picture: 0, 90, 180 or 270 (Invaders: 270), m suffix to mirror; overrides the manifest")
+var cabinetFlag = flag.String("cabinet", "", "Cabinet: upright (shared controls) or cocktail (P2 controls, screen flips for P2); overrides the manifest")
+var overlayFlag = flag.String("overlay", "", "Colour overlay: none, bands, midway or a PNG the size of the screen (224x256); overrides the manifest")
+var backdropFlag = flag.String("backdrop", "", "Backdrop PNG blended behind lit pixels; overrides the manifest")
+var filterSpec = flag.String("filter", "", "Video filters: crt, smooth, or a chain such as persistence:0.6,tint:d8e8ff,bloom,vscanlines,scale2x (F8 cycles them)")
+var soundMode = flag.String("sound", "", "Sound: synth (modelled circuits), samples (MAME samples, see -samples) or none; defaults to samples when a sample set is given, otherwise synth")
+var samplesPath = flag.String("samples", "", "Play sound from a MAME sample set (0.wav to 8.wav), as a directory or zip file; overrides the manifest")
+var y4mPath = flag.String("y4m", "", "Record every frame, uncompressed, to this YUV4MPEG2 file")
+var wavPath = flag.String("wav", "", "Record the emulated audio to this WAV file, in step with -y4m")
+var gifSkip = flag.Int("gif-skip", 2, "Record every n-th frame in GIFs (GIF delays are in 1/100 s)")
+var keymapPath = flag.String("keymap", "", "Key and controller map (JSON) added to the default bindings")
+var dipFlag = flag.String("dip", "", "DIP switches as name=value pairs, e.g. lives=5,bonus=1000 (lives, bonus, coin_info, dip4); overrides -dip-config and the manifest")
+var dipConfig = flag.String("dip-config", "", "DIP switch settings file (JSON), e.g. {\"lives\": \"5\"}; overrides the manifest")
+var recordMovie = flag.String("record-movie", "", "Record every input change, the starting state and per-frame state hashes to this movie file")
+var playMovie = flag.String("play-movie", "", "Replay a movie in -headless mode, failing at the first frame whose state differs; runs the whole movie unless -frames is given")
+var tasPath = flag.String("tas", "", "Edit a movie frame by frame on the command line (created from power-on if missing), then exit")
+var statePath = flag.String("state", "invaders.state", "File written by save-state (F2) and read by load-state (F4)")
+var netplayListen = flag.String("netplay-listen", "", "Host a two-player netplay game as player 1, waiting for the peer on this UDP address (e.g. :7000)")
+var netplayConnect = flag.String("netplay-connect", "", "Join a two-player netplay game as player 2 at this UDP address (e.g. host:7000)")
+var netplayDelay = flag.Uint64("netplay-delay", netplay.DefaultDelay, "Netplay input delay in frames; more hides more latency without rollbacks")
+var netplayLatency = flag.Duration("netplay-latency", 0, "Delay every netplay packet sent by this much more, to test on one machine (e.g. 80ms)")
+var netplayLoss = flag.Float64("netplay-loss", 0, "Drop this fraction of the netplay packets sent (0 to 1), to test on one machine")
 
 var cpu *intel8080.CPU
 
+// running is cleared to stop the interactive loop
+var running = true
+
+// interrupts receives SIGINT, which dumps the machine to core.dump
+var interrupts = make(chan os.Signal, 1)
+
+// dumpOnInterrupt writes core.dump and reports true if SIGINT arrived. It is
+// called from the emulation goroutine between frames, so the CPU holds still
+// while it is dumped.
+func dumpOnInterrupt(cpu *intel8080.CPU) bool {
+	select {
+	case sig := <-interrupts:
+		log.Printf("(sig %v) Dumping memory... ", sig)
+		if err := intel8080.WriteDumpFile("core.dump", cpu.Dump()); err != nil {
+			log.Printf("WriteDumpFile error: %v\n", err)
+		}
+		return true
+	default:
+		return false
+	}
+}
+
 func main() {
 	fmt.Println("Launching...")
 
 	flag.Parse()
+	if *clockHz < uint64(math.Ceil(machine.RefreshRate)) {
+		// Slower clocks leave no cycles to run in a frame
+		fmt.Printf("-clock must be at least %d Hz, got %d\n", uint64(math.Ceil(machine.RefreshRate)), *clockHz)
+		os.Exit(1)
+	}
+	var err error
 	if *testRomPath != "" {
 		fmt.Printf("Running a test ROM - %s\n", *testRomPath)
-		intel8080.RunTestRom(*testRomPath)
+		if err := intel8080.RunTestRom(*testRomPath); err != nil {
+			fmt.Printf("\nTest ROM failed: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if *replayCrash != "" {
+		if err := runReplayCrash(*replayCrash); err != nil {
+			fmt.Printf("%v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+	if *dumpDiff != "" || *dumpExport != "" {
+		if err := runDumpTool(); err != nil {
+			fmt.Printf("%v\n", err)
+			os.Exit(1)
+		}
 		return
 	}
 
+	manifest := intel8080.DefaultManifest()
+	if *manifestPath != "" {
+		manifest, err = intel8080.LoadManifest(*manifestPath)
+		if err != nil {
+			fmt.Printf("LoadManifest failed: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	patches, err := manifest.RomPatches()
+	if err != nil {
+		fmt.Printf("Bad manifest patches: %v\n", err)
+		os.Exit(1)
+	}
+	if *patchSpecs != "" {
+		for _, spec := range strings.Split(*patchSpecs, ",") {
+			patch, err := intel8080.ParsePatchSpec(spec)
+			if err != nil {
+				fmt.Printf("%v\n", err)
+				os.Exit(1)
+			}
+			patches = append(patches, patch)
+		}
+	}
+
 	memory := intel8080.NewMemory(0x4000)
-	romDir := "roms/"
-	count, err := memory.LoadRomFiles([]string{
-		romDir + "invaders.h",
-		romDir + "invaders.g",
-		romDir + "invaders.f",
-		romDir + "invaders.e",
-	}, 0x0, true)
+	count, err := memory.LoadPatchedRomFiles(manifest.RomPaths(), patches, manifest.Offset, true)
 	fmt.Printf("%d bytes loaded\n", count)
 	if err != nil {
 		fmt.Printf("LoadRomFiles failed: %v\n", err)
@@ -43,150 +165,308 @@ func main() {
 
 	ioBus := intel8080.NewIOBus()
 	cpu = intel8080.NewCPU(ioBus, memory)
+	cpu.EnableHistory(*historySize)
+	if *sanitize != "" {
+		checks, err := intel8080.ParseSanitizerChecks(*sanitize)
+		if err != nil {
+			fmt.Printf("%v\n", err)
+			os.Exit(1)
+		}
+		config := intel8080.InvadersSanitizerConfig()
+		config.Checks = checks
+		sanitizer := intel8080.NewSanitizer(config)
+		sanitizer.OnReport = func(r intel8080.SanitizerReport) {
+			log.Println(r)
+		}
+		cpu.AttachSanitizer(sanitizer)
+	}
 
 	if err != nil {
 		log.Fatalf("load invaders failed: %v", err)
 		os.Exit(0)
 	}
 
-	running := true
-
 	// Trap SIGINT for debugging purposes
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt)
-	go func() {
-		for sig := range c {
-			log.Printf("(sig %v) Dumping memory... ", sig)
-			err := dumpCoreToFile("core.dump", memory)
-			if err != nil {
-				log.Printf("dumpCoreToFile error: %v\n", err)
-			}
-			running = false
+	signal.Notify(interrupts, os.Interrupt)
+
+	m := machine.New(cpu, memory, ioBus)
+	m.ClockHz = *clockHz
+	if !*watchdog {
+		m.WatchdogFrames = 0
+	}
+	if orientation := pick(*orientationFlag, manifest.Orientation); orientation != "" {
+		if m.Orientation, err = display.ParseOrientation(orientation); err != nil {
+			fmt.Printf("%v\n", err)
+			os.Exit(1)
 		}
-	}()
+	}
+	switch cabinet := pick(*cabinetFlag, manifest.Cabinet); cabinet {
+	case "", "upright":
+	case "cocktail":
+		ioBus.Cocktail = true
+	default:
+		fmt.Printf("unknown cabinet %q (want upright or cocktail)\n", cabinet)
+		os.Exit(1)
+	}
+	if err := setupDIPSwitches(m, manifest.DIPSwitches); err != nil {
+		fmt.Printf("%v\n", err)
+		os.Exit(1)
+	}
+	overlay, err := machine.LoadOverlay(pick(*overlayFlag, manifest.Asset(manifest.Overlay)),
+		pick(*backdropFlag, manifest.Asset(manifest.Backdrop)), m.Orientation)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		os.Exit(1)
+	}
+	if err := setupSound(m, *soundMode, pick(*samplesPath, manifest.SamplesPath())); err != nil {
+		fmt.Printf("%v\n", err)
+		os.Exit(1)
+	}
+
+	if *headless || *tasPath != "" {
+		// Without a run loop of our own, look for SIGINT between frames
+		m.OnFrame = func() error {
+			dumpOnInterrupt(cpu)
+			return nil
+		}
+	}
+	if *tasPath != "" {
+		if err := runTAS(m, overlay); err != nil {
+			fmt.Printf("%v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	finishMovie, err := startMovie(m)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		os.Exit(1)
+	}
+	peer, err := startNetplay(m)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		os.Exit(1)
+	}
 
-	screenCols := uint(256)
-	screenRows := uint(224)
-	screenWidth := uint(512)
-	screenHeight := screenRows * screenWidth / screenCols
-
-	display.Init(screenHeight, screenWidth, screenRows, screenCols)
-	defer display.Cleanup()
-	cm := display.NewColorMask()
-	cm.AddBoxMask(0, screenCols, 48, 64, 0xff00ff00)   // Green
-	cm.AddBoxMask(0, screenCols, 192, 224, 0xffff0000) // Red
-	display.SetColorMask(cm)
-
-	vram := cpu.GetVram()
-	fmt.Println("Starting CPU")
-	var holdCycles uint
-	var currCycles uint
-	var interruptType uint = 1
-	sleepTime := time.Duration(0)
-	for running {
-		if holdCycles > 0 {
-			holdCycles--
-			time.Sleep(sleepTime)
-			continue
-		}
-
-		holdCycles, err = cpu.Step()
+	switch {
+	case *headless:
+		err = runHeadless(m, overlay)
+	case *displayMode == "terminal":
+		err = runTerminal(m, overlay, peer)
+	case *displayMode == "sdl":
+		err = runInteractive(m, overlay, peer)
+	default:
+		err = fmt.Errorf("unknown -display %q (want sdl or terminal)", *displayMode)
+	}
+	if peer != nil {
+		peer.close()
+	}
+	if movieErr := finishMovie(); err == nil {
+		err = movieErr
+	}
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		os.Exit(1)
+	}
+}
+
+// pick returns the command line setting if given, otherwise the manifest's
+func pick(flagValue, manifestValue string) string {
+	if flagValue != "" {
+		return flagValue
+	}
+	return manifestValue
+}
+
+// flagSet reports whether a flag was given on the command line
+func flagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// setupSound attaches the sound board selected by -sound
+func setupSound(m *machine.Machine, mode, samplesPath string) error {
+	if mode == "" {
+		mode = "synth"
+		if samplesPath != "" {
+			mode = "samples"
+		}
+	}
+	switch mode {
+	case "synth":
+		m.SetSound(machine.NewSynthBoard(audio.DefaultSampleRate), audio.DefaultSampleRate)
+	case "samples":
+		if samplesPath == "" {
+			return fmt.Errorf("-sound=samples needs a sample set (-samples or the manifest)")
+		}
+		samples, err := machine.LoadSamples(samplesPath)
 		if err != nil {
-			fmt.Printf("CPU Execution error: %v\n", err)
-			running = false
+			return err
 		}
-		currCycles += holdCycles
+		m.SetSound(machine.NewSampleBoard(samples, audio.DefaultSampleRate), audio.DefaultSampleRate)
+	case "none":
+	default:
+		return fmt.Errorf("unknown -sound %q (want synth, samples or none)", mode)
+	}
+	return nil
+}
 
-		if currCycles > 16666 {
-			currCycles = 0
-			if interruptType == 2 {
-				interruptType = 1
-			} else if interruptType == 1 {
-				interruptType = 2
-			}
-			_ = display.DrawRotated(vram)
-			// trigger interrupt (this happens in hardware at VBlank and ~1/2 VBlank)
-			cpu.Interrupt(interruptType)
-
-			for event := sdl.PollEvent(); event != nil; event = sdl.PollEvent() {
-				switch t := event.(type) {
-				case *sdl.KeyboardEvent:
-					// Game input
-					pressed := false
-					if t.Type == sdl.KEYDOWN {
-						pressed = true
-					} else if t.Type == sdl.KEYUP {
-						pressed = false
-					}
-					switch t.Keysym.Sym {
-					case sdl.K_c:
-						ioBus.HandleInput(1, 0, pressed)
-					case sdl.K_SPACE:
-						ioBus.HandleInput(1, 2, pressed)
-					case sdl.K_w:
-						ioBus.HandleInput(1, 4, pressed)
-					case sdl.K_a:
-						ioBus.HandleInput(1, 5, pressed)
-					case sdl.K_d:
-						ioBus.HandleInput(1, 6, pressed)
-					case sdl.K_t:
-						ioBus.HandleInput(2, 2, pressed)
-					}
-
-					// Misc input
-					if t.Type == sdl.KEYDOWN {
-						switch t.Keysym.Sym {
-						case sdl.K_ESCAPE:
-							running = false
-						case sdl.K_LEFTBRACKET:
-							if cpu.DEBUG {
-								cpu.DEBUG = false
-							} else {
-								cpu.DEBUG = true
-							}
-						case sdl.K_RIGHTBRACKET:
-							if ioBus.DEBUG {
-								ioBus.DEBUG = false
-							} else {
-								ioBus.DEBUG = true
-							}
-						case sdl.K_p:
-							if memory.DEBUG {
-								memory.DEBUG = false
-							} else {
-								memory.DEBUG = true
-							}
-						case sdl.K_COMMA:
-							sleepTime += 1 * time.Nanosecond
-							fmt.Printf("sleepTime: %d\n", sleepTime)
-						case sdl.K_PERIOD:
-							sleepTime -= 1 * time.Nanosecond
-							if sleepTime < 0 {
-								sleepTime = 0
-							}
-							fmt.Printf("sleepTime: %d\n", sleepTime)
-						}
-					}
-				case *sdl.QuitEvent:
-					println("Quit")
-					running = false
-				}
-			}
+// setupDIPSwitches applies the manifest's DIP switches, then -dip-config's,
+// then -dip's
+func setupDIPSwitches(m *machine.Machine, manifestSettings map[string]string) error {
+	if err := m.SetDIPSwitches(manifestSettings); err != nil {
+		return fmt.Errorf("manifest: %v", err)
+	}
+	if *dipConfig != "" {
+		settings, err := machine.LoadDIPSettings(*dipConfig)
+		if err != nil {
+			return err
+		}
+		if err := m.SetDIPSwitches(settings); err != nil {
+			return fmt.Errorf("%s: %v", *dipConfig, err)
 		}
-		time.Sleep(sleepTime)
 	}
+	settings, err := machine.ParseDIPSettings(*dipFlag)
+	if err != nil {
+		return fmt.Errorf("-dip: %v", err)
+	}
+	return m.SetDIPSwitches(settings)
 }
 
-func dumpCoreToFile(filename string, memory *intel8080.Memory) error {
-	f, err := os.OpenFile(filename, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+// runHeadless runs -frames frames without a display, following -script and
+// writing the frames listed in -png-at to PNG files
+func runHeadless(m *machine.Machine, overlay *display.Overlay) error {
+	c, err := newCapture(m)
+	if err != nil {
+		return err
+	}
+	opts := machine.HeadlessOptions{Frames: *frames, Overlay: overlay, CaptureAll: c.recording(), CaptureLast: true}
+	if *scriptPath != "" {
+		script, err := machine.LoadScript(*scriptPath)
+		if err != nil {
+			return err
+		}
+		opts.Script = script
+	}
+	for frame := range c.pngAt {
+		opts.Capture = append(opts.Capture, frame)
+	}
+
+	f, err := newFilters(*filterSpec)
 	if err != nil {
-		return fmt.Errorf("failed to open file: %v", err)
+		return err
 	}
-	defer f.Close()
+	// Stateful filters need to see every frame
+	opts.CaptureAll = opts.CaptureAll || len(f.Pipeline) > 0
 
-	_, err = f.Write(memory.GetMemoryCopy())
+	var last *display.Framebuffer
+	// A capture that can't be written stops the run, but is no crash
+	var ioErr error
+	err = m.RunHeadless(opts, func(frame uint64, fb *display.Framebuffer) error {
+		last = f.Apply(fb)
+		ioErr = c.frame(frame, last, m.Audio())
+		return ioErr
+	})
+	if ioErr != nil {
+		return fmt.Errorf("I/O error: %v", ioErr)
+	}
+	var desync *machine.DesyncError
+	if errors.As(err, &desync) {
+		// The replay went its own way: the CPU is fine
+		return fmt.Errorf("%s: %v", *playMovie, err)
+	}
 	if err != nil {
-		return fmt.Errorf("writing to file failed: %v", err)
+		writeCrashBundle(m.CPU, err)
+		return err
+	}
+	if last == nil {
+		return nil
+	}
+	if err := c.close(m.Frame, last); err != nil {
+		return err
+	}
+	if *goldenPath != "" {
+		want, err := display.LoadPNG(*goldenPath)
+		if err != nil {
+			return err
+		}
+		if n := display.Diff(last, want); n != 0 {
+			return fmt.Errorf("frame %d does not match %s (%d pixels differ, -1 = size)", m.Frame, *goldenPath, n)
+		}
+		fmt.Printf("frame %d matches %s\n", m.Frame, *goldenPath)
 	}
 	return nil
 }
+
+// runFrameCatchingPanics turns a panic escaping the emulator into an error so
+// it can be reported in a crash bundle
+func runFrameCatchingPanics(m *machine.Machine) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return m.RunFrame()
+}
+
+func writeCrashBundle(cpu *intel8080.CPU, cause error) {
+	filename := fmt.Sprintf("crash-%s.json", time.Now().Format("20060102-150405"))
+	if err := intel8080.WriteCrashBundle(filename, cpu.CrashBundle(cause)); err != nil {
+		fmt.Printf("Failed writing crash bundle: %v\n", err)
+		return
+	}
+	fmt.Printf("Crash bundle written to %s (inspect with -replay-crash=%s)\n", filename, filename)
+}
+
+func runReplayCrash(filename string) error {
+	bundle, err := intel8080.ReadCrashBundle(filename)
+	if err != nil {
+		return err
+	}
+	crashed, err := bundle.Machine()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Crash at %s after %d cycles: %s\n", bundle.Time.Format(time.RFC3339), bundle.Cycles, bundle.Error)
+	return intel8080.RunMonitor(crashed, os.Stdin, os.Stdout)
+}
+
+func runDumpTool() error {
+	if *dumpDiff != "" {
+		files := strings.Split(*dumpDiff, ",")
+		if len(files) != 2 {
+			return fmt.Errorf("-dump-diff expects two files: old.dump,new.dump")
+		}
+		a, err := intel8080.ReadDumpFile(files[0])
+		if err != nil {
+			return err
+		}
+		b, err := intel8080.ReadDumpFile(files[1])
+		if err != nil {
+			return err
+		}
+		return intel8080.WriteDiff(os.Stdout, a, b)
+	}
+
+	d, err := intel8080.ReadDumpFile(*dumpExport)
+	if err != nil {
+		return err
+	}
+	var start, end int
+	if _, err := fmt.Sscanf(*dumpRange, "%x-%x", &start, &end); err != nil {
+		return fmt.Errorf("bad -range %q: %v", *dumpRange, err)
+	}
+	switch *dumpFormat {
+	case "hex":
+		return intel8080.WriteHexdump(os.Stdout, d.Memory, start, end+1)
+	case "ihex":
+		return intel8080.WriteIntelHex(os.Stdout, d.Memory, start, end+1)
+	}
+	return fmt.Errorf("unknown -format %q", *dumpFormat)
+}
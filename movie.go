@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"intel8080/display"
+	"intel8080/machine"
+	"intel8080/tas"
+)
+
+// startMovie starts -record-movie or -play-movie. The function it returns
+// finishes them, writing the recording or checking the replay got to the end.
+func startMovie(m *machine.Machine) (func() error, error) {
+	switch {
+	case *recordMovie != "" && *playMovie != "":
+		return nil, fmt.Errorf("-record-movie and -play-movie can't be combined")
+	case *recordMovie != "":
+		r, err := m.RecordMovie()
+		if err != nil {
+			return nil, err
+		}
+		return func() error {
+			movie := r.Stop()
+			if err := machine.WriteMovie(*recordMovie, movie); err != nil {
+				return err
+			}
+			fmt.Printf("%d frames recorded to %s\n", movie.Frames(), *recordMovie)
+			return nil
+		}, nil
+	case *playMovie != "":
+		if !*headless {
+			return nil, fmt.Errorf("-play-movie needs -headless (record video with -gif or -y4m)")
+		}
+		if *scriptPath != "" {
+			return nil, fmt.Errorf("-play-movie and -script can't be combined")
+		}
+		movie, err := machine.ReadMovie(*playMovie)
+		if err != nil {
+			return nil, err
+		}
+		p, err := m.PlayMovie(movie)
+		if err != nil {
+			return nil, err
+		}
+		if !flagSet("frames") {
+			*frames = movie.Frames()
+		}
+		return func() error {
+			if p.Done() {
+				fmt.Printf("%d frames of %s replayed in sync\n", movie.Frames(), *playMovie)
+			}
+			return nil
+		}, nil
+	}
+	return func() error { return nil }, nil
+}
+
+// runTAS edits -tas in the movie editor on stdin
+func runTAS(m *machine.Machine, overlay *display.Overlay) error {
+	var movie *machine.Movie
+	if _, err := os.Stat(*tasPath); os.IsNotExist(err) {
+		// Start an empty movie from power-on
+		r, err := m.RecordMovie()
+		if err != nil {
+			return err
+		}
+		movie = r.Stop()
+	} else if movie, err = machine.ReadMovie(*tasPath); err != nil {
+		return err
+	}
+	e, err := tas.NewEditor(m, movie)
+	if err != nil {
+		return err
+	}
+	save := func(mv *machine.Movie) error {
+		return machine.WriteMovie(*tasPath, mv)
+	}
+	return tas.RunEditor(e, overlay, save, os.Stdin, os.Stdout)
+}
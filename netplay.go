@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"intel8080/input"
+	"intel8080/machine"
+	"intel8080/netplay"
+)
+
+// netplayBlocked are the actions that would take one peer's machine away
+// from the other's
+var netplayBlocked = map[input.Action]bool{
+	input.ActionPause:        true,
+	input.ActionFrameAdvance: true,
+	input.ActionFastForward:  true,
+	input.ActionSlowMotion:   true,
+	input.ActionSpeedDown:    true,
+	input.ActionSpeedUp:      true,
+	input.ActionSaveState:    true,
+	input.ActionLoadState:    true,
+	input.ActionDIPChange:    true,
+}
+
+// stallNotice is how many frames in a row waiting for the peer (three
+// seconds) are worth mentioning
+const stallNotice = 180
+
+// netPeer runs frames through a netplay session. The local player's inputs
+// are held for the session instead of pressed on the machine.
+type netPeer struct {
+	session *netplay.Session
+	held    netplay.Input
+	// stalled counts the frames in a row spent waiting for the peer
+	stalled uint64
+}
+
+// startNetplay connects as -netplay-listen or -netplay-connect say, or
+// returns nil without either
+func startNetplay(m *machine.Machine) (*netPeer, error) {
+	if *netplayListen == "" && *netplayConnect == "" {
+		return nil, nil
+	}
+	switch {
+	case *netplayListen != "" && *netplayConnect != "":
+		return nil, fmt.Errorf("-netplay-listen and -netplay-connect can't be combined")
+	case *headless:
+		return nil, fmt.Errorf("netplay needs a display (-display=sdl or terminal)")
+	case *recordMovie != "":
+		return nil, fmt.Errorf("netplay games can't be recorded with -record-movie")
+	}
+	var conn netplay.Conn
+	player := 0
+	if *netplayListen != "" {
+		c, err := netplay.Listen(*netplayListen)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Printf("netplay: waiting for player 2 on %s\n", c.LocalAddr())
+		conn = c
+	} else {
+		c, err := netplay.Dial(*netplayConnect)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Printf("netplay: joining %s as player 2\n", *netplayConnect)
+		conn, player = c, 1
+	}
+	if *netplayLatency > 0 || *netplayLoss > 0 {
+		conn = netplay.NewLossy(conn, *netplayLatency, *netplayLoss, time.Now().UnixNano())
+	}
+	s, err := netplay.NewSession(m, conn, player)
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	s.Delay = *netplayDelay
+	return &netPeer{session: s}, nil
+}
+
+// press holds or releases a cabinet input of the local player
+func (p *netPeer) press(in machine.Input, pressed bool) {
+	p.held.Set(in, pressed)
+}
+
+// runFrame advances the session, reporting whether a frame ran. A panic
+// escaping the emulator is returned as a *netplay.FrameError, like the
+// machine's own faults.
+func (p *netPeer) runFrame() (ran bool, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = &netplay.FrameError{Frame: p.session.Frame(), Err: fmt.Errorf("panic: %v", r)}
+		}
+	}()
+	connected := p.session.Connected()
+	ran, err = p.session.Advance(p.held)
+	if !connected && p.session.Connected() {
+		fmt.Println("netplay: peer connected")
+	}
+	if ran {
+		p.stalled = 0
+	} else if p.stalled++; p.stalled == stallNotice && p.session.Connected() {
+		fmt.Println("netplay: waiting for the peer")
+	}
+	return ran, err
+}
+
+// blocked reports whether action is unavailable during netplay, saying so
+// when it is pressed
+func (p *netPeer) blocked(action input.Action, pressed bool) bool {
+	if !netplayBlocked[action] {
+		return false
+	}
+	if pressed {
+		fmt.Printf("%s is not available during netplay\n", action)
+	}
+	return true
+}
+
+// close closes the connection and shows how the session went
+func (p *netPeer) close() {
+	s := p.session
+	fmt.Printf("netplay: %d frames, %d rollbacks (%d frames run again), %d frames waiting\n",
+		s.Frame(), s.Rollbacks, s.Resimulated, s.Stalls)
+	_ = s.Close()
+}
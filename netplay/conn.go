@@ -0,0 +1,216 @@
+package netplay
+
+import (
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+)
+
+// Conn carries packets between the two peers. Packets may be lost, delayed
+// or reordered; the session copes.
+type Conn interface {
+	Send(packet []byte) error
+	// Receive returns the next packet waiting, or nil if there is none. It
+	// never blocks.
+	Receive() ([]byte, error)
+	Close() error
+}
+
+// maxPacket is larger than any packet the session sends
+const maxPacket = 1024
+
+// UDPConn is a Conn over UDP
+type UDPConn struct {
+	conn *net.UDPConn
+
+	mu      sync.Mutex
+	remote  *net.UDPAddr
+	packets chan []byte
+	err     error
+}
+
+// Listen waits for a peer on addr (such as ":7000"). The peer's address is
+// learnt from the first netplay packet received; nothing is sent before then.
+func Listen(addr string) (*UDPConn, error) {
+	local, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp", local)
+	if err != nil {
+		return nil, err
+	}
+	return newUDPConn(conn, nil), nil
+}
+
+// Dial sends to the peer listening on addr (such as "host:7000")
+func Dial(addr string) (*UDPConn, error) {
+	remote, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp", nil)
+	if err != nil {
+		return nil, err
+	}
+	return newUDPConn(conn, remote), nil
+}
+
+func newUDPConn(conn *net.UDPConn, remote *net.UDPAddr) *UDPConn {
+	c := &UDPConn{conn: conn, remote: remote, packets: make(chan []byte, 256)}
+	go c.read()
+	return c
+}
+
+// read queues incoming packets from the peer, dropping them if the session
+// falls behind, as the network would
+func (c *UDPConn) read() {
+	buf := make([]byte, maxPacket)
+	for {
+		n, from, err := c.conn.ReadFromUDP(buf)
+		if err != nil {
+			c.mu.Lock()
+			c.err = err
+			c.mu.Unlock()
+			close(c.packets)
+			return
+		}
+		c.mu.Lock()
+		if c.remote == nil {
+			// Only a netplay packet makes its sender the peer, so a stray
+			// datagram on the port can't take the session
+			if _, err := decodePacket(buf[:n]); err != nil {
+				c.mu.Unlock()
+				continue
+			}
+			c.remote = from
+		}
+		ours := c.remote.IP.Equal(from.IP) && c.remote.Port == from.Port
+		c.mu.Unlock()
+		if !ours {
+			continue
+		}
+		select {
+		case c.packets <- append([]byte(nil), buf[:n]...):
+		default:
+		}
+	}
+}
+
+// LocalAddr returns the address the connection receives on
+func (c *UDPConn) LocalAddr() net.Addr {
+	return c.conn.LocalAddr()
+}
+
+func (c *UDPConn) Send(packet []byte) error {
+	c.mu.Lock()
+	remote := c.remote
+	c.mu.Unlock()
+	if remote == nil {
+		return nil
+	}
+	_, err := c.conn.WriteToUDP(packet, remote)
+	return err
+}
+
+func (c *UDPConn) Receive() ([]byte, error) {
+	select {
+	case p, ok := <-c.packets:
+		if !ok {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+			return nil, c.err
+		}
+		return p, nil
+	default:
+		return nil, nil
+	}
+}
+
+func (c *UDPConn) Close() error {
+	return c.conn.Close()
+}
+
+// pipeConn is one end of a Pipe
+type pipeConn struct {
+	in, out chan []byte
+}
+
+// Pipe returns two connected Conns in memory, for testing sessions without
+// a network
+func Pipe() (Conn, Conn) {
+	a, b := make(chan []byte, 1024), make(chan []byte, 1024)
+	return &pipeConn{in: a, out: b}, &pipeConn{in: b, out: a}
+}
+
+func (c *pipeConn) Send(packet []byte) error {
+	select {
+	case c.out <- append([]byte(nil), packet...):
+	default:
+	}
+	return nil
+}
+
+func (c *pipeConn) Receive() ([]byte, error) {
+	select {
+	case p := <-c.in:
+		return p, nil
+	default:
+		return nil, nil
+	}
+}
+
+func (c *pipeConn) Close() error {
+	return nil
+}
+
+// Lossy wraps a Conn to simulate a bad network: sent packets are delayed by
+// Latency and a fraction Loss of them (0 to 1) is dropped. Delayed packets
+// go out on later calls to Send or Receive.
+type Lossy struct {
+	Conn
+	Latency time.Duration
+	Loss    float64
+	// Now is the clock, time.Now unless set (tests drive their own)
+	Now func() time.Time
+
+	rand    *rand.Rand
+	pending []delayedPacket
+}
+
+type delayedPacket struct {
+	at     time.Time
+	packet []byte
+}
+
+// NewLossy wraps c; seed makes the losses repeatable
+func NewLossy(c Conn, latency time.Duration, loss float64, seed int64) *Lossy {
+	return &Lossy{Conn: c, Latency: latency, Loss: loss, Now: time.Now, rand: rand.New(rand.NewSource(seed))}
+}
+
+func (l *Lossy) Send(packet []byte) error {
+	if l.rand.Float64() >= l.Loss {
+		l.pending = append(l.pending, delayedPacket{at: l.Now().Add(l.Latency), packet: append([]byte(nil), packet...)})
+	}
+	return l.flush()
+}
+
+func (l *Lossy) Receive() ([]byte, error) {
+	if err := l.flush(); err != nil {
+		return nil, err
+	}
+	return l.Conn.Receive()
+}
+
+// flush sends the delayed packets that are due
+func (l *Lossy) flush() error {
+	now := l.Now()
+	for len(l.pending) > 0 && !l.pending[0].at.After(now) {
+		if err := l.Conn.Send(l.pending[0].packet); err != nil {
+			return err
+		}
+		l.pending = l.pending[1:]
+	}
+	return nil
+}
@@ -0,0 +1,459 @@
+// Package netplay runs two-player games across two machines with rollback.
+// Each peer runs its own emulator and sends its inputs to the other every
+// frame. A peer never waits for the remote inputs of a frame: it predicts
+// them (the remote player holds whatever they held last) and runs on. When
+// the real inputs arrive and differ, it restores the snapshot taken before
+// the first wrong frame and runs the frames again, so both peers see the
+// same game. Peers exchange state hashes of frames whose inputs are known to
+// both, which catches desyncs.
+package netplay
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"intel8080/machine"
+)
+
+// Input holds the control bits of IN ports 1 and 2 held through a frame.
+// DIP switches are set before the session and never change in it.
+type Input [2]byte
+
+// controlMask is the bits of ports 1 and 2 that are player controls
+var controlMask = Input{0x77, 0x74}
+
+// Held reports whether in is held
+func (i Input) Held(in machine.Input) bool {
+	return in.Port >= 1 && in.Port <= 2 && i[in.Port-1]&(1<<in.Bit) != 0
+}
+
+// Set holds or releases in. Inputs that are not controls are ignored.
+func (i *Input) Set(in machine.Input, held bool) {
+	if in.Port < 1 || in.Port > 2 {
+		return
+	}
+	bit := byte(1<<in.Bit) & controlMask[in.Port-1]
+	if held {
+		i[in.Port-1] |= bit
+	} else {
+		i[in.Port-1] &^= bit
+	}
+}
+
+// asPlayer2 moves player 1's start button to player 2's, and on a cocktail
+// cabinet the player 1 controls to player 2's. Upright cabinets have one
+// set of controls, shared by the players in turn.
+func asPlayer2(i Input, cocktail bool) Input {
+	out := Input{i[0] &^ 0x04, i[1]}
+	if i[0]&0x04 != 0 {
+		out[0] |= 0x02
+	}
+	if cocktail {
+		out[1] |= i[0] & 0x70
+		out[0] &^= 0x70
+	}
+	return out
+}
+
+const (
+	// DefaultDelay is the input delay in frames. Local inputs take effect
+	// this many frames after they are given, which hides that much latency
+	// without rolling back.
+	DefaultDelay = 2
+	// DefaultMaxRollback is how far a peer runs ahead of the last frame
+	// whose remote inputs it has, before waiting for them
+	DefaultMaxRollback = 8
+	// syncInterval is the fewest frames between waits to let the peer
+	// catch up
+	syncInterval = 20
+)
+
+// DesyncError reports peers whose machines differ after the same inputs
+type DesyncError struct {
+	Frame         uint64
+	Local, Remote uint64
+}
+
+func (e *DesyncError) Error() string {
+	return fmt.Sprintf("netplay desync after frame %d: state hash %016x, peer has %016x", e.Frame, e.Local, e.Remote)
+}
+
+// FrameError is the machine failing to run a frame, as opposed to the
+// session failing
+type FrameError struct {
+	Frame uint64
+	Err   error
+}
+
+func (e *FrameError) Error() string {
+	return fmt.Sprintf("frame %d: %v", e.Frame, e.Err)
+}
+
+func (e *FrameError) Unwrap() error {
+	return e.Err
+}
+
+// Session is one peer of a netplay game. Frames are counted from the start
+// of the session.
+type Session struct {
+	m      *machine.Machine
+	conn   Conn
+	player int
+	start  uint64
+
+	// Delay and MaxRollback may be changed before the first Advance
+	Delay, MaxRollback uint64
+
+	// frame is the number of frames run
+	frame uint64
+	// local and remote are the inputs of each frame, as far as known; used
+	// is the remote input each frame ran with
+	local, remote, used []Input
+	// states holds the state before frame n, from the first frame that may
+	// still be rolled back
+	states map[uint64]*machine.State
+	// hashes holds the hash of the state after frame n, for the frames
+	// whose inputs are final; remoteHashes the peer's, until compared
+	hashes       []uint64
+	remoteHashes map[uint64]uint64
+
+	connected       bool
+	remoteAck       uint64
+	remoteFrame     uint64
+	remoteAdvantage int64
+	lastSync        uint64
+
+	// Rollbacks counts the mispredictions, Resimulated the frames run again
+	// for them and Stalls the frames spent waiting for the peer
+	Rollbacks, Resimulated, Stalls uint64
+}
+
+// NewSession starts a session on m for player (0 or 1) over conn. Both
+// peers must start from the same state: the same ROM, DIP switches and
+// cabinet, usually at power on.
+func NewSession(m *machine.Machine, conn Conn, player int) (*Session, error) {
+	if player != 0 && player != 1 {
+		return nil, fmt.Errorf("netplay: player must be 0 or 1, not %d", player)
+	}
+	return &Session{
+		m:            m,
+		conn:         conn,
+		player:       player,
+		start:        m.Snapshot().Hash(),
+		Delay:        DefaultDelay,
+		MaxRollback:  DefaultMaxRollback,
+		states:       map[uint64]*machine.State{},
+		remoteHashes: map[uint64]uint64{},
+	}, nil
+}
+
+// Frame returns the number of frames run
+func (s *Session) Frame() uint64 {
+	return s.frame
+}
+
+// Connected reports whether anything has been heard from the peer
+func (s *Session) Connected() bool {
+	return s.connected
+}
+
+// Confirmed returns the number of frames whose inputs are final on this peer
+func (s *Session) Confirmed() uint64 {
+	return uint64(len(s.hashes))
+}
+
+// Hash returns the hash of the state after frame n, once its inputs are final
+func (s *Session) Hash(n uint64) (uint64, bool) {
+	if n >= uint64(len(s.hashes)) {
+		return 0, false
+	}
+	return s.hashes[n], true
+}
+
+// Close closes the connection
+func (s *Session) Close() error {
+	return s.conn.Close()
+}
+
+// Advance exchanges inputs with the peer, rolls back if a prediction was
+// wrong and runs the next frame with local held by this peer's player.
+// local is given in player 1's controls; player 2's session moves them to
+// player 2's. Advance returns false without running a frame when it has to
+// wait for the peer, either because it is too far ahead of the remote
+// inputs or to let the peer catch up; local is dropped then.
+func (s *Session) Advance(local Input) (bool, error) {
+	if err := s.receive(); err != nil {
+		return false, err
+	}
+	if err := s.confirm(); err != nil {
+		return false, err
+	}
+	if s.wait() {
+		s.Stalls++
+		return false, s.send()
+	}
+
+	for uint64(len(s.local)) < s.Delay {
+		s.local = append(s.local, Input{})
+	}
+	local[0] &= controlMask[0]
+	local[1] &= controlMask[1]
+	if s.player == 1 {
+		local = asPlayer2(local, s.m.IOBus.Cocktail)
+	}
+	s.local = append(s.local, local)
+	if err := s.run(s.frame, false); err != nil {
+		return false, err
+	}
+	s.frame++
+	return true, s.send()
+}
+
+// wait reports whether to hold off running the next frame
+func (s *Session) wait() bool {
+	if s.frame >= uint64(len(s.remote))+s.MaxRollback {
+		return true
+	}
+	// Both peers measure how far they are ahead of the other with the same
+	// latency, so the difference is twice how far this one is really ahead
+	if s.connected && s.frame >= s.lastSync+syncInterval {
+		advantage := int64(s.frame) - int64(s.remoteFrame)
+		if advantage-s.remoteAdvantage >= 2 {
+			s.lastSync = s.frame
+			return true
+		}
+	}
+	return false
+}
+
+// run runs frame n with the local input and the remote one, predicted if
+// not known yet, keeping the state before it for rollbacks. Frames run again
+// are muted: their sound was played the first time.
+func (s *Session) run(n uint64, again bool) error {
+	s.states[n] = s.m.Snapshot()
+	remote := s.remoteInput(n)
+	if n < uint64(len(s.used)) {
+		s.used[n] = remote
+	} else {
+		s.used = append(s.used, remote)
+	}
+	held := Input{s.local[n][0] | remote[0], s.local[n][1] | remote[1]}
+	for port := range held {
+		current := s.m.IOBus.InputBits(uint8(port + 1))
+		for bit := uint8(0); bit < 8; bit++ {
+			if controlMask[port]&(held[port]^current)&(1<<bit) != 0 {
+				s.m.IOBus.HandleInput(uint8(port+1), bit, held[port]&(1<<bit) != 0)
+			}
+		}
+	}
+	run := s.m.RunFrame
+	if again {
+		run = s.m.RunFrameMuted
+	}
+	if err := run(); err != nil {
+		return &FrameError{Frame: n, Err: err}
+	}
+	return nil
+}
+
+// remoteInput returns the remote input of frame n, or the prediction for it
+func (s *Session) remoteInput(n uint64) Input {
+	if n < uint64(len(s.remote)) {
+		return s.remote[n]
+	}
+	if len(s.remote) > 0 {
+		return s.remote[len(s.remote)-1]
+	}
+	return Input{}
+}
+
+// receive reads the waiting packets, then rolls back to the first frame
+// that ran with a wrong prediction and runs the frames since again
+func (s *Session) receive() error {
+	rollback := s.frame
+	for {
+		data, err := s.conn.Receive()
+		if err != nil {
+			return err
+		}
+		if data == nil {
+			break
+		}
+		p, err := decodePacket(data)
+		if err != nil {
+			// Not a packet of ours: ignore it, as UDP junk
+			continue
+		}
+		if p.player == s.player {
+			return fmt.Errorf("netplay: both peers are player %d", s.player+1)
+		}
+		if p.start != s.start {
+			return errors.New("netplay: the peers started from different states (check the ROM, DIP switches and cabinet)")
+		}
+		s.connected = true
+		if p.ack > s.remoteAck {
+			s.remoteAck = p.ack
+		}
+		if p.frame > s.remoteFrame {
+			s.remoteFrame, s.remoteAdvantage = p.frame, p.advantage
+		}
+		for i, in := range p.inputs {
+			n := p.first + uint64(i)
+			if n != uint64(len(s.remote)) {
+				continue
+			}
+			s.remote = append(s.remote, in)
+			if n < s.frame && s.used[n] != in && n < rollback {
+				rollback = n
+			}
+		}
+		if p.hashFrame > 0 {
+			s.remoteHashes[p.hashFrame-1] = p.hash
+		}
+	}
+	if rollback == s.frame {
+		return nil
+	}
+	s.Rollbacks++
+	if err := s.m.Restore(s.states[rollback]); err != nil {
+		return err
+	}
+	for n := rollback; n < s.frame; n++ {
+		if err := s.run(n, true); err != nil {
+			return err
+		}
+		s.Resimulated++
+	}
+	// Sounds the wrong prediction started or stopped follow the right one
+	s.m.SyncSound()
+	return nil
+}
+
+// confirm hashes the frames whose inputs became final, checks them against
+// the peer's hashes and drops the states that can no longer be rolled back
+func (s *Session) confirm() error {
+	final := s.frame
+	if known := uint64(len(s.remote)); known < final {
+		final = known
+	}
+	for n := uint64(len(s.hashes)); n < final; n++ {
+		after := s.states[n+1]
+		if n+1 == s.frame {
+			after = s.m.Snapshot()
+		}
+		s.hashes = append(s.hashes, after.Hash())
+	}
+	for n, remote := range s.remoteHashes {
+		if n >= uint64(len(s.hashes)) {
+			continue
+		}
+		delete(s.remoteHashes, n)
+		if local := s.hashes[n]; local != remote {
+			return &DesyncError{Frame: n + 1, Local: local, Remote: remote}
+		}
+	}
+	for n := range s.states {
+		if n < final {
+			delete(s.states, n)
+		}
+	}
+	return nil
+}
+
+// maxInputs is the most inputs sent in a packet
+const maxInputs = 64
+
+// send sends the local inputs the peer hasn't acknowledged, so a lost packet
+// is made up for by the next one, with the hash of the last final frame
+func (s *Session) send() error {
+	p := packet{
+		player:    s.player,
+		start:     s.start,
+		ack:       uint64(len(s.remote)),
+		frame:     s.frame,
+		advantage: int64(s.frame) - int64(s.remoteFrame),
+		first:     s.remoteAck,
+	}
+	if !s.connected {
+		p.advantage = 0
+	}
+	if n := uint64(len(s.hashes)); n > 0 {
+		p.hashFrame, p.hash = n, s.hashes[n-1]
+	}
+	if p.first < uint64(len(s.local)) {
+		p.inputs = s.local[p.first:]
+		if len(p.inputs) > maxInputs {
+			p.inputs = p.inputs[:maxInputs]
+		}
+	}
+	return s.conn.Send(p.encode())
+}
+
+// packet is what the peers send each other every frame. All fields are
+// little endian:
+//
+//	0   "NP", version, player
+//	4   start state hash (8 bytes)
+//	12  remote inputs received (4 bytes)
+//	16  frames run (4 bytes), frames ahead of the peer (4 bytes, signed)
+//	24  frames with a hash (4 bytes), hash of the state after the last (8 bytes)
+//	36  frame of the first input (4 bytes), number of inputs (1 byte)
+//	41  inputs, 2 bytes each
+type packet struct {
+	player    int
+	start     uint64
+	ack       uint64
+	frame     uint64
+	advantage int64
+	hashFrame uint64
+	hash      uint64
+	first     uint64
+	inputs    []Input
+}
+
+const (
+	packetVersion = 1
+	headerSize    = 41
+)
+
+func (p *packet) encode() []byte {
+	b := make([]byte, headerSize, headerSize+2*len(p.inputs))
+	b[0], b[1], b[2], b[3] = 'N', 'P', packetVersion, byte(p.player)
+	binary.LittleEndian.PutUint64(b[4:], p.start)
+	binary.LittleEndian.PutUint32(b[12:], uint32(p.ack))
+	binary.LittleEndian.PutUint32(b[16:], uint32(p.frame))
+	binary.LittleEndian.PutUint32(b[20:], uint32(int32(p.advantage)))
+	binary.LittleEndian.PutUint32(b[24:], uint32(p.hashFrame))
+	binary.LittleEndian.PutUint64(b[28:], p.hash)
+	binary.LittleEndian.PutUint32(b[36:], uint32(p.first))
+	b[40] = byte(len(p.inputs))
+	for _, in := range p.inputs {
+		b = append(b, in[0], in[1])
+	}
+	return b
+}
+
+func decodePacket(b []byte) (*packet, error) {
+	if len(b) < headerSize || b[0] != 'N' || b[1] != 'P' || b[2] != packetVersion {
+		return nil, errors.New("not a netplay packet")
+	}
+	p := &packet{
+		player:    int(b[3]),
+		start:     binary.LittleEndian.Uint64(b[4:]),
+		ack:       uint64(binary.LittleEndian.Uint32(b[12:])),
+		frame:     uint64(binary.LittleEndian.Uint32(b[16:])),
+		advantage: int64(int32(binary.LittleEndian.Uint32(b[20:]))),
+		hashFrame: uint64(binary.LittleEndian.Uint32(b[24:])),
+		hash:      binary.LittleEndian.Uint64(b[28:]),
+		first:     uint64(binary.LittleEndian.Uint32(b[36:])),
+	}
+	n := int(b[40])
+	if len(b) != headerSize+2*n {
+		return nil, errors.New("truncated netplay packet")
+	}
+	for i := 0; i < n; i++ {
+		p.inputs = append(p.inputs, Input{b[headerSize+2*i], b[headerSize+2*i+1]})
+	}
+	return p, nil
+}
@@ -0,0 +1,343 @@
+package netplay
+
+import (
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"intel8080/intel8080"
+	"intel8080/machine"
+)
+
+// bothPortsProgram adds ports 1 and 2 to a running total at 0x2000 and shows
+// it in VRAM, so the state depends on the frame every input lands on
+var bothPortsProgram = []byte{
+	0xDB, 0x01, // IN 1
+	0x47,       // MOV B,A
+	0xDB, 0x02, // IN 2
+	0x80,             // ADD B
+	0x4F,             // MOV C,A
+	0x3A, 0x00, 0x20, // LDA 0x2000
+	0x81,             // ADD C
+	0x32, 0x00, 0x20, // STA 0x2000
+	0x32, 0x00, 0x24, // STA 0x2400
+	0xC3, 0x00, 0x00, // JMP 0x0000
+}
+
+// soundProgram turns the shot sound on and off every 16 frames from its
+// vblank handler, while its main loop adds ports 1 and 2 to a total as
+// bothPortsProgram does. Its sound doesn't depend on the inputs.
+var soundProgram = map[uint16][]byte{
+	0x00: {0xC3, 0x40, 0x00}, // JMP 0x0040
+	0x08: {0xFB, 0xC9},       // RST 1: EI, RET
+	0x10: {
+		0xF5,             // RST 2: PUSH PSW
+		0x3A, 0x01, 0x20, // LDA 0x2001
+		0x3C,             // INR A
+		0x32, 0x01, 0x20, // STA 0x2001
+		0xE6, 0x10, // ANI 0x10
+		0x0F, 0x0F, 0x0F, // RRC x3
+		0xF6, 0x20, // ORI 0x20 (amplifier on)
+		0xD3, 0x03, // OUT 3
+		0xF1, // POP PSW
+		0xFB, // EI
+		0xC9, // RET
+	},
+	0x40: {
+		0x31, 0x00, 0x24, // LXI SP,0x2400
+		0xFB,       // EI
+		0xDB, 0x01, // IN 1
+		0x47,       // MOV B,A
+		0xDB, 0x02, // IN 2
+		0x80,             // ADD B
+		0x4F,             // MOV C,A
+		0x3A, 0x00, 0x20, // LDA 0x2000
+		0x81,             // ADD C
+		0x32, 0x00, 0x20, // STA 0x2000
+		0xC3, 0x44, 0x00, // JMP 0x0044
+	},
+}
+
+func newTestMachine(t *testing.T) *machine.Machine {
+	return newProgramMachine(t, map[uint16][]byte{0: bothPortsProgram})
+}
+
+// newSoundMachine runs soundProgram with the synthesized sound board
+func newSoundMachine(t *testing.T) *machine.Machine {
+	m := newProgramMachine(t, soundProgram)
+	m.SetSound(machine.NewSynthBoard(testSampleRate), testSampleRate)
+	return m
+}
+
+const testSampleRate = 48000
+
+func newProgramMachine(t *testing.T, program map[uint16][]byte) *machine.Machine {
+	memory := intel8080.NewMemory(0x4000)
+	for at, code := range program {
+		for i, b := range code {
+			if err := memory.Write(at+uint16(i), b); err != nil {
+				t.Fatalf("loading program failed: %v\n", err)
+			}
+		}
+	}
+	ioBus := intel8080.NewIOBus()
+	return machine.New(intel8080.NewCPU(ioBus, memory), memory, ioBus)
+}
+
+// script returns what player holds on frame n: fire and left in bursts that
+// differ between the players, so predictions keep going wrong
+func script(player int, n uint64) Input {
+	var in Input
+	in.Set(machine.Inputs["p1fire"], (n/7+uint64(player))%3 == 0)
+	in.Set(machine.Inputs["p1left"], player == 1 && n%11 < 4)
+	return in
+}
+
+// reference runs frames offline on m with both players' inputs where the
+// session puts them, returning the hash and the audio of each frame
+func reference(t *testing.T, m *machine.Machine, frames uint64) ([]uint64, [][]int16) {
+	var hashes []uint64
+	var sound [][]int16
+	for n := uint64(0); n < frames; n++ {
+		var held Input
+		if n >= DefaultDelay {
+			p1, p2 := script(0, n-DefaultDelay), asPlayer2(script(1, n-DefaultDelay), false)
+			held = Input{p1[0] | p2[0], p1[1] | p2[1]}
+		}
+		for port := range held {
+			for bit := uint8(0); bit < 8; bit++ {
+				if controlMask[port]&(1<<bit) != 0 {
+					m.IOBus.HandleInput(uint8(port+1), bit, held[port]&(1<<bit) != 0)
+				}
+			}
+		}
+		if err := m.RunFrame(); err != nil {
+			t.Fatalf("RunFrame failed: %v\n", err)
+		}
+		hashes = append(hashes, m.Snapshot().Hash())
+		sound = append(sound, append([]int16(nil), m.Audio()...))
+	}
+	return hashes, sound
+}
+
+// clock is a fake clock for Lossy, advanced a frame at a time
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	return c.now
+}
+
+func newTestSessions(t *testing.T, a, b Conn) [2]*Session {
+	return newSessionsOn(t, newTestMachine(t), newTestMachine(t), a, b)
+}
+
+func newSessionsOn(t *testing.T, ma, mb *machine.Machine, a, b Conn) [2]*Session {
+	var sessions [2]*Session
+	for player, conn := range []Conn{a, b} {
+		s, err := NewSession([]*machine.Machine{ma, mb}[player], conn, player)
+		if err != nil {
+			t.Fatalf("NewSession failed: %v\n", err)
+		}
+		sessions[player] = s
+	}
+	return sessions
+}
+
+// tick advances each session once with its script
+func tick(sessions [2]*Session) error {
+	for player, s := range sessions {
+		if _, err := s.Advance(script(player, s.Frame())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestSessionOverLossyLink(t *testing.T) {
+	const frames = 240
+	c := &clock{now: time.Unix(0, 0)}
+	a, b := Pipe()
+	// 50ms each way is three frames, beyond the input delay
+	lossyA, lossyB := NewLossy(a, 50*time.Millisecond, 0.2, 1), NewLossy(b, 50*time.Millisecond, 0.2, 2)
+	lossyA.Now, lossyB.Now = c.Now, c.Now
+	sessions := newTestSessions(t, lossyA, lossyB)
+
+	for i := 0; sessions[0].Confirmed() < frames || sessions[1].Confirmed() < frames; i++ {
+		if i > 10*frames {
+			t.Fatalf("sessions stuck at %d and %d confirmed frames\n", sessions[0].Confirmed(), sessions[1].Confirmed())
+		}
+		if err := tick(sessions); err != nil {
+			t.Fatalf("Advance failed: %v\n", err)
+		}
+		c.now = c.now.Add(time.Second / 60)
+	}
+
+	want, _ := reference(t, newTestMachine(t), frames)
+	for player, s := range sessions {
+		got := make([]uint64, frames)
+		for n := range got {
+			got[n], _ = s.Hash(uint64(n))
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("player %d: frames differ from the offline run\n", player+1)
+		}
+		if s.Rollbacks == 0 || s.Resimulated == 0 {
+			t.Errorf("player %d: expected rollbacks over a slow link\n", player+1)
+		}
+	}
+}
+
+// TestRollbackSound checks frames run again don't reach the sound board:
+// the audio of every frame is the same as with no rollbacks
+func TestRollbackSound(t *testing.T) {
+	const frames = 120
+	c := &clock{now: time.Unix(0, 0)}
+	a, b := Pipe()
+	lossyA, lossyB := NewLossy(a, 50*time.Millisecond, 0, 1), NewLossy(b, 50*time.Millisecond, 0, 2)
+	lossyA.Now, lossyB.Now = c.Now, c.Now
+	sessions := newSessionsOn(t, newSoundMachine(t), newSoundMachine(t), lossyA, lossyB)
+
+	got := map[uint64][]int16{}
+	for i := 0; sessions[0].Frame() < frames; i++ {
+		if i > 10*frames {
+			t.Fatalf("session stuck at frame %d\n", sessions[0].Frame())
+		}
+		ran, err := sessions[0].Advance(script(0, sessions[0].Frame()))
+		if err != nil {
+			t.Fatalf("Advance failed: %v\n", err)
+		}
+		if ran {
+			got[sessions[0].Frame()-1] = append([]int16(nil), sessions[0].m.Audio()...)
+		}
+		if _, err := sessions[1].Advance(script(1, sessions[1].Frame())); err != nil {
+			t.Fatalf("Advance failed: %v\n", err)
+		}
+		c.now = c.now.Add(time.Second / 60)
+	}
+	if sessions[0].Rollbacks == 0 {
+		t.Fatalf("expected rollbacks over a slow link\n")
+	}
+	_, want := reference(t, newSoundMachine(t), frames)
+	loud := false
+	for n := uint64(0); n < frames; n++ {
+		if !reflect.DeepEqual(got[n], want[n]) {
+			t.Fatalf("frame %d: audio differs from a run without rollbacks\n", n)
+		}
+		for _, sample := range want[n] {
+			loud = loud || sample != 0
+		}
+	}
+	if !loud {
+		t.Fatalf("expected the program to make a sound\n")
+	}
+}
+
+func TestSessionDesync(t *testing.T) {
+	a, b := Pipe()
+	sessions := newTestSessions(t, a, b)
+	var err error
+	for i := 0; i < 120 && err == nil; i++ {
+		if i == 30 {
+			// Something outside the inputs changes one machine
+			_ = sessions[1].m.Memory.Write(0x2100, 0xff)
+		}
+		err = tick(sessions)
+	}
+	var desync *DesyncError
+	if !errors.As(err, &desync) || desync.Frame < 30 || desync.Frame > 32 {
+		t.Fatalf("expected a desync around frame 31, got %v\n", err)
+	}
+}
+
+func TestSessionDifferentStart(t *testing.T) {
+	a, b := Pipe()
+	sessions := newTestSessions(t, a, b)
+	sessions[1].m.IOBus.HandleInput(2, 0, true)
+	sessions[1].start = sessions[1].m.Snapshot().Hash()
+	var err error
+	for i := 0; i < 5 && err == nil; i++ {
+		err = tick(sessions)
+	}
+	if err == nil {
+		t.Fatalf("expected an error for peers with different DIP switches\n")
+	}
+
+	if _, err := NewSession(newTestMachine(t), a, 2); err == nil {
+		t.Fatalf("expected an error for player 3\n")
+	}
+}
+
+func TestSessionStallsWithoutPeer(t *testing.T) {
+	a, _ := Pipe()
+	s, err := NewSession(newTestMachine(t), a, 0)
+	if err != nil {
+		t.Fatalf("NewSession failed: %v\n", err)
+	}
+	for i := 0; i < 20; i++ {
+		if _, err := s.Advance(Input{}); err != nil {
+			t.Fatalf("Advance failed: %v\n", err)
+		}
+	}
+	if s.Frame() != DefaultMaxRollback || s.Stalls != 20-DefaultMaxRollback || s.Connected() {
+		t.Fatalf("expected to stop at frame %d, got %d with %d stalls\n", DefaultMaxRollback, s.Frame(), s.Stalls)
+	}
+}
+
+func TestSessionOverUDP(t *testing.T) {
+	host, err := Listen("127.0.0.1:0")
+	if err != nil {
+		t.Skipf("no UDP on loopback: %v\n", err)
+	}
+	defer host.Close()
+	// A stray datagram arriving first must not become the peer
+	stray, err := net.Dial("udp", host.LocalAddr().String())
+	if err != nil {
+		t.Fatalf("Dial failed: %v\n", err)
+	}
+	defer stray.Close()
+	if _, err := stray.Write([]byte("hello")); err != nil {
+		t.Fatalf("Write failed: %v\n", err)
+	}
+	client, err := Dial(host.LocalAddr().String())
+	if err != nil {
+		t.Fatalf("Dial failed: %v\n", err)
+	}
+	defer client.Close()
+	sessions := newTestSessions(t, NewLossy(host, 5*time.Millisecond, 0.1, 1), NewLossy(client, 5*time.Millisecond, 0.1, 2))
+
+	const frames = 60
+	deadline := time.Now().Add(10 * time.Second)
+	for sessions[0].Confirmed() < frames || sessions[1].Confirmed() < frames {
+		if time.Now().After(deadline) {
+			t.Fatalf("sessions stuck at %d and %d confirmed frames\n", sessions[0].Confirmed(), sessions[1].Confirmed())
+		}
+		if err := tick(sessions); err != nil {
+			t.Fatalf("Advance failed: %v\n", err)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	want, _ := reference(t, newTestMachine(t), frames)
+	for player, s := range sessions {
+		if got, _ := s.Hash(frames - 1); got != want[frames-1] {
+			t.Fatalf("player %d: frame %d differs from the offline run\n", player+1, frames-1)
+		}
+	}
+}
+
+func TestPacketRoundTrip(t *testing.T) {
+	p := &packet{player: 1, start: 0x1122334455667788, ack: 40, frame: 45, advantage: -3, hashFrame: 38, hash: 42, first: 41, inputs: []Input{{0x10, 0}, {0, 0x70}}}
+	got, err := decodePacket(p.encode())
+	if err != nil {
+		t.Fatalf("decodePacket failed: %v\n", err)
+	}
+	if !reflect.DeepEqual(got, p) {
+		t.Fatalf("round trip mismatch: %+v\n", got)
+	}
+	if _, err := decodePacket(p.encode()[:42]); err == nil {
+		t.Fatalf("expected an error for a truncated packet\n")
+	}
+}
@@ -0,0 +1,16 @@
+//go:build nosdl
+// +build nosdl
+
+package main
+
+import (
+	"errors"
+
+	"intel8080/display"
+	"intel8080/machine"
+)
+
+// runInteractive is unavailable in builds without SDL
+func runInteractive(m *machine.Machine, overlay *display.Overlay, peer *netPeer) error {
+	return errors.New("built without SDL (-tags nosdl); use -headless")
+}
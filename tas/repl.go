@@ -0,0 +1,257 @@
+package tas
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"intel8080/display"
+	"intel8080/machine"
+)
+
+const editorHelp = `commands (frames are decimal, counted from the start of the movie):
+  show [frame] [n]          show n frames of the input grid (default 20 around the cursor)
+  set frame[-last] input [on|off]
+                            hold or release input (a name or port.bit) through the frames;
+                            without on or off, toggle it on the first frame's state
+  insert frame [n]          insert n blank frames before frame (frame may be the end)
+  delete frame [n]          delete n frames from frame
+  seek frame                run to frame, from the closest greenzone state
+  branch [name]             save the grid and state as a branch, or list branches
+  load name                 load a branch
+  lag                       list the lag frames found so far
+  png file                  save the picture at the cursor
+  save                      write the movie
+  info                      show the movie length, cursor, greenzone and rerecords
+  q                         quit
+`
+
+// columns are the inputs shown in the grid, with their heading letters
+var columns = []struct {
+	name   string
+	letter byte
+}{
+	{"coin", 'C'}, {"p1start", '1'}, {"p2start", '2'}, {"p1fire", 'F'}, {"p1left", 'L'}, {"p1right", 'R'},
+	{"p2fire", 'f'}, {"p2left", 'l'}, {"p2right", 'r'}, {"tilt", 'T'},
+}
+
+// RunEditor is a line based movie editor reading commands from in until EOF
+// or "q". save writes the movie; overlay colours png pictures.
+func RunEditor(e *Editor, overlay *display.Overlay, save func(*machine.Movie) error, in io.Reader, out io.Writer) error {
+	scanner := bufio.NewScanner(in)
+	fmt.Fprintf(out, "%d frames, %d rerecords (h for help)\n> ", e.Len(), e.Rerecords)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) > 0 {
+			quit, err := e.command(fields[0], fields[1:], overlay, save, out)
+			if err != nil {
+				fmt.Fprintf(out, "%v\n", err)
+			}
+			if quit {
+				return nil
+			}
+		}
+		fmt.Fprint(out, "> ")
+	}
+	return scanner.Err()
+}
+
+func (e *Editor) command(cmd string, args []string, overlay *display.Overlay, save func(*machine.Movie) error, out io.Writer) (bool, error) {
+	number := func(i int, def uint64) (uint64, error) {
+		if i >= len(args) {
+			return def, nil
+		}
+		n, err := strconv.ParseUint(args[i], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("bad number %q", args[i])
+		}
+		return n, nil
+	}
+
+	switch cmd {
+	case "q", "quit":
+		return true, nil
+	case "h", "help":
+		fmt.Fprint(out, editorHelp)
+	case "show":
+		from := uint64(0)
+		if e.cursor > 10 {
+			from = e.cursor - 10
+		}
+		from, err := number(0, from)
+		if err != nil {
+			return false, err
+		}
+		n, err := number(1, 20)
+		if err != nil {
+			return false, err
+		}
+		e.show(out, from, n)
+	case "set":
+		if len(args) < 2 || len(args) > 3 {
+			return false, fmt.Errorf("usage: set frame[-last] input [on|off]")
+		}
+		first, last, err := parseRange(args[0])
+		if err != nil {
+			return false, err
+		}
+		in, err := machine.ParseInput(args[1])
+		if err != nil {
+			return false, err
+		}
+		if first >= e.Len() {
+			return false, fmt.Errorf("frame %d out of range (movie has %d)", first, e.Len())
+		}
+		held := !e.frames[first].Held(in)
+		if len(args) == 3 {
+			switch args[2] {
+			case "on":
+				held = true
+			case "off":
+				held = false
+			default:
+				return false, fmt.Errorf("bad state %q (want on or off)", args[2])
+			}
+		}
+		if err := e.Set(first, last, in, held); err != nil {
+			return false, err
+		}
+		e.show(out, first, last-first+1)
+	case "insert", "delete":
+		if len(args) == 0 {
+			return false, fmt.Errorf("usage: %s frame [n]", cmd)
+		}
+		at, err := number(0, 0)
+		if err != nil {
+			return false, err
+		}
+		n, err := number(1, 1)
+		if err != nil {
+			return false, err
+		}
+		if cmd == "insert" {
+			err = e.Insert(at, n)
+		} else {
+			err = e.Delete(at, n)
+		}
+		if err != nil {
+			return false, err
+		}
+		fmt.Fprintf(out, "%d frames\n", e.Len())
+	case "seek":
+		if len(args) != 1 {
+			return false, fmt.Errorf("usage: seek frame")
+		}
+		n, err := number(0, 0)
+		if err != nil {
+			return false, err
+		}
+		if err := e.Seek(n); err != nil {
+			return false, err
+		}
+		e.show(out, n, 1)
+	case "branch":
+		if len(args) == 0 {
+			for _, name := range e.Branches() {
+				b := e.branches[name]
+				fmt.Fprintf(out, "%-12s %d frames, cursor %d\n", name, len(b.Frames), b.Cursor)
+			}
+			break
+		}
+		e.SaveBranch(args[0])
+		fmt.Fprintf(out, "branch %s saved at frame %d\n", args[0], e.cursor)
+	case "load":
+		if len(args) != 1 {
+			return false, fmt.Errorf("usage: load name")
+		}
+		if err := e.LoadBranch(args[0]); err != nil {
+			return false, err
+		}
+		fmt.Fprintf(out, "branch %s loaded: %d frames, cursor %d, %d rerecords\n", args[0], e.Len(), e.cursor, e.Rerecords)
+	case "lag":
+		lag := e.LagFrames()
+		fmt.Fprintf(out, "%d lag frames: %v\n", len(lag), lag)
+	case "png":
+		if len(args) != 1 {
+			return false, fmt.Errorf("usage: png file")
+		}
+		fb := display.NewFramebuffer(e.m.ScreenSize())
+		if err := e.m.DrawScreen(fb, overlay); err != nil {
+			return false, err
+		}
+		if err := display.SavePNG(args[0], fb); err != nil {
+			return false, err
+		}
+	case "save":
+		mv, err := e.Movie()
+		if err != nil {
+			return false, err
+		}
+		if err := save(mv); err != nil {
+			return false, err
+		}
+		fmt.Fprintf(out, "%d frames saved, %d rerecords\n", mv.Frames(), mv.Rerecords)
+	case "info":
+		fmt.Fprintf(out, "%d frames, cursor %d, %d greenzone states, %d lag frames known, %d rerecords\n",
+			e.Len(), e.cursor, e.GreenzoneSize(), len(e.LagFrames()), e.Rerecords)
+	default:
+		return false, fmt.Errorf("unknown command %q (h for help)", cmd)
+	}
+	return false, nil
+}
+
+// parseRange parses "frame" or "first-last"
+func parseRange(s string) (first, last uint64, err error) {
+	parts := strings.SplitN(s, "-", 2)
+	if first, err = strconv.ParseUint(parts[0], 10, 64); err != nil {
+		return 0, 0, fmt.Errorf("bad frame %q", parts[0])
+	}
+	last = first
+	if len(parts) == 2 {
+		if last, err = strconv.ParseUint(parts[1], 10, 64); err != nil || last < first {
+			return 0, 0, fmt.Errorf("bad frame range %q", s)
+		}
+	}
+	return first, last, nil
+}
+
+// show prints n frames of the grid from frame from. Each row has a marker
+// for the cursor (>) and greenzone states (*), L for known lag frames and
+// ? for frames not run since they changed.
+func (e *Editor) show(out io.Writer, from, n uint64) {
+	heading := make([]byte, len(columns))
+	for i, c := range columns {
+		heading[i] = c.letter
+	}
+	fmt.Fprintf(out, "  frame     %s\n", heading)
+	for f := from; f < from+n && f < e.Len(); f++ {
+		marker := ' '
+		if f == e.cursor {
+			marker = '>'
+		}
+		green := ' '
+		if e.InGreenzone(f) {
+			green = '*'
+		}
+		lag := '?'
+		if lagged, known := e.Lag(f); known {
+			lag = ' '
+			if lagged {
+				lag = 'L'
+			}
+		}
+		row := make([]byte, len(columns))
+		for i, c := range columns {
+			row[i] = '.'
+			if e.frames[f].Held(machine.Inputs[c.name]) {
+				row[i] = c.letter
+			}
+		}
+		fmt.Fprintf(out, "%c%c%8d %c %s\n", marker, green, f, lag, row)
+	}
+	if from+n > e.Len() && e.cursor == e.Len() {
+		fmt.Fprintf(out, ">%9d   (end)\n", e.Len())
+	}
+}
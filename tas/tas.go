@@ -0,0 +1,427 @@
+// Package tas edits movies a frame at a time, for tool-assisted runs. The
+// Editor keeps the movie as a grid of the inputs held through each frame and
+// a greenzone of machine states, so any frame can be reached without
+// replaying the movie from the start, and an edit only re-runs the frames
+// after it.
+package tas
+
+import (
+	"fmt"
+	"sort"
+
+	"intel8080/machine"
+)
+
+// Frame holds the input bits held through one frame, for IN ports 0 to 2.
+// DIP switches are input bits too, so they are part of every frame.
+type Frame [3]byte
+
+// Held reports whether in is held
+func (f Frame) Held(in machine.Input) bool {
+	return in.Port < 3 && f[in.Port]&(1<<in.Bit) != 0
+}
+
+// Set holds or releases in
+func (f *Frame) Set(in machine.Input, held bool) {
+	if in.Port >= 3 {
+		return
+	}
+	if held {
+		f[in.Port] |= 1 << in.Bit
+	} else {
+		f[in.Port] &^= 1 << in.Bit
+	}
+}
+
+// dipMask returns the DIP switch bits of each port
+func dipMask() Frame {
+	var mask Frame
+	for _, d := range machine.DIPSwitches {
+		mask[d.Port] |= d.Mask
+	}
+	return mask
+}
+
+const (
+	// DefaultDense is how many frames either side of the cursor keep every
+	// state in the greenzone
+	DefaultDense = 600
+	// DefaultSparse is the spacing of the states kept further away
+	DefaultSparse = 60
+)
+
+// Branch is a saved version of the movie: its input grid and the frame the
+// cursor was on
+type Branch struct {
+	Frames []Frame
+	Cursor uint64
+	// State is the machine at Cursor, so loading the branch is instant
+	State *machine.State
+}
+
+// Editor edits a movie on a machine. Frames are numbered from the start of
+// the movie; the cursor is the number of frames run, so frame n's inputs are
+// the next to go in.
+type Editor struct {
+	m      *machine.Machine
+	start  *machine.State
+	frames []Frame
+	cursor uint64
+
+	// greenzone holds the state after n frames, before frame n's inputs;
+	// hashes and lag hold what is known about frame n once it has run.
+	// recorded holds the hashes of the movie as loaded, which frames are
+	// checked against until the grid is edited.
+	greenzone map[uint64]*machine.State
+	hashes    map[uint64]uint64
+	lag       map[uint64]bool
+	recorded  map[uint64]uint64
+
+	branches  map[string]*Branch
+	Rerecords uint64
+
+	// Dense and Sparse thin the greenzone: states within Dense frames of the
+	// cursor are kept, further ones only every Sparse frames
+	Dense, Sparse uint64
+}
+
+// NewEditor opens mv for editing on m, which must have the movie's ROM
+// loaded. The machine is left at the start of the movie.
+func NewEditor(m *machine.Machine, mv *machine.Movie) (*Editor, error) {
+	if hash := m.RomHash(); hash != mv.RomSHA1 {
+		return nil, fmt.Errorf("movie was recorded with ROM %s, loaded ROM is %s", mv.RomSHA1, hash)
+	}
+	start, err := mv.StartState()
+	if err != nil {
+		return nil, err
+	}
+	if err := m.Restore(start); err != nil {
+		return nil, err
+	}
+	e := &Editor{
+		m:         m,
+		start:     start,
+		greenzone: map[uint64]*machine.State{0: start},
+		hashes:    map[uint64]uint64{},
+		lag:       map[uint64]bool{},
+		recorded:  map[uint64]uint64{},
+		branches:  map[string]*Branch{},
+		Rerecords: mv.Rerecords,
+		Dense:     DefaultDense,
+		Sparse:    DefaultSparse,
+	}
+	held := e.startFrame()
+	script := mv.Script()
+	for n := uint64(0); n < mv.Frames(); n++ {
+		for len(script) > 0 && script[0].Frame <= n {
+			held.Set(script[0].Input, script[0].Pressed)
+			script = script[1:]
+		}
+		e.frames = append(e.frames, held)
+		e.recorded[n] = mv.Hashes[n]
+	}
+	return e, nil
+}
+
+// startFrame returns the inputs held in the starting state
+func (e *Editor) startFrame() Frame {
+	io := e.start.CPU.IO
+	return Frame{io.Input0, io.Input1, io.Input2}
+}
+
+// Len returns the length of the movie in frames
+func (e *Editor) Len() uint64 {
+	return uint64(len(e.frames))
+}
+
+// Frame returns the inputs of frame n
+func (e *Editor) Frame(n uint64) Frame {
+	return e.frames[n]
+}
+
+// Cursor returns the number of frames run
+func (e *Editor) Cursor() uint64 {
+	return e.cursor
+}
+
+// Machine returns the machine being edited
+func (e *Editor) Machine() *machine.Machine {
+	return e.m
+}
+
+// Set holds or releases in through frames first to last
+func (e *Editor) Set(first, last uint64, in machine.Input, held bool) error {
+	if first > last || last >= e.Len() {
+		return fmt.Errorf("frames %d-%d out of range (movie has %d)", first, last, e.Len())
+	}
+	for n := first; n <= last; n++ {
+		if e.frames[n].Held(in) != held {
+			e.frames[n].Set(in, held)
+			if err := e.invalidate(n); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Insert inserts count frames before frame at (at may be Len to append).
+// They keep the DIP switches of the frame before with no controls held.
+func (e *Editor) Insert(at, count uint64) error {
+	if at > e.Len() {
+		return fmt.Errorf("frame %d out of range (movie has %d)", at, e.Len())
+	}
+	blank := e.startFrame()
+	if at > 0 {
+		blank = e.frames[at-1]
+	}
+	mask := dipMask()
+	for p := range blank {
+		blank[p] &= mask[p]
+	}
+	frames := make([]Frame, 0, e.Len()+count)
+	frames = append(frames, e.frames[:at]...)
+	for i := uint64(0); i < count; i++ {
+		frames = append(frames, blank)
+	}
+	e.frames = append(frames, e.frames[at:]...)
+	return e.invalidate(at)
+}
+
+// Delete removes count frames from frame at
+func (e *Editor) Delete(at, count uint64) error {
+	if at+count > e.Len() {
+		return fmt.Errorf("frames %d-%d out of range (movie has %d)", at, at+count-1, e.Len())
+	}
+	e.frames = append(e.frames[:at], e.frames[at+count:]...)
+	return e.invalidate(at)
+}
+
+// invalidate forgets everything that depended on frame n's inputs, and
+// returns the machine to frame n if it was past it. Changing frames that
+// already ran in the editor counts as a rerecord; frames only known from the
+// loaded movie don't.
+func (e *Editor) invalidate(n uint64) error {
+	forgot := false
+	for k := range e.greenzone {
+		if k > n {
+			delete(e.greenzone, k)
+			forgot = true
+		}
+	}
+	for k := range e.hashes {
+		if k >= n {
+			delete(e.hashes, k)
+			forgot = true
+		}
+	}
+	for k := range e.lag {
+		if k >= n {
+			delete(e.lag, k)
+		}
+	}
+	for k := range e.recorded {
+		if k >= n {
+			delete(e.recorded, k)
+		}
+	}
+	if forgot {
+		e.Rerecords++
+	}
+	if e.cursor > n {
+		// The machine is past the edit: go back to where it still holds
+		return e.Seek(n)
+	}
+	return nil
+}
+
+// Seek runs the machine to frame n, from the closest greenzone state before it
+func (e *Editor) Seek(n uint64) error {
+	if n > e.Len() {
+		return fmt.Errorf("frame %d out of range (movie has %d)", n, e.Len())
+	}
+	from := uint64(0)
+	for k := range e.greenzone {
+		if k <= n && k > from {
+			from = k
+		}
+	}
+	// The machine is always valid at the cursor, so carry on from there
+	// when it is on the way
+	if e.cursor > n || e.cursor < from {
+		if err := e.m.Restore(e.greenzone[from]); err != nil {
+			return err
+		}
+		e.cursor = from
+	}
+	for e.cursor < n {
+		if err := e.step(); err != nil {
+			return err
+		}
+	}
+	e.thin()
+	return nil
+}
+
+// step runs the frame at the cursor, recording its hash, whether it lagged
+// and the state after it
+func (e *Editor) step() error {
+	n := e.cursor
+	held := e.frames[n]
+	for port := range held {
+		current := e.m.IOBus.InputBits(uint8(port))
+		for bit := uint8(0); bit < 8; bit++ {
+			if (held[port]^current)&(1<<bit) != 0 {
+				e.m.IOBus.HandleInput(uint8(port), bit, held[port]&(1<<bit) != 0)
+			}
+		}
+	}
+	reads := e.m.IOBus.InputReads
+	if err := e.m.RunFrame(); err != nil {
+		return fmt.Errorf("frame %d: %v", n, err)
+	}
+	e.cursor++
+	state := e.m.Snapshot()
+	e.greenzone[e.cursor] = state
+	e.lag[n] = e.m.IOBus.InputReads == reads
+	hash := state.Hash()
+	if want, ok := e.recorded[n]; ok && want != hash {
+		return &machine.DesyncError{Frame: n + 1, Want: want, Got: hash}
+	}
+	e.hashes[n] = hash
+	return nil
+}
+
+// thin drops greenzone states away from the cursor, keeping every Sparse-th
+func (e *Editor) thin() {
+	for k := range e.greenzone {
+		if k == 0 || e.Sparse > 0 && k%e.Sparse == 0 || k+e.Dense >= e.cursor && k <= e.cursor+e.Dense {
+			continue
+		}
+		delete(e.greenzone, k)
+	}
+}
+
+// InGreenzone reports whether the state after n frames is cached
+func (e *Editor) InGreenzone(n uint64) bool {
+	_, ok := e.greenzone[n]
+	return ok
+}
+
+// GreenzoneSize returns the number of cached states
+func (e *Editor) GreenzoneSize() int {
+	return len(e.greenzone)
+}
+
+// Lag reports whether frame n was a lag frame, and whether that is known
+// (it is once the frame has run since its inputs last changed)
+func (e *Editor) Lag(n uint64) (lagged, known bool) {
+	lagged, known = e.lag[n]
+	return lagged, known
+}
+
+// LagFrames returns the known lag frames in order
+func (e *Editor) LagFrames() []uint64 {
+	var frames []uint64
+	for n, lagged := range e.lag {
+		if lagged {
+			frames = append(frames, n)
+		}
+	}
+	sort.Slice(frames, func(i, j int) bool { return frames[i] < frames[j] })
+	return frames
+}
+
+// SaveBranch saves the grid and the machine at the cursor under name
+func (e *Editor) SaveBranch(name string) {
+	e.branches[name] = &Branch{
+		Frames: append([]Frame(nil), e.frames...),
+		Cursor: e.cursor,
+		State:  e.m.Snapshot(),
+	}
+}
+
+// LoadBranch replaces the grid with a saved branch and returns to its
+// cursor. Frames up to the first difference keep their greenzone states.
+func (e *Editor) LoadBranch(name string) error {
+	b, ok := e.branches[name]
+	if !ok {
+		return fmt.Errorf("no branch %q", name)
+	}
+	first := uint64(0)
+	for first < e.Len() && first < uint64(len(b.Frames)) && e.frames[first] == b.Frames[first] {
+		first++
+	}
+	rerecords := e.Rerecords
+	e.frames = append([]Frame(nil), b.Frames...)
+	if err := e.invalidate(first); err != nil {
+		return err
+	}
+	if b.Cursor > first {
+		// The branch's own state is valid for its frames: start from it
+		if err := e.m.Restore(b.State); err != nil {
+			return err
+		}
+		e.greenzone[b.Cursor] = b.State
+		e.cursor = b.Cursor
+	}
+	// Loading a branch is one rerecord however much it changed
+	e.Rerecords = rerecords + 1
+	return e.Seek(b.Cursor)
+}
+
+// Branches returns the branch names in order
+func (e *Editor) Branches() []string {
+	names := make([]string, 0, len(e.branches))
+	for name := range e.branches {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Movie runs any frames not yet run and returns the edited movie. The
+// machine ends at the cursor again.
+func (e *Editor) Movie() (*machine.Movie, error) {
+	cursor := e.cursor
+	for n := uint64(0); n < e.Len(); n++ {
+		if _, ok := e.hashes[n]; !ok {
+			// Run frame n itself: the state after it may be cached already
+			if err := e.Seek(n); err != nil {
+				return nil, err
+			}
+			if err := e.step(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := e.Seek(cursor); err != nil {
+		return nil, err
+	}
+	start, err := e.start.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	mv := &machine.Movie{
+		Version:   machine.MovieVersion,
+		RomSHA1:   e.m.RomHash(),
+		Roms:      e.m.Memory.LoadedRoms(),
+		Start:     start,
+		Rerecords: e.Rerecords,
+	}
+	held := e.startFrame()
+	for n, f := range e.frames {
+		for port := range f {
+			for bit := uint8(0); bit < 8; bit++ {
+				if (f[port]^held[port])&(1<<bit) != 0 {
+					mv.Events = append(mv.Events, machine.MovieEvent{
+						Frame: uint64(n), Port: uint8(port), Bit: bit, Pressed: f[port]&(1<<bit) != 0,
+					})
+				}
+			}
+		}
+		held = f
+		mv.Hashes = append(mv.Hashes, e.hashes[uint64(n)])
+	}
+	return mv, nil
+}
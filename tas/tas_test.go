@@ -0,0 +1,312 @@
+package tas
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"intel8080/intel8080"
+	"intel8080/machine"
+)
+
+// lagProgram adds port 1 to a total in its vblank handler, every other frame,
+// so odd and even frames alternate between reading the inputs and lagging
+var lagProgram = map[uint16][]byte{
+	0x00: {0xC3, 0x40, 0x00}, // JMP 0x0040
+	0x08: {0xFB, 0xC9},       // RST 1: EI, RET
+	0x10: {
+		0xF5,             // RST 2: PUSH PSW
+		0x3A, 0x01, 0x20, // LDA 0x2001
+		0x3C,             // INR A
+		0x32, 0x01, 0x20, // STA 0x2001
+		0xE6, 0x01, // ANI 1
+		0xC2, 0x27, 0x00, // JNZ 0x0027
+		0xDB, 0x01, // IN 1
+		0x47,             // MOV B,A
+		0x3A, 0x00, 0x20, // LDA 0x2000
+		0x80,             // ADD B
+		0x32, 0x00, 0x20, // STA 0x2000
+		0xF1, // POP PSW
+		0xFB, // EI
+		0xC9, // RET
+	},
+	0x40: {
+		0x31, 0x00, 0x24, // LXI SP,0x2400
+		0xFB,             // EI
+		0xC3, 0x44, 0x00, // JMP 0x0044
+	},
+}
+
+func newTestMachine(t *testing.T) *machine.Machine {
+	memory := intel8080.NewMemory(0x4000)
+	for at, code := range lagProgram {
+		for i, b := range code {
+			if err := memory.Write(at+uint16(i), b); err != nil {
+				t.Fatalf("loading program failed: %v\n", err)
+			}
+		}
+	}
+	ioBus := intel8080.NewIOBus()
+	return machine.New(intel8080.NewCPU(ioBus, memory), memory, ioBus)
+}
+
+// recordMovie records 40 frames, holding fire through frames 4-11 and 20-29
+func recordMovie(t *testing.T) *machine.Movie {
+	m := newTestMachine(t)
+	r, err := m.RecordMovie()
+	if err != nil {
+		t.Fatalf("RecordMovie failed: %v\n", err)
+	}
+	fire := machine.Inputs["p1fire"]
+	for n := 0; n < 40; n++ {
+		switch n {
+		case 4, 20:
+			m.IOBus.HandleInput(fire.Port, fire.Bit, true)
+		case 12, 30:
+			m.IOBus.HandleInput(fire.Port, fire.Bit, false)
+		}
+		if err := m.RunFrame(); err != nil {
+			t.Fatalf("RunFrame failed: %v\n", err)
+		}
+	}
+	return r.Stop()
+}
+
+func newTestEditor(t *testing.T, mv *machine.Movie) *Editor {
+	e, err := NewEditor(newTestMachine(t), mv)
+	if err != nil {
+		t.Fatalf("NewEditor failed: %v\n", err)
+	}
+	return e
+}
+
+func TestEditorRoundTrip(t *testing.T) {
+	mv := recordMovie(t)
+	e := newTestEditor(t, mv)
+	fire := machine.Inputs["p1fire"]
+	for n := uint64(0); n < e.Len(); n++ {
+		want := n >= 4 && n < 12 || n >= 20 && n < 30
+		if e.Frame(n).Held(fire) != want {
+			t.Fatalf("frame %d: expected fire held %v\n", n, want)
+		}
+	}
+	// Running every frame checks them against the recorded hashes
+	if err := e.Seek(e.Len()); err != nil {
+		t.Fatalf("Seek failed: %v\n", err)
+	}
+	got, err := e.Movie()
+	if err != nil {
+		t.Fatalf("Movie failed: %v\n", err)
+	}
+	if !reflect.DeepEqual(got.Events, mv.Events) || !reflect.DeepEqual(got.Hashes, mv.Hashes) || got.Rerecords != 0 {
+		t.Fatalf("expected the movie back unchanged, got %v\n", got.Events)
+	}
+}
+
+func TestEditorEdit(t *testing.T) {
+	mv := recordMovie(t)
+	e := newTestEditor(t, mv)
+	if err := e.Seek(30); err != nil {
+		t.Fatalf("Seek failed: %v\n", err)
+	}
+	fire := machine.Inputs["p1fire"]
+	// Release fire on frames 6-7: one of them reads it, so the run changes
+	if err := e.Set(6, 7, fire, false); err != nil {
+		t.Fatalf("Set failed: %v\n", err)
+	}
+	if e.Cursor() != 6 || e.Rerecords != 1 {
+		t.Fatalf("expected the cursor back at 6 after one rerecord, got %d and %d\n", e.Cursor(), e.Rerecords)
+	}
+	if e.InGreenzone(7) {
+		t.Fatalf("expected states after the edit to be dropped\n")
+	}
+	// Editing frames not run yet isn't a rerecord
+	if err := e.Set(35, 35, fire, true); err != nil {
+		t.Fatalf("Set failed: %v\n", err)
+	}
+	if e.Rerecords != 1 {
+		t.Fatalf("expected 1 rerecord, got %d\n", e.Rerecords)
+	}
+
+	edited, err := e.Movie()
+	if err != nil {
+		t.Fatalf("Movie failed: %v\n", err)
+	}
+	if e.Cursor() != 6 {
+		t.Fatalf("expected Movie to leave the cursor at 6, got %d\n", e.Cursor())
+	}
+	if !reflect.DeepEqual(edited.Hashes[:6], mv.Hashes[:6]) || reflect.DeepEqual(edited.Hashes[8:], mv.Hashes[8:]) {
+		t.Fatalf("expected the hashes to change from the edit on\n")
+	}
+	// The edited movie plays back in sync
+	m := newTestMachine(t)
+	p, err := m.PlayMovie(edited)
+	if err != nil {
+		t.Fatalf("PlayMovie failed: %v\n", err)
+	}
+	for !p.Done() {
+		if err := m.RunFrame(); err != nil {
+			t.Fatalf("RunFrame failed: %v\n", err)
+		}
+	}
+}
+
+func TestEditorEditLoaded(t *testing.T) {
+	e := newTestEditor(t, recordMovie(t))
+	fire := machine.Inputs["p1fire"]
+	// Frames only known from the file never ran in the editor
+	if err := e.Set(6, 7, fire, false); err != nil {
+		t.Fatalf("Set failed: %v\n", err)
+	}
+	if e.Rerecords != 0 {
+		t.Fatalf("expected no rerecord for frames not run, got %d\n", e.Rerecords)
+	}
+
+	// A fault while going back to the edit is returned
+	if err := e.Seek(20); err != nil {
+		t.Fatalf("Seek failed: %v\n", err)
+	}
+	for k := range e.greenzone {
+		if k != 0 {
+			delete(e.greenzone, k)
+		}
+	}
+	e.recorded[2] = 0
+	if err := e.Set(14, 14, fire, true); err == nil {
+		t.Fatalf("expected Set to return the desync of frame 2\n")
+	}
+}
+
+func TestLagFrames(t *testing.T) {
+	e := newTestEditor(t, recordMovie(t))
+	if _, known := e.Lag(3); known {
+		t.Fatalf("expected lag to be unknown before running\n")
+	}
+	if err := e.Seek(10); err != nil {
+		t.Fatalf("Seek failed: %v\n", err)
+	}
+	lag := e.LagFrames()
+	if len(lag) != 5 {
+		t.Fatalf("expected every other frame to lag, got %v\n", lag)
+	}
+	for i := 1; i < len(lag); i++ {
+		if lag[i] != lag[i-1]+2 {
+			t.Fatalf("expected every other frame to lag, got %v\n", lag)
+		}
+	}
+
+	// Inputs held through lag frames make no difference to the game
+	fire := machine.Inputs["p1fire"]
+	if err := e.Seek(e.Len()); err != nil {
+		t.Fatalf("Seek failed: %v\n", err)
+	}
+	before := e.Machine().Snapshot().CPU.Memory
+	if err := e.Set(lag[0], lag[0], fire, true); err != nil {
+		t.Fatalf("Set failed: %v\n", err)
+	}
+	if err := e.Seek(e.Len()); err != nil {
+		t.Fatalf("Seek failed: %v\n", err)
+	}
+	if !bytes.Equal(e.Machine().Snapshot().CPU.Memory, before) {
+		t.Fatalf("expected a lag frame edit to change nothing\n")
+	}
+}
+
+func TestBranches(t *testing.T) {
+	mv := recordMovie(t)
+	e := newTestEditor(t, mv)
+	if err := e.Seek(10); err != nil {
+		t.Fatalf("Seek failed: %v\n", err)
+	}
+	e.SaveBranch("a")
+	fire := machine.Inputs["p1fire"]
+	if err := e.Set(4, 9, fire, false); err != nil {
+		t.Fatalf("Set failed: %v\n", err)
+	}
+	if err := e.Delete(0, 2); err != nil {
+		t.Fatalf("Delete failed: %v\n", err)
+	}
+	rerecords := e.Rerecords
+	if err := e.LoadBranch("a"); err != nil {
+		t.Fatalf("LoadBranch failed: %v\n", err)
+	}
+	if e.Cursor() != 10 || e.Len() != 40 || e.Rerecords != rerecords+1 {
+		t.Fatalf("expected the branch at frame 10 of 40 and one more rerecord, got %d of %d, %d\n", e.Cursor(), e.Len(), e.Rerecords)
+	}
+	got, err := e.Movie()
+	if err != nil {
+		t.Fatalf("Movie failed: %v\n", err)
+	}
+	if !reflect.DeepEqual(got.Hashes, mv.Hashes) {
+		t.Fatalf("expected the branch to replay the original\n")
+	}
+	if err := e.LoadBranch("b"); err == nil {
+		t.Fatalf("expected an error for a missing branch\n")
+	}
+}
+
+func TestGreenzoneThinning(t *testing.T) {
+	e := newTestEditor(t, recordMovie(t))
+	e.Dense, e.Sparse = 5, 4
+	if err := e.Seek(30); err != nil {
+		t.Fatalf("Seek failed: %v\n", err)
+	}
+	for n := uint64(0); n <= 30; n++ {
+		want := n%4 == 0 || n >= 25
+		if e.InGreenzone(n) != want {
+			t.Errorf("frame %d: expected in greenzone %v\n", n, want)
+		}
+	}
+	// Seeking back runs from the closest state before
+	if err := e.Seek(15); err != nil {
+		t.Fatalf("Seek failed: %v\n", err)
+	}
+	if e.Cursor() != 15 || e.Machine().Frame != 15 {
+		t.Fatalf("expected frame 15, got %d\n", e.Machine().Frame)
+	}
+}
+
+func TestInsertKeepsDIPSwitches(t *testing.T) {
+	mv := recordMovie(t)
+	e := newTestEditor(t, mv)
+	if err := e.Set(0, e.Len()-1, machine.Input{Port: 2, Bit: 0}, true); err != nil {
+		t.Fatalf("Set failed: %v\n", err)
+	}
+	if err := e.Insert(6, 3); err != nil {
+		t.Fatalf("Insert failed: %v\n", err)
+	}
+	if e.Len() != 43 {
+		t.Fatalf("expected 43 frames, got %d\n", e.Len())
+	}
+	for n := uint64(6); n < 9; n++ {
+		if e.Frame(n) != (Frame{0, 0, 0x01}) {
+			t.Fatalf("frame %d: expected only the DIP switch, got %v\n", n, e.Frame(n))
+		}
+	}
+	if err := e.Insert(44, 1); err == nil {
+		t.Fatalf("expected an error inserting past the end\n")
+	}
+}
+
+func TestRunEditor(t *testing.T) {
+	e := newTestEditor(t, recordMovie(t))
+	var saved *machine.Movie
+	save := func(mv *machine.Movie) error {
+		saved = mv
+		return nil
+	}
+	commands := "seek 12\nset 2-3 p1fire on\nbranch first\nlag\nbogus\nsave\ninfo\nq\nseek 1\n"
+	out := &bytes.Buffer{}
+	if err := RunEditor(e, nil, save, strings.NewReader(commands), out); err != nil {
+		t.Fatalf("RunEditor failed: %v\n", err)
+	}
+	for _, want := range []string{">*       2 ? ...F......", "branch first saved at frame 2", "unknown command \"bogus\"", "40 frames saved, 1 rerecords"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("expected %q in the output:\n%s\n", want, out)
+		}
+	}
+	if saved == nil || saved.Frames() != 40 || e.Cursor() != 2 {
+		t.Fatalf("expected the movie saved and the editor stopped at q\n")
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+
+	"intel8080/display"
+	"intel8080/input"
+	"intel8080/machine"
+	"intel8080/netplay"
+)
+
+// runTerminal plays the game in the terminal, reading keys from raw stdin.
+// Terminals report key presses but not releases, so an input stays pressed
+// for -term-hold frames after the last press (or auto-repeat) of its key.
+// With a netplay peer, frames run through its session.
+func runTerminal(m *machine.Machine, overlay *display.Overlay, peer *netPeer) error {
+	mode, err := display.ParseTerminalMode(*termMode)
+	if err != nil {
+		return err
+	}
+	c, err := newCapture(m)
+	if err != nil {
+		return err
+	}
+	f, err := newFilters(*filterSpec)
+	if err != nil {
+		return err
+	}
+	bindings, err := loadKeyMap(true)
+	if err != nil {
+		return err
+	}
+	restore, err := display.MakeRaw(os.Stdin)
+	if err != nil {
+		return err
+	}
+	renderer := display.NewTerminalRenderer(os.Stdout, mode)
+	renderer.Mono = *termMono
+	keys := display.NewTerminalKeys(os.Stdin)
+
+	fb := display.NewFramebuffer(m.ScreenSize())
+	out := fb
+	pacer := machine.NewPacer(machine.RefreshRate)
+	pacer.SetSpeed(*speed)
+	// Frame at which each held input is released
+	held := map[machine.Input]uint64{}
+	paused := false
+	frameAdvance := false
+	dips := &dipMenu{}
+	// runErr is a fault of the machine, ioErr a failure drawing or writing
+	// captures, which needs no crash bundle
+	var runErr, ioErr error
+	press := m.IOBus.HandleInput
+	if peer != nil {
+		press = func(port, bit uint8, pressed bool) {
+			peer.press(machine.Input{Port: port, Bit: bit}, pressed)
+		}
+	}
+
+	for running {
+		if dumpOnInterrupt(m.CPU) {
+			running = false
+			break
+		}
+	drain:
+		for {
+			select {
+			case key, ok := <-keys.Keys:
+				if !ok {
+					running = false
+					break drain
+				}
+				b, ok := bindings.Key(key)
+				if !ok {
+					break
+				}
+				if b.Action == "" {
+					if _, down := held[b.Input]; !down {
+						press(b.Input.Port, b.Input.Bit, true)
+					}
+					held[b.Input] = m.Frame + *termHold
+					break
+				}
+				if peer != nil && peer.blocked(b.Action, true) {
+					break
+				}
+				switch b.Action {
+				case input.ActionQuit:
+					running = false
+				case input.ActionPause:
+					paused = !paused
+				case input.ActionFrameAdvance:
+					frameAdvance = paused
+				case input.ActionScreenshot:
+					c.screenshot(m.Frame, out)
+				case input.ActionGIF:
+					c.toggleGIF()
+				case input.ActionFilter:
+					f.next(c)
+				case input.ActionSaveState:
+					saveState(m)
+				case input.ActionLoadState:
+					loadState(m)
+				case input.ActionDIPMenu:
+					dips.next(m)
+				case input.ActionDIPChange:
+					dips.change(m)
+				}
+			default:
+				break drain
+			}
+		}
+		// Release in a fixed order, so runs and recordings repeat
+		var released []machine.Input
+		for input, until := range held {
+			if m.Frame >= until {
+				released = append(released, input)
+			}
+		}
+		sort.Slice(released, func(i, j int) bool {
+			a, b := released[i], released[j]
+			return a.Port < b.Port || (a.Port == b.Port && a.Bit < b.Bit)
+		})
+		for _, input := range released {
+			press(input.Port, input.Bit, false)
+			delete(held, input)
+		}
+
+		ran := false
+		if peer != nil {
+			ran, runErr = peer.runFrame()
+		} else if !paused || frameAdvance {
+			frameAdvance = false
+			ran, runErr = true, runFrameCatchingPanics(m)
+		}
+		if runErr != nil {
+			break
+		}
+		if ran {
+			if ioErr = m.DrawScreen(fb, overlay); ioErr != nil {
+				break
+			}
+			out = f.Apply(fb)
+			if pacer.Show() {
+				if ioErr = renderer.Render(out); ioErr != nil {
+					break
+				}
+			}
+			if ioErr = c.frame(m.Frame, out, m.Audio()); ioErr != nil {
+				break
+			}
+		}
+		pacer.Wait()
+	}
+
+	_ = renderer.Close()
+	_ = restore()
+	if err := c.close(m.Frame, out); err != nil && ioErr == nil {
+		ioErr = err
+	}
+	var fault *netplay.FrameError
+	if peer != nil && runErr != nil && !errors.As(runErr, &fault) {
+		// Lost the peer or out of sync: the machine is fine
+		return runErr
+	}
+	if runErr != nil {
+		fmt.Printf("CPU Execution error: %v\n", runErr)
+		writeCrashBundle(m.CPU, runErr)
+		return runErr
+	}
+	if ioErr != nil {
+		return fmt.Errorf("I/O error: %v", ioErr)
+	}
+	return nil
+}